@@ -0,0 +1,68 @@
+package kvdb
+
+import "context"
+
+// Batch is a list of writes applied atomically by DB.Write. Batch is not safe
+// for concurrent use.
+type Batch struct {
+	ops []op
+}
+
+type op struct {
+	key    string
+	value  string
+	delete bool
+}
+
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Set adds setting value of key to batch.
+func (b *Batch) Set(key, value string) *Batch {
+	b.ops = append(b.ops, op{key: key, value: value})
+	return b
+}
+
+// Delete adds deleting key to batch.
+func (b *Batch) Delete(key string) *Batch {
+	b.ops = append(b.ops, op{key: key, delete: true})
+	return b
+}
+
+// Len returns number of writes in batch.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Reset removes every write from batch, so it can be reused.
+func (b *Batch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+// Write applies writes of batch in order. Readers see either none or every write of batch.
+func (d *DB) Write(ctx context.Context, batch *Batch) error {
+	unlock, err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return d.apply(ctx, batch)
+}
+
+// apply runs writes of batch, database must be locked for writing.
+func (d *DB) apply(ctx context.Context, batch *Batch) error {
+	for _, op := range batch.ops {
+		var err error
+		if op.delete {
+			err = d.db.Del(ctx, op.key)
+		} else {
+			err = d.db.Set(ctx, op.key, op.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+// Package client is a typed Go client of kvdb server.
+//
+// Client speaks RESP, so it connects to listeners with protocol resp. Arguments are
+// sent as RESP bulk strings, callers never build query strings or escape
+// arguments. Error responses are returned as errors matching ErrSyntax,
+// ErrWrongArgs and other errors of the catalogue with errors.Is.
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"kvdb/internal/model"
+	netclient "kvdb/internal/network/client"
+	"kvdb/internal/network/endpoint"
+	"net"
+	"sync"
+	"time"
+)
+
+const defaultDialTimeout = 5 * time.Second
+
+var (
+	ErrClosed             = errors.New("client is closed")
+	ErrUnexpectedResponse = errors.New("unexpected response")
+	ErrNotRESP            = errors.New("server doesn't speak resp, check protocol of listener")
+
+	errUnexpectedData = errors.New("unexpected data in idle connection")
+)
+
+// Options configure client created by Dial.
+type Options struct {
+	Address     string        // Address of resp listener, host:port or unix:///path.
+	TLS         *tls.Config   // Connect using TLS when set.
+	DialTimeout time.Duration // Timeout of connecting and authentication, default 5s.
+	Username    string        // User to authenticate as, default user when empty.
+	Password    string        // AUTH is sent after connecting when set, PING otherwise.
+
+	Reconnect   bool                 // Connect again when connection fails.
+	Backoff     Backoff              // Delay between reconnect attempts.
+	Retry       RetryPolicy          // Commands replayed after reconnect, default RetryIdempotent.
+	OnReconnect func(ReconnectEvent) // Called on reconnect events, it must not use client.
+}
+
+// Client is a connection to server. It is safe for concurrent use, commands of
+// concurrent callers are sent one after another.
+//
+// Deadline of context passed to command is set on connection, canceling context
+// aborts command. Connection is closed when command is aborted or fails to read
+// or write, later commands return error.
+//
+// Client created by Dial with Options.Reconnect connects again instead: the next
+// command dials new connection with backoff and authenticates it. Command sent
+// when connection failed is replayed over new connection when Options.Retry
+// allows, otherwise its error is returned.
+type Client struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+	err    error    // Error which broke connection.
+	opts   *Options // Options of Dial, nil for New.
+}
+
+// Dial connects to server and authenticates when password is set. Dial fails with
+// ErrNotRESP when listener uses another protocol.
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = defaultDialTimeout
+	}
+	if opts.Retry == nil {
+		opts.Retry = RetryIdempotent
+	}
+
+	c := &Client{opts: &opts}
+	if err := c.connect(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect dials new connection and authenticates it when password is set.
+// Otherwise PING checks that server speaks RESP, any reply of RESP server
+// including error passes.
+func (c *Client) connect(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.DialTimeout)
+	defer cancel()
+
+	conn, err := endpoint.Dial(ctx, c.opts.Address, c.opts.TLS)
+	if err != nil {
+		return fmt.Errorf("failed dial: %w", err)
+	}
+	c.conn, c.reader, c.writer = conn, bufio.NewReader(conn), bufio.NewWriter(conn)
+	c.err = nil
+
+	handshake := []string{"PING"}
+	if c.opts.Password != "" {
+		handshake = authArgs(c.opts.Username, c.opts.Password)
+	}
+
+	results, err := c.roundTrip(ctx, [][]string{handshake})
+	if err == nil && c.opts.Password != "" {
+		err = okValue("AUTH", results[0])
+	}
+	if err != nil {
+		_ = conn.Close()
+		c.err = fmt.Errorf("connection is broken: %w", err)
+		return err
+	}
+	return nil
+}
+
+// New returns client using connection to resp listener.
+func New(conn net.Conn) *Client {
+	return &Client{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+	}
+}
+
+// Close closes connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if errors.Is(c.err, ErrClosed) {
+		return nil
+	}
+	c.err = ErrClosed
+	return c.conn.Close()
+}
+
+// broken returns error which broke connection, nil when connection is usable.
+func (c *Client) broken() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+// check returns error when connection can't be reused: it is broken, closed by
+// server or has unread data.
+func (c *Client) check() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	if c.reader.Buffered() > 0 {
+		return errUnexpectedData
+	}
+	return connCheck(c.conn)
+}
+
+// do sends commands in one write and reads their results. Error responses are
+// returned in results, error is returned when connection fails.
+func (c *Client) do(ctx context.Context, commands ...[]string) ([]model.Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.err != nil {
+		if !c.reconnects() {
+			return nil, c.err
+		}
+		// Commands were not sent yet, so any commands may be sent over new connection.
+		if err := c.reconnect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := c.roundTrip(ctx, commands)
+	if err == nil {
+		return results, nil
+	}
+
+	err = c.fail(ctx, err)
+	if !c.reconnects() || ctx.Err() != nil || !c.retryable(commands) {
+		return nil, err
+	}
+	if reconnectErr := c.reconnect(ctx); reconnectErr != nil {
+		return nil, fmt.Errorf("%w: %w", err, reconnectErr)
+	}
+
+	results, err = c.roundTrip(ctx, commands)
+	if err != nil {
+		return nil, c.fail(ctx, err)
+	}
+	return results, nil
+}
+
+// fail closes connection broken by err and returns err. Stream position is unknown
+// after failure, so connection can't be reused.
+func (c *Client) fail(ctx context.Context, err error) error {
+	_ = c.conn.Close()
+	if ctxErr := contextErr(ctx, err); ctxErr != nil {
+		err = fmt.Errorf("%w: %w", ctxErr, err)
+	}
+	c.err = fmt.Errorf("connection is broken: %w", err)
+	c.notify(ReconnectEvent{Kind: EventDisconnected, Err: err})
+	return err
+}
+
+// reconnects reports whether broken connection is replaced by new one.
+func (c *Client) reconnects() bool {
+	return c.opts != nil && c.opts.Reconnect && !errors.Is(c.err, ErrClosed)
+}
+
+func (c *Client) reconnect(ctx context.Context) error {
+	return netclient.Reconnect(ctx, c.opts.Backoff, c.connect, c.notify)
+}
+
+// retryable reports whether all commands may be replayed.
+func (c *Client) retryable(commands [][]string) bool {
+	for _, args := range commands {
+		if !c.opts.Retry(args) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Client) notify(event ReconnectEvent) {
+	if c.opts != nil && c.opts.OnReconnect != nil {
+		c.opts.OnReconnect(event)
+	}
+}
+
+func (c *Client) roundTrip(ctx context.Context, commands [][]string) ([]model.Result, error) {
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("failed set deadline: %w", err)
+	}
+	// Deadline in the past interrupts blocked read or write.
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	for _, args := range commands {
+		if err := writeCommand(c.writer, args); err != nil {
+			return nil, fmt.Errorf("failed write conn: %w", err)
+		}
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, fmt.Errorf("failed write conn: %w", err)
+	}
+
+	results := make([]model.Result, 0, len(commands))
+	for range commands {
+		result, err := readResult(c.reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed read conn: %w", err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// contextErr returns error of ctx which aborted command. Deadline of socket may
+// expire a bit earlier than deadline of ctx, so timeout counts as exceeded deadline.
+func contextErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	var netErr net.Error
+	if _, ok := ctx.Deadline(); ok && errors.As(err, &netErr) && netErr.Timeout() {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// call sends one command and returns its value or error response.
+func (c *Client) call(ctx context.Context, args ...string) (model.Value, error) {
+	results, err := c.do(ctx, args)
+	if err != nil {
+		return model.Value{}, err
+	}
+	return value(results[0])
+}
+
+func value(result model.Result) (model.Value, error) {
+	if result.Status == model.StatusError {
+		return model.Value{}, result.Err
+	}
+	return result.Value, nil
+}
+
+func unexpected(command string, v model.Value) error {
+	return fmt.Errorf("%w to %s: %s", ErrUnexpectedResponse, command, v)
+}
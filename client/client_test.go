@@ -0,0 +1,336 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"kvdb/internal/compute"
+	"kvdb/internal/database"
+	"kvdb/internal/rpc/query"
+	"kvdb/internal/session"
+	"kvdb/internal/storage/inmemory"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTestClient returns client connected to resp handler of in memory database.
+func newTestClient(t *testing.T, handler *query.Handler) *Client {
+	t.Helper()
+
+	if handler == nil {
+		db := database.New(zap.NewNop(), compute.New(), inmemory.New())
+		handler = query.New(db, zap.NewNop()).WithProtocol(query.ProtocolRESP)
+	}
+
+	clientConn, serverConn := net.Pipe()
+	ctx := session.NewContext(context.Background(), session.New(serverConn))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.Handle(ctx, serverConn)
+	}()
+
+	c := New(clientConn)
+	t.Cleanup(func() {
+		_ = c.Close()
+		<-done
+	})
+	return c
+}
+
+func TestClient_Commands(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	_, ok, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Values needing escaping in text protocol are sent as is.
+	value := "it's \"quoted\"\nmultiline # value"
+	require.NoError(t, c.Set(ctx, "key", value))
+	got, ok, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, value, got)
+
+	require.NoError(t, c.Set(ctx, "empty", ""))
+	got, ok, err = c.Get(ctx, "empty")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, got)
+
+	set, err := c.SetWithOptions(ctx, "key", "other", SetOptions{Condition: IfNotExists})
+	require.NoError(t, err)
+	assert.False(t, set)
+	set, err = c.SetWithOptions(ctx, "key", "other", SetOptions{Condition: IfExists})
+	require.NoError(t, err)
+	assert.True(t, set)
+
+	values, err := c.MGet(ctx, "key", "missing", "empty")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"key": "other", "empty": ""}, values)
+
+	require.NoError(t, c.Del(ctx, "key"))
+	_, ok, err = c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestClient_Counters(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	n, err := c.Incr(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = c.IncrBy(ctx, "counter", 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), n)
+
+	n, err = c.DecrBy(ctx, "counter", 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+
+	n, err = c.Decr(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	require.NoError(t, c.Set(ctx, "name", "alice"))
+	_, err = c.Incr(ctx, "name")
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	_, err = c.DecrBy(ctx, "counter", math.MinInt64)
+	assert.ErrorIs(t, err, ErrWrongArgs)
+	n, err = c.DecrBy(ctx, "counter", -math.MaxInt64+5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64), n)
+}
+
+func TestClient_Expire(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	_, found, err := c.TTL(ctx, "session")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	set, err := c.Expire(ctx, "session", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, set, "key doesn't exist")
+
+	require.NoError(t, c.Set(ctx, "session", "token"))
+	ttl, found, err := c.TTL(ctx, "session")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Zero(t, ttl, "no expiration")
+
+	set, err = c.Expire(ctx, "session", 90*time.Second+time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, set)
+	ttl, _, err = c.TTL(ctx, "session")
+	require.NoError(t, err)
+	assert.Equal(t, 91*time.Second, ttl, "rounded up to seconds")
+
+	set, err = c.Expire(ctx, "session", 0)
+	require.NoError(t, err)
+	assert.True(t, set)
+	_, found, err = c.Get(ctx, "session")
+	require.NoError(t, err)
+	assert.False(t, found, "deleted at once")
+}
+
+func TestClient_ErrorResponse(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	err := c.Auth(ctx, "", "secret")
+	require.ErrorIs(t, err, ErrDisabled)
+	assert.EqualError(t, err, "failed auth: no password is set")
+
+	code, ok := Code(err)
+	assert.True(t, ok)
+	assert.Equal(t, "DISABLED", code)
+
+	// Error response doesn't break connection.
+	require.NoError(t, c.Set(ctx, "key", "value"))
+}
+
+func TestClient_ContextDeadline(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	// Server never answers.
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := serverConn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	c := New(clientConn)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, _, err := c.Get(ctx, "key")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Connection is in unknown state, so it is not reused.
+	_, _, err = c.Get(context.Background(), "key")
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "connection is broken")
+}
+
+func TestClient_ContextCanceled(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	c := New(clientConn)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	// Nobody reads from pipe, so write blocks until context is canceled.
+	err := c.Set(ctx, "key", "value")
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestClient_Closed(t *testing.T) {
+	c := newTestClient(t, nil)
+	require.NoError(t, c.Close())
+	require.NoError(t, c.Close())
+
+	err := c.Set(context.Background(), "key", "value")
+	assert.True(t, errors.Is(err, ErrClosed))
+}
+
+// TestClient_Reconnect tests that idempotent command is replayed over new connection
+// when server closed connection.
+func TestClient_Reconnect(t *testing.T) {
+	server := newTestServer(t)
+
+	var events []EventKind
+	c, err := Dial(context.Background(), Options{
+		Address:   server.addr(),
+		Reconnect: true,
+		Backoff:   Backoff{Initial: time.Millisecond, MaxAttempts: 3},
+		OnReconnect: func(event ReconnectEvent) {
+			events = append(events, event.Kind)
+		},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "key", "value"))
+
+	require.Eventually(t, func() bool { return server.accepted() == 1 }, time.Second, time.Millisecond)
+	server.closeConns()
+
+	value, ok, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, []EventKind{EventDisconnected, EventReconnected}, events)
+	assert.Equal(t, 1, server.accepted())
+}
+
+// TestClient_ReconnectNotReplayed tests that counter is not replayed, but the
+// next command uses new connection.
+func TestClient_ReconnectNotReplayed(t *testing.T) {
+	server := newTestServer(t)
+
+	c, err := Dial(context.Background(), Options{
+		Address:   server.addr(),
+		Reconnect: true,
+		Backoff:   Backoff{Initial: time.Millisecond, MaxAttempts: 3},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.Eventually(t, func() bool { return server.accepted() == 1 }, time.Second, time.Millisecond)
+	server.closeConns()
+
+	ctx := context.Background()
+	_, err = c.Incr(ctx, "counter")
+	require.Error(t, err)
+
+	n, err := c.Incr(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+// TestClient_ReconnectFailed tests that reconnect gives up after MaxAttempts
+// and the next command tries again.
+func TestClient_ReconnectFailed(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+
+	// Server replies to PING of Dial and stops.
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = conn.Read(make([]byte, 64))
+		_, _ = conn.Write([]byte("+PONG\r\n"))
+		accepted <- conn
+	}()
+
+	c, err := Dial(context.Background(), Options{
+		Address:   addr,
+		Reconnect: true,
+		Backoff:   Backoff{Initial: time.Millisecond, MaxAttempts: 2},
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	// Server is stopped.
+	require.NoError(t, listener.Close())
+	(<-accepted).Close()
+
+	_, _, err = c.Get(context.Background(), "key")
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "failed reconnect after 2 attempts")
+
+	_, _, err = c.Get(context.Background(), "key")
+	assert.ErrorContains(t, err, "failed reconnect after 2 attempts")
+}
+
+// TestDial_NotRESP tests that Dial fails fast on listener of text protocol, which
+// replies have no line end.
+func TestDial_NotRESP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	db := database.New(zap.NewNop(), compute.New(), inmemory.New())
+	handler := query.New(db, zap.NewNop())
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			handler.Handle(session.NewContext(context.Background(), session.New(conn)), conn)
+		}
+	}()
+
+	start := time.Now()
+	_, err = Dial(context.Background(), Options{Address: listener.Addr().String(), DialTimeout: 5 * time.Second})
+	assert.ErrorIs(t, err, ErrNotRESP)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"kvdb/internal/model"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Replies of TTL for keys without time left.
+const (
+	ttlNoExpiration = -1
+	ttlMissingKey   = -2
+)
+
+// Condition of SetWithOptions.
+type Condition int
+
+const (
+	Always      Condition = iota // Set value unconditionally.
+	IfNotExists                  // Set value only when key doesn't exist, NX.
+	IfExists                     // Set value only when key exists, XX.
+)
+
+// SetOptions configure SetWithOptions.
+type SetOptions struct {
+	Condition Condition
+}
+
+// Auth authenticates connection as user, default user when username is empty.
+func (c *Client) Auth(ctx context.Context, username, password string) error {
+	return c.expectOK(ctx, authArgs(username, password)...)
+}
+
+func authArgs(username, password string) []string {
+	if username == "" {
+		return []string{"AUTH", password}
+	}
+	return []string{"AUTH", username, password}
+}
+
+// Get returns value of key and whether key exists.
+func (c *Client) Get(ctx context.Context, key string) (string, bool, error) {
+	v, err := c.call(ctx, "GET", key)
+	if err != nil {
+		return "", false, err
+	}
+	return stringValue("GET", v)
+}
+
+// Set sets value of key.
+func (c *Client) Set(ctx context.Context, key, value string) error {
+	return c.expectOK(ctx, "SET", key, value)
+}
+
+// SetWithOptions sets value of key and returns whether value is set, it is not
+// set when condition of opts is not met.
+func (c *Client) SetWithOptions(ctx context.Context, key, value string, opts SetOptions) (bool, error) {
+	args := []string{"SET", key, value}
+	switch opts.Condition {
+	case IfNotExists:
+		args = append(args, "NX")
+	case IfExists:
+		args = append(args, "XX")
+	}
+
+	v, err := c.call(ctx, args...)
+	if err != nil {
+		return false, err
+	}
+
+	switch v.Kind {
+	case model.KindNone:
+		return true, nil
+	case model.KindNil:
+		return false, nil
+	default:
+		return false, unexpected("SET", v)
+	}
+}
+
+// Del deletes key. Deleting missing key is not an error.
+func (c *Client) Del(ctx context.Context, key string) error {
+	return c.expectOK(ctx, "DEL", key)
+}
+
+// MGet returns values of existing keys. Keys are read with one round trip, but
+// not atomically: writes of other clients may happen between reads.
+func (c *Client) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	commands := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		commands = append(commands, []string{"GET", key})
+	}
+
+	results, err := c.do(ctx, commands...)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(keys))
+	for i, result := range results {
+		v, err := value(result)
+		if err != nil {
+			return nil, err
+		}
+		s, ok, err := stringValue("GET", v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			values[keys[i]] = s
+		}
+	}
+	return values, nil
+}
+
+// Incr atomically adds 1 to integer value of key and returns new value. Missing
+// key counts as 0, value which is not integer fails with ErrWrongType.
+func (c *Client) Incr(ctx context.Context, key string) (int64, error) {
+	return c.intCall(ctx, "INCR", key)
+}
+
+// IncrBy atomically adds delta to integer value of key and returns new value.
+func (c *Client) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return c.intCall(ctx, "INCRBY", key, strconv.FormatInt(delta, 10))
+}
+
+// Decr atomically subtracts 1 from integer value of key and returns new value.
+func (c *Client) Decr(ctx context.Context, key string) (int64, error) {
+	return c.IncrBy(ctx, key, -1)
+}
+
+// DecrBy atomically subtracts delta from integer value of key and returns new value.
+// math.MinInt64 can't be negated and fails with ErrWrongArgs.
+func (c *Client) DecrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, fmt.Errorf("%w: delta %d can't be negated", ErrWrongArgs, delta)
+	}
+	return c.IncrBy(ctx, key, -delta)
+}
+
+// Expire sets time to live of key and returns false when key doesn't exist. ttl
+// is rounded up to whole seconds, key with ttl of 0 or less is deleted at once.
+func (c *Client) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	seconds := int64(ttl / time.Second)
+	if ttl%time.Second > 0 {
+		seconds++
+	}
+
+	n, err := c.intCall(ctx, "EXPIRE", key, strconv.FormatInt(seconds, 10))
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// TTL returns time left until key expires, in whole seconds rounded up, and
+// whether key exists. ttl is 0 when key has no expiration.
+func (c *Client) TTL(ctx context.Context, key string) (ttl time.Duration, found bool, err error) {
+	v, err := c.call(ctx, "TTL", key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch {
+	case v.Kind != model.KindInt:
+		return 0, false, unexpected("TTL", v)
+	case v.Int == ttlMissingKey:
+		return 0, false, nil
+	case v.Int == ttlNoExpiration:
+		return 0, true, nil
+	case v.Int < 0:
+		return 0, false, unexpected("TTL", v)
+	default:
+		return time.Duration(v.Int) * time.Second, true, nil
+	}
+}
+
+func (c *Client) expectOK(ctx context.Context, args ...string) error {
+	results, err := c.do(ctx, args)
+	if err != nil {
+		return err
+	}
+	return okValue(args[0], results[0])
+}
+
+// okValue returns error unless result is OK response.
+func okValue(command string, result model.Result) error {
+	v, err := value(result)
+	if err != nil {
+		return err
+	}
+	if v.Kind != model.KindNone {
+		return unexpected(command, v)
+	}
+	return nil
+}
+
+func (c *Client) intCall(ctx context.Context, args ...string) (int64, error) {
+	v, err := c.call(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	if v.Kind != model.KindInt {
+		return 0, unexpected(args[0], v)
+	}
+	return v.Int, nil
+}
+
+func stringValue(command string, v model.Value) (string, bool, error) {
+	switch v.Kind {
+	case model.KindString:
+		return v.Str, true, nil
+	case model.KindNil:
+		return "", false, nil
+	default:
+		return "", false, unexpected(command, v)
+	}
+}
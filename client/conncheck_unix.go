@@ -0,0 +1,48 @@
+//go:build linux || darwin
+
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// connCheck peeks into socket without blocking. Idle connection has nothing to
+// read, so EOF means server closed it and data means stream is out of sync.
+func connCheck(conn net.Conn) error {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	sysConn, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	rawConn, err := sysConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var checkErr error
+	buf := make([]byte, 1)
+	err = rawConn.Read(func(fd uintptr) bool {
+		n, _, err := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		switch {
+		case n == 0 && err == nil:
+			checkErr = io.EOF
+		case n > 0:
+			checkErr = errUnexpectedData
+		case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EWOULDBLOCK):
+			checkErr = nil
+		default:
+			checkErr = err
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return checkErr
+}
@@ -0,0 +1,29 @@
+package client
+
+import "kvdb/internal/model"
+
+// Errors returned for error responses of server, check them with errors.Is.
+// Message of returned error is the message sent by server.
+var (
+	ErrServer     = model.ErrErr // Failure without more specific code.
+	ErrSyntax     = model.ErrSyntax
+	ErrUnknownCmd = model.ErrUnknownCmd
+	ErrWrongArgs  = model.ErrWrongArgs
+	ErrWrongType  = model.ErrWrongType
+	ErrOOM        = model.ErrOOM
+	ErrNoAuth     = model.ErrNoAuth
+	ErrWrongPass  = model.ErrWrongPass
+	ErrNoPerm     = model.ErrNoPerm
+	ErrReadOnly   = model.ErrReadOnly
+	ErrBusy       = model.ErrBusy
+	ErrLimit      = model.ErrLimit
+	ErrTooLarge   = model.ErrTooLarge
+	ErrNotFound   = model.ErrNotFound
+	ErrDisabled   = model.ErrDisabled
+)
+
+// Code returns code of error response, like "WRONGARGS", and false for other errors.
+func Code(err error) (string, bool) {
+	code, ok := model.CodeOf(err)
+	return string(code), ok
+}
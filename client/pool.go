@@ -0,0 +1,423 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultMaxConns    = 10
+	defaultIdleTimeout = 5 * time.Minute
+)
+
+var ErrPoolClosed = errors.New("pool is closed")
+
+// PoolOptions configure pool created by NewPool.
+type PoolOptions struct {
+	Options                   // Options of every connection.
+	MinConns    int           // Connections kept open even when idle, default 0.
+	MaxConns    int           // Connections open at once, default 10.
+	IdleTimeout time.Duration // Idle connections above MinConns are closed after, default 5m.
+}
+
+// PoolStats are statistics of pool since it was created.
+type PoolStats struct {
+	Hits         uint64        // Borrows served by idle connection.
+	Misses       uint64        // Borrows which dialed new connection.
+	DialErrors   uint64        // Failed dials.
+	Waits        uint64        // Borrows which waited for connection as MaxConns were borrowed.
+	WaitDuration time.Duration // Total time spent waiting.
+	Timeouts     uint64        // Borrows which gave up waiting because context was done.
+	Unhealthy    uint64        // Idle connections closed by health check on borrow.
+	Evicted      uint64        // Idle connections closed after IdleTimeout.
+	TotalConns   int           // Open connections, idle and borrowed.
+	IdleConns    int           // Idle connections.
+}
+
+// Pool is a set of connections safe for concurrent use. Every command borrows
+// connection, so commands of concurrent callers run in parallel up to MaxConns.
+//
+// Idle connection is checked before it is borrowed and replaced when server
+// closed it. Connection broken by failed or aborted command is not returned to pool.
+type Pool struct {
+	opts PoolOptions
+	sem  chan struct{} // Token per borrowed connection.
+
+	mu     sync.Mutex
+	idle   []*pooledConn // Most recently used last.
+	total  int
+	closed bool
+
+	stats struct {
+		hits, misses, dialErrors, waits, timeouts, unhealthy, evicted atomic.Uint64
+		waitDuration                                                  atomic.Int64
+	}
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+type pooledConn struct {
+	client    *Client
+	idleSince time.Time
+}
+
+// NewPool opens MinConns connections and starts closing idle connections.
+func NewPool(ctx context.Context, opts PoolOptions) (*Pool, error) {
+	if opts.MaxConns == 0 {
+		opts.MaxConns = defaultMaxConns
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = defaultIdleTimeout
+	}
+	if opts.MaxConns < 0 || opts.MinConns < 0 || opts.MinConns > opts.MaxConns {
+		return nil, fmt.Errorf("invalid pool size: min %d, max %d", opts.MinConns, opts.MaxConns)
+	}
+	if opts.IdleTimeout < 0 {
+		return nil, fmt.Errorf("invalid idle timeout: %s", opts.IdleTimeout)
+	}
+
+	p := &Pool{
+		opts: opts,
+		sem:  make(chan struct{}, opts.MaxConns),
+		stop: make(chan struct{}),
+	}
+
+	for range opts.MinConns {
+		c, err := p.dial(ctx)
+		if err != nil {
+			_ = p.Close()
+			return nil, err
+		}
+		p.put(c)
+	}
+
+	p.wg.Add(1)
+	go p.maintain()
+
+	return p, nil
+}
+
+// With runs fn with borrowed connection. fn must not keep connection after it returns.
+func (p *Pool) With(ctx context.Context, fn func(c *Client) error) error {
+	c, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.release(c)
+
+	return fn(c)
+}
+
+// Get returns value of key and whether key exists.
+func (p *Pool) Get(ctx context.Context, key string) (value string, found bool, err error) {
+	err = p.With(ctx, func(c *Client) error {
+		value, found, err = c.Get(ctx, key)
+		return err
+	})
+	return value, found, err
+}
+
+// Set sets value of key.
+func (p *Pool) Set(ctx context.Context, key, value string) error {
+	return p.With(ctx, func(c *Client) error {
+		return c.Set(ctx, key, value)
+	})
+}
+
+// SetWithOptions sets value of key and returns whether value is set.
+func (p *Pool) SetWithOptions(ctx context.Context, key, value string, opts SetOptions) (set bool, err error) {
+	err = p.With(ctx, func(c *Client) error {
+		set, err = c.SetWithOptions(ctx, key, value, opts)
+		return err
+	})
+	return set, err
+}
+
+// Del deletes key.
+func (p *Pool) Del(ctx context.Context, key string) error {
+	return p.With(ctx, func(c *Client) error {
+		return c.Del(ctx, key)
+	})
+}
+
+// MGet returns values of existing keys.
+func (p *Pool) MGet(ctx context.Context, keys ...string) (values map[string]string, err error) {
+	err = p.With(ctx, func(c *Client) error {
+		values, err = c.MGet(ctx, keys...)
+		return err
+	})
+	return values, err
+}
+
+// IncrBy atomically adds delta to integer value of key and returns new value.
+func (p *Pool) IncrBy(ctx context.Context, key string, delta int64) (n int64, err error) {
+	err = p.With(ctx, func(c *Client) error {
+		n, err = c.IncrBy(ctx, key, delta)
+		return err
+	})
+	return n, err
+}
+
+// Incr atomically adds 1 to integer value of key and returns new value.
+func (p *Pool) Incr(ctx context.Context, key string) (int64, error) {
+	return p.IncrBy(ctx, key, 1)
+}
+
+// Decr atomically subtracts 1 from integer value of key and returns new value.
+func (p *Pool) Decr(ctx context.Context, key string) (int64, error) {
+	return p.IncrBy(ctx, key, -1)
+}
+
+// Expire sets time to live of key and returns false when key doesn't exist.
+func (p *Pool) Expire(ctx context.Context, key string, ttl time.Duration) (set bool, err error) {
+	err = p.With(ctx, func(c *Client) error {
+		set, err = c.Expire(ctx, key, ttl)
+		return err
+	})
+	return set, err
+}
+
+// TTL returns time left until key expires and whether key exists.
+func (p *Pool) TTL(ctx context.Context, key string) (ttl time.Duration, found bool, err error) {
+	err = p.With(ctx, func(c *Client) error {
+		ttl, found, err = c.TTL(ctx, key)
+		return err
+	})
+	return ttl, found, err
+}
+
+// Stats returns statistics of pool.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	total, idle := p.total, len(p.idle)
+	p.mu.Unlock()
+
+	return PoolStats{
+		Hits:         p.stats.hits.Load(),
+		Misses:       p.stats.misses.Load(),
+		DialErrors:   p.stats.dialErrors.Load(),
+		Waits:        p.stats.waits.Load(),
+		WaitDuration: time.Duration(p.stats.waitDuration.Load()),
+		Timeouts:     p.stats.timeouts.Load(),
+		Unhealthy:    p.stats.unhealthy.Load(),
+		Evicted:      p.stats.evicted.Load(),
+		TotalConns:   total,
+		IdleConns:    idle,
+	}
+}
+
+// Close closes idle connections, borrowed connections are closed when returned.
+// Later commands return ErrPoolClosed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.total -= len(idle)
+	p.mu.Unlock()
+
+	close(p.stop)
+	p.wg.Wait()
+
+	var errs []error
+	for _, pc := range idle {
+		errs = append(errs, pc.client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// acquire borrows idle connection or dials new one, waiting while MaxConns are borrowed.
+func (p *Pool) acquire(ctx context.Context) (*Client, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	for {
+		c, err := p.take()
+		if err != nil {
+			<-p.sem
+			return nil, err
+		}
+		if c == nil {
+			break
+		}
+
+		if err := c.check(); err != nil {
+			p.stats.unhealthy.Add(1)
+			p.discard(c)
+			continue
+		}
+
+		p.stats.hits.Add(1)
+		return c, nil
+	}
+
+	p.stats.misses.Add(1)
+	c, err := p.dial(ctx)
+	if err != nil {
+		<-p.sem
+		return nil, err
+	}
+	return c, nil
+}
+
+// wait takes token of borrowed connection.
+func (p *Pool) wait(ctx context.Context) error {
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	p.stats.waits.Add(1)
+	start := time.Now()
+	defer func() {
+		p.stats.waitDuration.Add(int64(time.Since(start)))
+	}()
+
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		p.stats.timeouts.Add(1)
+		return ctx.Err()
+	case <-p.stop:
+		return ErrPoolClosed
+	}
+}
+
+// release returns borrowed connection to pool.
+func (p *Pool) release(c *Client) {
+	defer func() { <-p.sem }()
+
+	if c.broken() != nil {
+		p.discard(c)
+		return
+	}
+	p.put(c)
+}
+
+// take removes most recently used idle connection, nil is returned when there is none.
+func (p *Pool) take() (*Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	if len(p.idle) == 0 {
+		return nil, nil
+	}
+
+	pc := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return pc.client, nil
+}
+
+func (p *Pool) put(c *Client) {
+	p.mu.Lock()
+	if !p.closed {
+		p.idle = append(p.idle, &pooledConn{client: c, idleSince: time.Now()})
+		p.mu.Unlock()
+		return
+	}
+	p.total--
+	p.mu.Unlock()
+
+	_ = c.Close()
+}
+
+func (p *Pool) discard(c *Client) {
+	p.mu.Lock()
+	p.total--
+	p.mu.Unlock()
+
+	_ = c.Close()
+}
+
+func (p *Pool) dial(ctx context.Context) (*Client, error) {
+	c, err := Dial(ctx, p.opts.Options)
+	if err != nil {
+		p.stats.dialErrors.Add(1)
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.total++
+	p.mu.Unlock()
+	return c, nil
+}
+
+// maintain closes connections idle longer than IdleTimeout and reopens
+// connections up to MinConns.
+func (p *Pool) maintain() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.opts.IdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.evict()
+			p.refill()
+		}
+	}
+}
+
+func (p *Pool) evict() {
+	deadline := time.Now().Add(-p.opts.IdleTimeout)
+
+	p.mu.Lock()
+	var expired []*pooledConn
+	// Least recently used connections are first.
+	for len(p.idle) > 0 && p.total > p.opts.MinConns && p.idle[0].idleSince.Before(deadline) {
+		expired = append(expired, p.idle[0])
+		p.idle = p.idle[1:]
+		p.total--
+	}
+	p.mu.Unlock()
+
+	for _, pc := range expired {
+		p.stats.evicted.Add(1)
+		_ = pc.client.Close()
+	}
+}
+
+func (p *Pool) refill() {
+	for {
+		p.mu.Lock()
+		missing := !p.closed && p.total < p.opts.MinConns
+		p.mu.Unlock()
+		if !missing {
+			return
+		}
+
+		// Connection being dialed takes token, so pool never exceeds MaxConns.
+		select {
+		case p.sem <- struct{}{}:
+		default:
+			return
+		}
+
+		c, err := p.dial(context.Background())
+		if err == nil {
+			p.put(c)
+		}
+		<-p.sem
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"kvdb/internal/compute"
+	"kvdb/internal/database"
+	"kvdb/internal/rpc/query"
+	"kvdb/internal/session"
+	"kvdb/internal/storage/inmemory"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testServer serves resp protocol of in memory database on tcp port.
+type testServer struct {
+	listener net.Listener
+	handler  *query.Handler
+
+	mu    sync.Mutex
+	conns []net.Conn
+	wg    sync.WaitGroup
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	db := database.New(zap.NewNop(), compute.New(), inmemory.New())
+	s := &testServer{
+		listener: listener,
+		handler:  query.New(db, zap.NewNop()).WithProtocol(query.ProtocolRESP),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	t.Cleanup(func() {
+		_ = listener.Close()
+		s.closeConns()
+		s.wg.Wait()
+	})
+	return s
+}
+
+func (s *testServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handler.Handle(session.NewContext(context.Background(), session.New(conn)), conn)
+		}()
+	}
+}
+
+func (s *testServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+// closeConns closes every accepted connection, like restarted server.
+func (s *testServer) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testServer) accepted() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+func TestNewPool_InvalidOptions(t *testing.T) {
+	_, err := NewPool(context.Background(), PoolOptions{MinConns: 5, MaxConns: 2})
+	require.Error(t, err)
+
+	_, err = NewPool(context.Background(), PoolOptions{IdleTimeout: -time.Second})
+	require.Error(t, err)
+}
+
+func TestNewPool_DialError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	_, err = NewPool(context.Background(), PoolOptions{
+		Options:  Options{Address: addr, DialTimeout: time.Second},
+		MinConns: 1,
+	})
+	require.Error(t, err)
+}
+
+func TestPool_MinConns(t *testing.T) {
+	server := newTestServer(t)
+
+	pool, err := NewPool(context.Background(), PoolOptions{Options: Options{Address: server.addr()}, MinConns: 3})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	stats := pool.Stats()
+	assert.Equal(t, 3, stats.TotalConns)
+	assert.Equal(t, 3, stats.IdleConns)
+
+	require.NoError(t, pool.Set(context.Background(), "key", "value"))
+	stats = pool.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(0), stats.Misses)
+	assert.Equal(t, 3, stats.TotalConns)
+}
+
+// TestPool_Parallel tests that many goroutines share pool without exceeding MaxConns.
+func TestPool_Parallel(t *testing.T) {
+	server := newTestServer(t)
+
+	const maxConns, workers, iterations = 4, 32, 100
+	pool, err := NewPool(context.Background(), PoolOptions{
+		Options:  Options{Address: server.addr()},
+		MaxConns: maxConns,
+	})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for worker := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := "key:" + strconv.Itoa(worker)
+			for i := range iterations {
+				value := strconv.Itoa(i)
+				if !assert.NoError(t, pool.Set(ctx, key, value)) {
+					return
+				}
+				got, ok, err := pool.Get(ctx, key)
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.True(t, ok)
+				assert.Equal(t, value, got, "response of other goroutine")
+
+				_, err = pool.Incr(ctx, "counter")
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	n, err := pool.IncrBy(ctx, "counter", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(workers*iterations), n)
+
+	stats := pool.Stats()
+	assert.LessOrEqual(t, stats.TotalConns, maxConns)
+	assert.LessOrEqual(t, server.accepted(), maxConns)
+	assert.Equal(t, stats.TotalConns, stats.IdleConns)
+	assert.Equal(t, uint64(workers*iterations*3+1), stats.Hits+stats.Misses)
+	assert.Positive(t, stats.Waits)
+}
+
+func TestPool_WaitTimeout(t *testing.T) {
+	server := newTestServer(t)
+
+	pool, err := NewPool(context.Background(), PoolOptions{Options: Options{Address: server.addr()}, MaxConns: 1})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	borrowed := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		_ = pool.With(context.Background(), func(*Client) error {
+			close(borrowed)
+			<-done
+			return nil
+		})
+	}()
+	<-borrowed
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = pool.Set(ctx, "key", "value")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	close(done)
+
+	stats := pool.Stats()
+	assert.Equal(t, uint64(1), stats.Timeouts)
+	assert.GreaterOrEqual(t, stats.WaitDuration, 20*time.Millisecond)
+}
+
+// TestPool_HealthCheck tests that connections closed by server are replaced on borrow.
+func TestPool_HealthCheck(t *testing.T) {
+	server := newTestServer(t)
+
+	pool, err := NewPool(context.Background(), PoolOptions{Options: Options{Address: server.addr()}, MinConns: 2})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	require.Eventually(t, func() bool { return server.accepted() == 2 }, time.Second, time.Millisecond)
+	server.closeConns()
+	// Let FIN of server reach client sockets.
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, pool.Set(context.Background(), "key", "value"))
+
+	stats := pool.Stats()
+	assert.Equal(t, uint64(2), stats.Unhealthy)
+	assert.Equal(t, uint64(1), stats.Misses)
+}
+
+func TestPool_BrokenConnNotReused(t *testing.T) {
+	server := newTestServer(t)
+
+	pool, err := NewPool(context.Background(), PoolOptions{Options: Options{Address: server.addr()}})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	err = pool.With(context.Background(), func(c *Client) error {
+		require.Eventually(t, func() bool { return server.accepted() == 1 }, time.Second, time.Millisecond)
+		// Connection is dropped while command runs.
+		server.closeConns()
+		return c.Set(context.Background(), "key", "value")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 0, pool.Stats().TotalConns)
+
+	require.NoError(t, pool.Set(context.Background(), "key", "value"))
+	assert.Equal(t, 1, pool.Stats().TotalConns)
+}
+
+func TestPool_IdleEviction(t *testing.T) {
+	server := newTestServer(t)
+
+	pool, err := NewPool(context.Background(), PoolOptions{
+		Options:     Options{Address: server.addr()},
+		MinConns:    1,
+		MaxConns:    3,
+		IdleTimeout: 20 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	// Borrow 3 connections at once.
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = pool.With(context.Background(), func(*Client) error {
+				<-start
+				return nil
+			})
+		}()
+	}
+	require.Eventually(t, func() bool { return pool.Stats().TotalConns == 3 }, time.Second, time.Millisecond)
+	close(start)
+	wg.Wait()
+
+	require.Eventually(t, func() bool { return pool.Stats().TotalConns == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, uint64(2), pool.Stats().Evicted)
+}
+
+func TestPool_Close(t *testing.T) {
+	server := newTestServer(t)
+
+	pool, err := NewPool(context.Background(), PoolOptions{Options: Options{Address: server.addr()}, MinConns: 1})
+	require.NoError(t, err)
+
+	require.NoError(t, pool.Close())
+	require.NoError(t, pool.Close())
+	assert.Equal(t, 0, pool.Stats().TotalConns)
+
+	err = pool.Set(context.Background(), "key", "value")
+	assert.True(t, errors.Is(err, ErrPoolClosed))
+}
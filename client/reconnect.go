@@ -0,0 +1,39 @@
+package client
+
+import netclient "kvdb/internal/network/client"
+
+// Backoff is a delay between reconnect attempts. Delay starts at Initial and is
+// multiplied by Multiplier after every failed attempt up to Max, every delay is
+// randomly changed by up to Jitter of it. Zero fields take defaults: 100ms, 10s,
+// 2 and 0.2. MaxAttempts of 0 retries until context of command is done.
+type Backoff = netclient.Backoff
+
+// ReconnectEvent describes change of connection state, it is passed to
+// Options.OnReconnect.
+type ReconnectEvent = netclient.ReconnectEvent
+
+// EventKind is a kind of reconnect event.
+type EventKind = netclient.EventKind
+
+const (
+	EventDisconnected    = netclient.EventDisconnected    // Connection failed and was closed.
+	EventReconnectFailed = netclient.EventReconnectFailed // Attempt to reconnect failed.
+	EventReconnected     = netclient.EventReconnected     // New connection is ready.
+)
+
+// RetryPolicy reports whether command, args including command name, may be sent
+// again over new connection after connection failed while command was sent.
+// Server may have executed command before connection failed.
+type RetryPolicy func(args []string) bool
+
+// RetryIdempotent replays GET, TTL, DEL and SET without condition. SetWithOptions
+// with condition, counters and Expire are not replayed: result of the second
+// attempt would differ from result of the first one.
+func RetryIdempotent(args []string) bool {
+	return netclient.Idempotent(args)
+}
+
+// RetryNever never replays commands.
+func RetryNever([]string) bool {
+	return false
+}
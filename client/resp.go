@@ -0,0 +1,139 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"kvdb/internal/model"
+	"strconv"
+	"strings"
+)
+
+// maxArrayLen limits number of items of array response to protect from broken stream.
+const maxArrayLen = 1 << 20
+
+var errInvalidResponse = errors.New("invalid response")
+
+// writeCommand writes command as RESP array of bulk strings.
+func writeCommand(w *bufio.Writer, args []string) error {
+	if _, err := fmt.Fprintf(w, "*%d\r\n", len(args)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if _, err := fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readResult reads RESP response. Error "-<CODE> <message>" is returned as failed
+// result with error matching error of catalogue with the same code.
+// Reply of server speaking another protocol fails with ErrNotRESP.
+func readResult(r *bufio.Reader) (model.Result, error) {
+	prefix, err := r.Peek(1)
+	if err != nil {
+		return model.Result{}, err
+	}
+	// Replies of text and framed protocols have no RESP type byte, they may have
+	// no line end either, so reply is not read further.
+	if !strings.ContainsRune("+-:$*", rune(prefix[0])) {
+		return model.Result{}, fmt.Errorf("%w: %w: reply starts with %q", errInvalidResponse, ErrNotRESP, prefix[0])
+	}
+
+	line, err := readLine(r)
+	if err != nil {
+		return model.Result{}, err
+	}
+
+	if message, ok := strings.CutPrefix(line, "-"); ok {
+		return parseError(message), nil
+	}
+
+	v, err := parseValue(r, line)
+	if err != nil {
+		return model.Result{}, err
+	}
+	return model.OK(v), nil
+}
+
+func parseError(message string) model.Result {
+	name, text, _ := strings.Cut(message, " ")
+	code, ok := model.ParseCode(name)
+	if !ok {
+		code, text = model.CodeErr, message
+	}
+	return model.Error(code, model.WithCode(code, errors.New(text)))
+}
+
+func readValue(r *bufio.Reader) (model.Value, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return model.Value{}, err
+	}
+	return parseValue(r, line)
+}
+
+func parseValue(r *bufio.Reader, line string) (model.Value, error) {
+	if line == "" {
+		return model.Value{}, fmt.Errorf("%w: empty line", errInvalidResponse)
+	}
+
+	switch prefix, rest := line[0], line[1:]; prefix {
+	case '+':
+		if rest == "OK" {
+			return model.NoneValue(), nil
+		}
+		return model.StringValue(rest), nil
+	case ':':
+		n, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			return model.Value{}, fmt.Errorf("%w: invalid integer %q", errInvalidResponse, line)
+		}
+		return model.IntValue(n), nil
+	case '$':
+		size, err := strconv.Atoi(rest)
+		if err != nil || size < -1 {
+			return model.Value{}, fmt.Errorf("%w: invalid bulk string header %q", errInvalidResponse, line)
+		}
+		if size == -1 {
+			return model.NilValue(), nil
+		}
+
+		// Payload is followed by \r\n.
+		payload := make([]byte, size+2)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return model.Value{}, err
+		}
+		return model.StringValue(string(payload[:size])), nil
+	case '*':
+		count, err := strconv.Atoi(rest)
+		if err != nil || count < -1 || count > maxArrayLen {
+			return model.Value{}, fmt.Errorf("%w: invalid array header %q", errInvalidResponse, line)
+		}
+		if count == -1 {
+			return model.NilValue(), nil
+		}
+
+		items := make([]model.Value, 0, count)
+		for range count {
+			item, err := readValue(r)
+			if err != nil {
+				return model.Value{}, err
+			}
+			items = append(items, item)
+		}
+		return model.ArrayValue(items), nil
+	default:
+		return model.Value{}, fmt.Errorf("%w: unknown type %q", errInvalidResponse, line)
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
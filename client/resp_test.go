@@ -0,0 +1,67 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"kvdb/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCommand(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := bufio.NewWriter(buf)
+	require.NoError(t, writeCommand(w, []string{"SET", "key", "hello world"}))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$11\r\nhello world\r\n", buf.String())
+}
+
+func TestReadResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected model.Value
+	}{
+		{name: "ok", input: "+OK\r\n", expected: model.NoneValue()},
+		{name: "nil", input: "$-1\r\n", expected: model.NilValue()},
+		{name: "integer", input: ":-42\r\n", expected: model.IntValue(-42)},
+		{name: "bulk string", input: "$7\r\nab\r\ncde\r\n", expected: model.StringValue("ab\r\ncde")},
+		{
+			name:     "array",
+			input:    "*3\r\n$1\r\na\r\n$-1\r\n:1\r\n",
+			expected: model.ArrayValue([]model.Value{model.StringValue("a"), model.NilValue(), model.IntValue(1)}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := readResult(bufio.NewReader(strings.NewReader(tt.input)))
+			require.NoError(t, err)
+			assert.Equal(t, model.OK(tt.expected), result)
+		})
+	}
+}
+
+func TestReadResult_Error(t *testing.T) {
+	result, err := readResult(bufio.NewReader(strings.NewReader("-NOPERM failed check access: permission denied\r\n")))
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusError, result.Status)
+	assert.Equal(t, model.CodeNoPerm, result.Code)
+	assert.ErrorIs(t, result.Err, ErrNoPerm)
+	assert.EqualError(t, result.Err, "failed check access: permission denied")
+
+	result, err = readResult(bufio.NewReader(strings.NewReader("-boom\r\n")))
+	require.NoError(t, err)
+	assert.ErrorIs(t, result.Err, ErrServer)
+	assert.EqualError(t, result.Err, "boom")
+}
+
+func TestReadResult_Invalid(t *testing.T) {
+	for _, input := range []string{"?\r\n", ":x\r\n", "$-2\r\n", "*-5\r\n", "\r\n"} {
+		_, err := readResult(bufio.NewReader(strings.NewReader(input)))
+		assert.ErrorIs(t, err, errInvalidResponse, input)
+	}
+}
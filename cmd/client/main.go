@@ -3,9 +3,16 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
+	"fmt"
 	cli "kvdb/internal/cli/client"
-	"kvdb/internal/network/client"
+	"kvdb/internal/compute"
+	"kvdb/internal/model"
+	netclient "kvdb/internal/network/client"
+	"kvdb/internal/network/endpoint"
+	"kvdb/internal/network/tlsconf"
 	"net"
 	"os"
 	"os/signal"
@@ -15,17 +22,59 @@ import (
 )
 
 func main() {
-	addr := flag.String("addr", "127.0.0.1:8080", "database address")
+	addr := flag.String("addr", "127.0.0.1:8080", "database address, host:port or unix:///path")
+	useTLS := flag.Bool("tls", false, "connect using tls")
+	caCert := flag.String("cacert", "", "ca certificate to verify server")
+	cert := flag.String("cert", "", "client certificate for mutual tls")
+	key := flag.String("key", "", "client private key for mutual tls")
+	password := flag.String("auth", "", "password to authenticate with")
+	reconnectAttempts := flag.Int("reconnect-attempts", 5, "attempts to reconnect when connection fails, 0 to disable")
+	flag.Parse()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	mainLogger := zap.NewExample()
 
-	client, err := initClient(*addr)
+	var tlsConfig *tls.Config
+	if *useTLS {
+		var err error
+		tlsConfig, err = tlsconf.ClientConfig(tlsconf.Options{
+			CertFile: *cert,
+			KeyFile:  *key,
+			CAFile:   *caCert,
+		})
+		if err != nil {
+			mainLogger.Fatal("failed init tls", zap.Error(err))
+		}
+	}
+
+	client, err := initClient(ctx, *addr, tlsConfig)
 	if err != nil {
 		mainLogger.Fatal("failed init client", zap.Error(err))
 	}
 
+	var handshake netclient.Handshake
+	if *password != "" {
+		handshake = func(ctx context.Context, send func(context.Context, []byte) ([]byte, error)) error {
+			return authenticate(ctx, send, *password)
+		}
+		if err := handshake(ctx, client.Send); err != nil {
+			client.Close()
+			mainLogger.Fatal("failed auth", zap.Error(err))
+		}
+	}
+
+	if *reconnectAttempts > 0 {
+		client.WithReconnect(func(ctx context.Context) (net.Conn, error) {
+			return endpoint.Dial(ctx, *addr, tlsConfig)
+		}, netclient.Backoff{MaxAttempts: *reconnectAttempts}).
+			WithHandshake(handshake).
+			WithReconnectHook(func(event netclient.ReconnectEvent) {
+				logReconnect(mainLogger, event)
+			})
+	}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
@@ -40,11 +89,50 @@ func main() {
 	cli.Run(ctx, reader, client)
 }
 
-func initClient(addr string) (*client.TCPClient, error) {
-	conn, err := net.Dial("tcp", addr)
+func initClient(ctx context.Context, addr string, tlsConfig *tls.Config) (*netclient.TCPClient, error) {
+	conn, err := endpoint.Dial(ctx, addr, tlsConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.New(conn), nil
+	return netclient.New(conn), nil
+}
+
+func logReconnect(logger *zap.Logger, event netclient.ReconnectEvent) {
+	switch event.Kind {
+	case netclient.EventDisconnected:
+		logger.Warn("connection failed", zap.Error(event.Err))
+	case netclient.EventReconnectFailed:
+		logger.Warn("failed reconnect",
+			zap.Int("attempt", event.Attempt),
+			zap.Duration("retry_in", event.Delay),
+			zap.Error(event.Err),
+		)
+	case netclient.EventReconnected:
+		logger.Info("reconnected", zap.Int("attempt", event.Attempt))
+	}
+}
+
+var errAuthRejected = errors.New("auth rejected")
+
+// authenticate sends AUTH with send, it is also run as handshake after reconnect.
+func authenticate(ctx context.Context, send func(context.Context, []byte) ([]byte, error), password string) error {
+	raw, err := send(ctx, []byte("AUTH "+compute.Quote(password)))
+	if err != nil {
+		return err
+	}
+
+	response, err := netclient.ParseResponse(raw)
+	if _, ok := model.CodeOf(err); ok {
+		return fmt.Errorf("%w: %w", errAuthRejected, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if response != "ok" {
+		return fmt.Errorf("%w: %s", errAuthRejected, response)
+	}
+
+	return nil
 }
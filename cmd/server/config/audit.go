@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"kvdb/internal/audit"
+	"kvdb/internal/database"
+	"kvdb/internal/logging"
+	"os"
+
+	serverConfig "kvdb/internal/config/server"
+)
+
+// InitAudit starts audit log of database commands. Returned log is nil when
+// audit is disabled, otherwise it must be closed after servers stop.
+func InitAudit(conf *serverConfig.Config, db *database.Database) (*audit.Log, error) {
+	if !conf.Audit.Enabled {
+		return nil, nil
+	}
+
+	var w io.Writer = os.Stdout
+	if conf.Audit.Output != "stdout" {
+		file, err := logging.OpenRotatingFile(conf.Audit.Output, logging.RotationOptions{
+			MaxSize:    conf.Audit.Rotation.MaxSizeBytes,
+			MaxAge:     conf.Audit.Rotation.MaxAge,
+			MaxBackups: conf.Audit.Rotation.MaxBackups,
+			Compress:   conf.Audit.Rotation.Compress,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed open audit log: %w", err)
+		}
+		w = file
+	}
+
+	auditLog, err := audit.New(w, audit.Options{
+		BufferSize:   conf.Audit.BufferSize,
+		Policy:       audit.Policy(conf.Audit.FullPolicy),
+		RedactValues: conf.Audit.RedactValues,
+	})
+	if err != nil {
+		if closer, ok := w.(io.Closer); ok {
+			closer.Close()
+		}
+		return nil, err
+	}
+
+	db.WithAudit(auditLog)
+	return auditLog, nil
+}
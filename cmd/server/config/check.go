@@ -0,0 +1,60 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"kvdb/internal/security/acl"
+	"strings"
+
+	serverConfig "kvdb/internal/config/server"
+)
+
+// PrintConfig writes effective config, with defaults filled in and source of every
+// value, or every problem found by validation. It returns exit code of -check-config mode.
+func PrintConfig(w, errW io.Writer, conf *serverConfig.Config, sources serverConfig.Sources, err error) int {
+	if err != nil {
+		var validationErr *serverConfig.ValidationError
+		if !errors.As(err, &validationErr) {
+			fmt.Fprintf(errW, "config is invalid: %s\n", err)
+			return 1
+		}
+
+		fmt.Fprintf(errW, "config is invalid, %d problems:\n", len(validationErr.Problems))
+		for _, problem := range validationErr.Problems {
+			fmt.Fprintf(errW, "  %s\n", problem)
+		}
+		return 1
+	}
+
+	var buf bytes.Buffer
+	if err := serverConfig.EncodeWithSources(&buf, conf, sources); err != nil {
+		fmt.Fprintf(errW, "%s\n", err)
+		return 1
+	}
+
+	fmt.Fprintln(w, "# config is valid, effective values with their sources:")
+	_, _ = buf.WriteTo(w)
+	return 0
+}
+
+// PrintPasswordHash reads password from the first line of r and writes its hash
+// for security.passwords. It returns exit code of -hash-password mode.
+func PrintPasswordHash(r io.Reader, w, errW io.Writer) int {
+	password, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		fmt.Fprintf(errW, "failed read password: %s\n", err)
+		return 1
+	}
+
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		fmt.Fprintln(errW, "password is empty")
+		return 1
+	}
+
+	fmt.Fprintln(w, acl.HashPassword(password))
+	return 0
+}
@@ -1,81 +1,445 @@
 package config
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"kvdb/internal/compute"
 	"kvdb/internal/database"
+	"kvdb/internal/metrics"
+	"kvdb/internal/model"
+	"kvdb/internal/network/endpoint"
 	"kvdb/internal/network/server"
+	"kvdb/internal/network/tlsconf"
+	"kvdb/internal/network/websocket"
 	"kvdb/internal/rpc/query"
+	"kvdb/internal/rpc/rest"
+	"kvdb/internal/security/acl"
+	"kvdb/internal/security/auth"
 	"kvdb/internal/storage/inmemory"
+	"kvdb/internal/version"
 	"net"
+	"net/http"
 	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
+	humanize "github.com/dustin/go-humanize"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 
 	serverConfig "kvdb/internal/config/server"
 )
 
-func LoadConfig(configPath string) (*serverConfig.Config, error) {
+// LoadConfig loads config file with overrides from environment and flags. Without
+// config path only defaults and overrides are used.
+func LoadConfig(configPath string, overrides []serverConfig.Override) (*serverConfig.Config, serverConfig.Sources, error) {
+	if configPath == "" {
+		return serverConfig.LoadLayers(strings.NewReader(""), overrides)
+	}
+
 	f, err := os.Open(configPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed read file: %s: %w", configPath, err)
+		return nil, nil, fmt.Errorf("failed read file: %s: %w", configPath, err)
 	}
 	defer f.Close()
 
-	return serverConfig.LoadConfig(f)
+	return serverConfig.LoadLayers(f, overrides)
+}
+
+// InitACL loads users from acl file and sets configured passwords of default user.
+// Configured passwords win over passwords of default user saved in the file, so
+// changing security.passwords always takes effect. They are saved to the file.
+func InitACL(conf *serverConfig.Config) (*acl.ACL, error) {
+	accessControl := acl.New().WithFile(conf.Security.ACLFile)
+	if err := accessControl.Load(); err != nil {
+		return nil, err
+	}
+
+	if len(conf.Security.Passwords) > 0 {
+		rules := []string{"resetpass"}
+		for _, password := range conf.Security.Passwords {
+			// Plain hex is unsalted sha256 of configs written before pbkdf2.
+			if !strings.HasPrefix(password, "pbkdf2:") {
+				password = "sha256:" + password
+			}
+			rules = append(rules, password)
+		}
+
+		if err := accessControl.SetUser(acl.DefaultUser, rules); err != nil {
+			return nil, fmt.Errorf("invalid passwords: %w", err)
+		}
+	}
+
+	return accessControl, nil
+}
+
+func InitDatabase(conf *serverConfig.Config, logger *zap.Logger, accessControl *acl.ACL, registry *metrics.Registry) *database.Database {
+	compute := compute.New()
+	storage := inmemory.New().WithMetrics(registry)
+	return database.New(logger, compute, storage).
+		WithACL(accessControl).
+		WithMetrics(registry).
+		WithSlowLog(conf.SlowLog.Threshold, conf.SlowLog.MaxLen).
+		WithInfoSection(database.InfoSectionMemory, func() []database.InfoField {
+			return []database.InfoField{
+				{Key: "keys", Value: strconv.Itoa(storage.Len())},
+				{Key: "used_memory_estimate", Value: strconv.FormatInt(storage.MemoryUsage(), 10)},
+				{Key: "used_memory_estimate_human", Value: humanize.Bytes(uint64(storage.MemoryUsage()))},
+			}
+		})
+}
+
+// InitClients registers clients of every server in shared registry managed by CLIENT commands.
+func InitClients(db *database.Database, servers []*server.TCPServer) {
+	clients := server.NewClients()
+	for _, tcpServer := range servers {
+		tcpServer.WithClients(clients)
+	}
+	db.WithClients(clients)
 }
 
-func InitLogger(config *serverConfig.Config) (*zap.Logger, error) {
-	var level zapcore.Level
-	err := level.UnmarshalText([]byte(config.Logging.Level))
+// InitInfo adds server, clients and persistence sections to INFO output. conf is
+// changed by CONFIG SET and reload, so values are copied here or read from logging.
+func InitInfo(conf *serverConfig.Config, logging *Logging, db *database.Database, servers []*server.TCPServer) {
+	startedAt := time.Now()
+
+	// Changes of these values need restart.
+	engine := conf.Engine.Type
+	listeners := strconv.Itoa(len(conf.Network.EffectiveListeners()))
+	httpEnabled := formatBool(conf.HTTP.Enabled)
+	metricsEnabled := formatBool(conf.Metrics.Enabled)
+	aclFile := conf.Security.ACLFile
+
+	db.WithInfoSection(database.InfoSectionServer, func() []database.InfoField {
+		uptime := time.Since(startedAt)
+		return []database.InfoField{
+			{Key: "version", Value: version.Version},
+			{Key: "go_version", Value: runtime.Version()},
+			{Key: "process_id", Value: strconv.Itoa(os.Getpid())},
+			{Key: "uptime_in_seconds", Value: strconv.FormatInt(int64(uptime.Seconds()), 10)},
+			{Key: "uptime", Value: uptime.Truncate(time.Second).String()},
+			{Key: "engine", Value: engine},
+			{Key: "listeners", Value: listeners},
+			{Key: "http_enabled", Value: httpEnabled},
+			{Key: "metrics_enabled", Value: metricsEnabled},
+			{Key: "log_level", Value: logging.Level()},
+		}
+	})
+
+	db.WithInfoSection(database.InfoSectionClients, func() []database.InfoField {
+		var connected, maxClients int
+		var accepted, rejected uint64
+		unlimited := false
+
+		listenerFields := make([]database.InfoField, 0, len(servers))
+		for i, tcpServer := range servers {
+			stats := tcpServer.Stats()
+			connected += stats.Connections
+			maxClients += stats.MaxConnections
+			unlimited = unlimited || stats.MaxConnections <= 0
+			accepted += stats.Accepted
+			rejected += stats.Rejected
+
+			occupancy := 0.0
+			if stats.MaxConnections > 0 {
+				occupancy = float64(stats.Connections) / float64(stats.MaxConnections)
+			}
+
+			listenerFields = append(listenerFields, database.InfoField{
+				Key: fmt.Sprintf("listener%d", i),
+				Value: fmt.Sprintf(
+					"network=%s,addr=%s,connected=%d,max=%d,occupancy=%.2f",
+					tcpServer.Addr().Network(), tcpServer.Addr(), stats.Connections, stats.MaxConnections, occupancy,
+				),
+			})
+		}
+
+		maxClientsValue := strconv.Itoa(maxClients)
+		if unlimited {
+			maxClientsValue = "unlimited"
+		}
+
+		fields := []database.InfoField{
+			{Key: "connected_clients", Value: strconv.Itoa(connected)},
+			{Key: "max_clients", Value: maxClientsValue},
+			{Key: "total_connections_received", Value: strconv.FormatUint(accepted, 10)},
+			{Key: "rejected_connections", Value: strconv.FormatUint(rejected, 10)},
+		}
+		return append(fields, listenerFields...)
+	})
+
+	db.WithInfoSection(database.InfoSectionPersistence, func() []database.InfoField {
+		// Engine keeps data in memory only, acl file is the only persisted state.
+		return []database.InfoField{
+			{Key: "persistence_enabled", Value: formatBool(false)},
+			{Key: "acl_file", Value: aclFile},
+		}
+	})
+}
+
+func formatBool(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
+
+// InitMetricsServer creates server of metrics endpoint, nil if it is disabled.
+func InitMetricsServer(conf *serverConfig.Config, logger *zap.Logger, registry *metrics.Registry) (*rest.Server, error) {
+	if !conf.Metrics.Enabled {
+		return nil, nil
+	}
+	if !strings.HasPrefix(conf.Metrics.Path, "/") {
+		return nil, fmt.Errorf("%w: %s: want absolute path", errInvalidPath, conf.Metrics.Path)
+	}
+
+	listener, err := endpoint.Listen(conf.Metrics.Address, 0)
 	if err != nil {
-		return nil, fmt.Errorf("unexpected log level: %w", err)
+		return nil, err
 	}
 
-	var output zapcore.WriteSyncer
-	if config.Logging.Output == "" || config.Logging.Output == "stdout" {
-		output = os.Stdout
-	} else {
-		file, err := os.OpenFile(config.Logging.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	mux := http.NewServeMux()
+	mux.Handle("GET "+conf.Metrics.Path, registry)
+
+	return rest.NewServer(logger, listener, mux), nil
+}
+
+// InitAuthenticator creates authenticator shared by all listeners, so rate
+// limits are applied across them.
+func InitAuthenticator(conf *serverConfig.Config, accessControl *acl.ACL) *auth.Authenticator {
+	return auth.New(accessControl).
+		WithMaxFailures(conf.Security.MaxAuthFailures).
+		WithBlockDuration(conf.Security.AuthBlockDuration)
+}
+
+// InitServers creates server for every configured listener. All servers share
+// database and authenticator.
+func InitServers(
+	conf *serverConfig.Config,
+	logger *zap.Logger,
+	db *database.Database,
+	authenticator *auth.Authenticator,
+	registry *metrics.Registry,
+) ([]*server.TCPServer, error) {
+	listenerConfigs := conf.Network.EffectiveListeners()
+	servers := make([]*server.TCPServer, 0, len(listenerConfigs))
+	listeners := make([]net.Listener, 0, len(listenerConfigs))
+
+	for _, listenerConf := range listenerConfigs {
+		listenerLogger := logger.With(zap.String("listener", listenerConf.Name))
+
+		listener, tcpServer, err := initServer(listenerConf, listenerLogger, db, authenticator)
 		if err != nil {
-			return nil, fmt.Errorf("failed open log file: %w", err)
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("listener %s: %w", listenerConf.Name, err)
 		}
-		output = zapcore.AddSync(file)
+
+		listeners = append(listeners, listener)
+		servers = append(servers, tcpServer.WithMetrics(registry, listenerConf.Name))
+	}
+
+	return servers, nil
+}
+
+func initServer(
+	conf serverConfig.ListenerConfig,
+	logger *zap.Logger,
+	db *database.Database,
+	authenticator *auth.Authenticator,
+) (net.Listener, *server.TCPServer, error) {
+	if err := query.ValidateProtocol(conf.Protocol); err != nil {
+		return nil, nil, err
+	}
+
+	queryHandler, err := newQueryHandler(conf, logger, db, authenticator)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	encoderConfig := zap.NewProductionEncoderConfig()
-	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	listener, err := endpoint.Listen(conf.Address, conf.UnixSocketPermMode)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	core := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),
-		output,
-		level,
-	)
+	tcpServer := newServer(conf, logger, listener, queryHandler)
+	if conf.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(conf.TLS)
+		if err != nil {
+			listener.Close()
+			return nil, nil, err
+		}
+		tcpServer.WithTLSConfig(tlsConfig)
+	}
 
-	logger := zap.New(core)
-	return logger, nil
+	return listener, tcpServer, nil
 }
 
-func InitDatabase(logger *zap.Logger) *database.Database {
-	compute := compute.New()
-	storage := inmemory.New()
-	return database.New(logger, compute, storage)
+// newTLSConfig loads certificates of listener.
+func newTLSConfig(conf serverConfig.TLSConfig) (*tls.Config, error) {
+	tlsConfig, err := tlsconf.ServerConfig(tlsconf.Options{
+		CertFile:          conf.CertFile,
+		KeyFile:           conf.KeyFile,
+		CAFile:            conf.CAFile,
+		MinVersion:        conf.MinVersion,
+		RequireClientCert: conf.RequireClientCert,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed init tls: %w", err)
+	}
+
+	return tlsConfig, nil
 }
 
-func InitServer(conf *serverConfig.Config, logger *zap.Logger, db *database.Database) (*server.TCPServer, error) {
-	listener, err := net.Listen("tcp", conf.Network.Address)
+func newQueryHandler(
+	conf serverConfig.ListenerConfig,
+	logger *zap.Logger,
+	db *database.Database,
+	authenticator *auth.Authenticator,
+) (*query.Handler, error) {
+	allowedCategories, err := parseCategories(conf.AllowedCategories)
 	if err != nil {
 		return nil, err
 	}
 
-	queryHandler := query.New(db, logger)
+	queryHandler := query.New(db, logger).
+		WithAuthenticator(authenticator).
+		WithProtocol(conf.Protocol).
+		WithMaxMessageSize(conf.MaxMessageSizeBytes).
+		WithAllowedCategories(allowedCategories).
+		WithMonitor(db)
 
-	tcpServer := server.New(logger, listener).
-		WithMaxConn(conf.Network.MaxConnections).
-		WithMaxMessageSize(conf.Network.MaxMessageSizeBytes).
-		WithIdleTimeout(conf.Network.IdleTimeout).
+	return queryHandler, nil
+}
+
+func newServer(
+	conf serverConfig.ListenerConfig,
+	logger *zap.Logger,
+	listener net.Listener,
+	queryHandler *query.Handler,
+) *server.TCPServer {
+	return server.New(logger, listener).
+		WithMaxConn(conf.MaxConnections).
+		WithMaxMessageSize(conf.MaxMessageSizeBytes).
+		WithIdleTimeout(conf.IdleTimeout).
 		WithQueryHandleFunc(queryHandler.Handle)
+}
+
+// InitHTTPServer creates JSON gateway server, nil if it is disabled. When
+// websocket endpoint is enabled, it also returns server of websocket connections.
+func InitHTTPServer(
+	conf *serverConfig.Config,
+	logger *zap.Logger,
+	db *database.Database,
+	authenticator *auth.Authenticator,
+	registry *metrics.Registry,
+) (*rest.Server, *server.TCPServer, error) {
+	if !conf.HTTP.Enabled {
+		return nil, nil, nil
+	}
+
+	listener, err := endpoint.Listen(conf.HTTP.Address, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	allowedCategories, err := parseCategories(conf.HTTP.AllowedCategories)
+	if err != nil {
+		listener.Close()
+		return nil, nil, err
+	}
+
+	restHandler := rest.New(db, logger).
+		WithAuthenticator(authenticator).
+		WithMaxBodySize(int64(conf.HTTP.MaxBodySizeBytes)).
+		WithAllowedCategories(allowedCategories)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", restHandler)
+
+	var wsServer *server.TCPServer
+	if conf.HTTP.WebSocket.Enabled {
+		wsServer, err = initWebSocketServer(conf, logger, db, authenticator, listener.Addr(), mux)
+		if err != nil {
+			listener.Close()
+			return nil, nil, fmt.Errorf("websocket: %w", err)
+		}
+		wsServer.WithMetrics(registry, "websocket")
+	}
+
+	httpServer := rest.NewServer(logger, listener, mux).
+		WithReadTimeout(conf.HTTP.ReadTimeout).
+		WithWriteTimeout(conf.HTTP.WriteTimeout)
+
+	return httpServer, wsServer, nil
+}
+
+// initWebSocketServer serves websocket connections with settings of configured
+// listener. Queries are text messages, SUBSCRIBE streams key changes.
+func initWebSocketServer(
+	conf *serverConfig.Config,
+	logger *zap.Logger,
+	db *database.Database,
+	authenticator *auth.Authenticator,
+	addr net.Addr,
+	mux *http.ServeMux,
+) (*server.TCPServer, error) {
+	listenerConf, err := findListener(conf, conf.HTTP.WebSocket.Listener)
+	if err != nil {
+		return nil, err
+	}
+	listenerConf.Protocol = query.ProtocolText
+
+	queryHandler, err := newQueryHandler(listenerConf, logger, db, authenticator)
+	if err != nil {
+		return nil, err
+	}
+	queryHandler.WithSubscriber(db)
+
+	wsListener := websocket.NewListener(addr, listenerConf.MaxMessageSizeBytes).
+		WithAllowedOrigins(conf.HTTP.WebSocket.AllowedOrigins)
+	mux.Handle("GET "+webSocketPath, wsListener)
+
+	wsLogger := logger.With(zap.String("websocket_listener", listenerConf.Name))
+	return newServer(listenerConf, wsLogger, wsListener, queryHandler), nil
+}
+
+const webSocketPath = "/v1/ws"
+
+var (
+	errUnknownListener = errors.New("unknown listener")
+	errInvalidPath     = errors.New("invalid path")
+)
+
+func findListener(conf *serverConfig.Config, name string) (serverConfig.ListenerConfig, error) {
+	listeners := conf.Network.EffectiveListeners()
+	if name == "" {
+		return listeners[0], nil
+	}
+
+	for _, listener := range listeners {
+		if listener.Name == name {
+			return listener, nil
+		}
+	}
+
+	return serverConfig.ListenerConfig{}, fmt.Errorf("%w: %s", errUnknownListener, name)
+}
+
+var errUnknownCategory = errors.New("unknown command category")
+
+func parseCategories(names []string) (model.Category, error) {
+	categories := model.CategoryNone
+	for _, name := range names {
+		category, ok := model.ParseCategory(name)
+		if !ok {
+			return model.CategoryNone, fmt.Errorf("%w: %s", errUnknownCategory, name)
+		}
+		categories |= category
+	}
 
-	return tcpServer, nil
+	return categories, nil
 }
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"kvdb/internal/config/dynamic"
+	"kvdb/internal/database"
+	"kvdb/internal/network/server"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	serverConfig "kvdb/internal/config/server"
+)
+
+// InitDynamicConfig enables CONFIG command for parameters which can be changed
+// while server is running. Network parameters are applied to every listener, they
+// are refused when network.listeners set own values, which the top level value
+// doesn't change in config file.
+func InitDynamicConfig(
+	conf *serverConfig.Config,
+	path string,
+	logging *Logging,
+	db *database.Database,
+	servers []*server.TCPServer,
+) *dynamic.Config {
+	config := dynamic.New(path).
+		Register("logging.level", logging.Level, func(value string) error {
+			level, err := zapcore.ParseLevel(value)
+			if err != nil {
+				return err
+			}
+
+			logging.SetLevel(level)
+			for i := range conf.Logging.Sinks {
+				if conf.Logging.Sinks[i].Level == conf.Logging.Level {
+					conf.Logging.Sinks[i].Level = level.String()
+				}
+			}
+			conf.Logging.Level = level.String()
+			return nil
+		}).
+		Register("network.idle_timeout", func() string {
+			return conf.Network.IdleTimeout.String()
+		}, func(value string) error {
+			idleTimeout, err := parsePositiveDuration(value)
+			if err != nil {
+				return err
+			}
+			for _, listener := range conf.Network.Listeners {
+				if listener.IdleTimeout != conf.Network.IdleTimeout {
+					return errListenerOverride(listener.Name, "idle_timeout")
+				}
+			}
+
+			for _, tcpServer := range servers {
+				tcpServer.SetIdleTimeout(idleTimeout)
+			}
+			conf.Network.IdleTimeout = idleTimeout
+			for i := range conf.Network.Listeners {
+				conf.Network.Listeners[i].IdleTimeout = idleTimeout
+			}
+			return nil
+		}).
+		Register("network.max_connections", func() string {
+			return strconv.Itoa(conf.Network.MaxConnections)
+		}, func(value string) error {
+			maxConn, err := strconv.Atoi(value)
+			if err != nil || maxConn < 0 {
+				return errors.New("want non-negative integer, 0 means unlimited")
+			}
+			for _, listener := range conf.Network.Listeners {
+				if listener.MaxConnections != conf.Network.MaxConnections {
+					return errListenerOverride(listener.Name, "max_connections")
+				}
+			}
+
+			for _, tcpServer := range servers {
+				tcpServer.SetMaxConn(maxConn)
+			}
+			conf.Network.MaxConnections = maxConn
+			for i := range conf.Network.Listeners {
+				conf.Network.Listeners[i].MaxConnections = maxConn
+			}
+			return nil
+		}).
+		Register("slowlog.threshold", func() string {
+			return db.SlowLogThreshold().String()
+		}, func(value string) error {
+			threshold, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+
+			db.SetSlowLogThreshold(threshold)
+			conf.SlowLog.Threshold = threshold
+			return nil
+		})
+
+	db.WithConfig(config)
+	return config
+}
+
+// errListenerOverride is returned when the top level network value can't be set,
+// because a listener sets its own value.
+func errListenerOverride(listener, field string) error {
+	return fmt.Errorf("listener %q sets own %s, change it in config file and reload", listener, field)
+}
+
+func parsePositiveDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, errors.New("want positive duration")
+	}
+	return d, nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"kvdb/internal/database"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestInitDynamicConfig_LoggingLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`logging:
+  level: info
+  sinks:
+    - output: stdout
+    - output: stderr
+      level: error
+`), 0o600))
+
+	conf, _, err := LoadConfig(path, nil)
+	require.NoError(t, err)
+	_, logging, err := InitLogger(conf)
+	require.NoError(t, err)
+
+	config := InitDynamicConfig(conf, path, logging, database.New(zap.NewNop(), nil, nil), nil)
+	require.NoError(t, config.Set("logging.level", "debug"))
+	assert.Equal(t, zapcore.DebugLevel, logging.sinks[0].level.Level())
+	assert.Equal(t, zapcore.ErrorLevel, logging.sinks[1].level.Level())
+
+	require.NoError(t, config.Rewrite())
+
+	rewritten, _, err := LoadConfig(path, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "debug", rewritten.Logging.Level)
+	assert.Equal(t, conf.Logging.EffectiveSinks(), rewritten.Logging.EffectiveSinks())
+	assert.Equal(t, "debug", rewritten.Logging.Sinks[0].Level)
+	assert.Equal(t, "error", rewritten.Logging.Sinks[1].Level)
+}
+
+func TestInitDynamicConfig_NetworkListeners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`network:
+  max_connections: 100
+  listeners:
+    - name: public
+      address: 127.0.0.1:0
+    - name: admin
+      address: 127.0.0.1:0
+      max_connections: 10
+`), 0o600))
+
+	conf, _, err := LoadConfig(path, nil)
+	require.NoError(t, err)
+	_, logging, err := InitLogger(conf)
+	require.NoError(t, err)
+
+	config := InitDynamicConfig(conf, path, logging, database.New(zap.NewNop(), nil, nil), nil)
+	err = config.Set("network.max_connections", "50")
+	assert.ErrorContains(t, err, `listener "admin" sets own max_connections`)
+	assert.Equal(t, 100, conf.Network.MaxConnections)
+
+	require.NoError(t, config.Set("network.idle_timeout", "1m"))
+	for _, listener := range conf.Network.Listeners {
+		assert.Equal(t, conf.Network.IdleTimeout, listener.IdleTimeout)
+	}
+}
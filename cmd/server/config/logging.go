@@ -0,0 +1,210 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"kvdb/internal/logging"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	serverConfig "kvdb/internal/config/server"
+)
+
+var errSinksChanged = errors.New("number of log sinks changed")
+
+// Logging changes levels and outputs of log sinks while server is running.
+type Logging struct {
+	level zap.AtomicLevel // Top level value, sinks may have their own levels.
+	sinks []*logSink
+}
+
+type logSink struct {
+	level  zap.AtomicLevel
+	output *logOutput
+}
+
+// InitLogger creates logger writing to every configured sink. Levels and outputs
+// of sinks can be changed at runtime.
+func InitLogger(config *serverConfig.Config) (*zap.Logger, *Logging, error) {
+	level, err := zap.ParseAtomicLevel(config.Logging.Level)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unexpected log level: %w", err)
+	}
+
+	sinkConfigs := config.Logging.EffectiveSinks()
+	logging := &Logging{level: level, sinks: make([]*logSink, 0, len(sinkConfigs))}
+	cores := make([]zapcore.Core, 0, len(sinkConfigs))
+
+	for _, sinkConf := range sinkConfigs {
+		sinkLevel, err := zap.ParseAtomicLevel(sinkConf.Level)
+		if err != nil {
+			logging.close()
+			return nil, nil, fmt.Errorf("unexpected log level: %w", err)
+		}
+
+		w, closer, err := openLogOutput(sinkConf)
+		if err != nil {
+			logging.close()
+			return nil, nil, err
+		}
+
+		sink := &logSink{level: sinkLevel, output: &logOutput{w: w, closer: closer}}
+		logging.sinks = append(logging.sinks, sink)
+		cores = append(cores, zapcore.NewCore(newLogEncoder(sinkConf.Encoding), sink.output, sink.level))
+	}
+
+	logger := zap.New(zapcore.NewTee(cores...))
+	return logger, logging, nil
+}
+
+// Level returns top level log level.
+func (l *Logging) Level() string {
+	return l.level.String()
+}
+
+// SetLevel changes top level and level of sinks which inherit it. Sinks with a
+// different level keep it, as it is set in config file.
+func (l *Logging) SetLevel(level zapcore.Level) {
+	prev := l.level.Level()
+	l.level.SetLevel(level)
+	for _, sink := range l.sinks {
+		if sink.level.Level() == prev {
+			sink.level.SetLevel(level)
+		}
+	}
+}
+
+// Reload applies levels, outputs and rotation of sinks. Outputs are reopened
+// even if unchanged, so log files moved by external tools are recreated. Nothing
+// is changed if any output fails to open or number of sinks differs.
+func (l *Logging) Reload(conf serverConfig.LoggingConfig) error {
+	sinkConfigs := conf.EffectiveSinks()
+	if len(sinkConfigs) != len(l.sinks) {
+		return errSinksChanged
+	}
+
+	level, err := zapcore.ParseLevel(conf.Level)
+	if err != nil {
+		return fmt.Errorf("unexpected log level: %w", err)
+	}
+
+	levels := make([]zapcore.Level, 0, len(sinkConfigs))
+	for _, sinkConf := range sinkConfigs {
+		sinkLevel, err := zapcore.ParseLevel(sinkConf.Level)
+		if err != nil {
+			return fmt.Errorf("unexpected log level: %w", err)
+		}
+		levels = append(levels, sinkLevel)
+	}
+
+	type output struct {
+		w      zapcore.WriteSyncer
+		closer io.Closer
+	}
+
+	outputs := make([]output, 0, len(sinkConfigs))
+	for _, sinkConf := range sinkConfigs {
+		w, closer, err := openLogOutput(sinkConf)
+		if err != nil {
+			for _, o := range outputs {
+				if o.closer != nil {
+					o.closer.Close()
+				}
+			}
+			return err
+		}
+		outputs = append(outputs, output{w: w, closer: closer})
+	}
+
+	l.level.SetLevel(level)
+	for i, sink := range l.sinks {
+		sink.level.SetLevel(levels[i])
+		sink.output.set(outputs[i].w, outputs[i].closer)
+	}
+
+	return nil
+}
+
+func (l *Logging) close() {
+	for _, sink := range l.sinks {
+		sink.output.close()
+	}
+}
+
+func newLogEncoder(encoding string) zapcore.Encoder {
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	if encoding == "console" {
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		return zapcore.NewConsoleEncoder(encoderConfig)
+	}
+	return zapcore.NewJSONEncoder(encoderConfig)
+}
+
+// openLogOutput opens stdout, stderr or log file. Closer is nil for standard streams.
+func openLogOutput(conf serverConfig.LogSinkConfig) (zapcore.WriteSyncer, io.Closer, error) {
+	switch conf.Output {
+	case "", "stdout":
+		return os.Stdout, nil, nil
+	case "stderr":
+		return os.Stderr, nil, nil
+	}
+
+	file, err := logging.OpenRotatingFile(conf.Output, logging.RotationOptions{
+		MaxSize:    conf.Rotation.MaxSizeBytes,
+		MaxAge:     conf.Rotation.MaxAge,
+		MaxBackups: conf.Rotation.MaxBackups,
+		Compress:   conf.Rotation.Compress,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, file, nil
+}
+
+// logOutput is a log sink which output can be replaced.
+type logOutput struct {
+	mu     sync.Mutex
+	w      zapcore.WriteSyncer
+	closer io.Closer // Nil for standard streams.
+}
+
+func (o *logOutput) set(w zapcore.WriteSyncer, closer io.Closer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closer != nil {
+		o.closer.Close()
+	}
+	o.w, o.closer = w, closer
+}
+
+func (o *logOutput) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closer != nil {
+		o.closer.Close()
+		o.closer = nil
+	}
+}
+
+func (o *logOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.w.Write(p)
+}
+
+func (o *logOutput) Sync() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.w.Sync()
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"kvdb/internal/config/dynamic"
+	"kvdb/internal/database"
+	"kvdb/internal/network/server"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+
+	serverConfig "kvdb/internal/config/server"
+)
+
+// reloadable are YAML paths of values applied by Reload. Indexes of listeners and sinks
+// are stripped, e.g. network.listeners[1].idle_timeout matches network.listeners.idle_timeout.
+var reloadable = []string{
+	"logging.level",
+	"logging.output",
+	"logging.rotation.max_size",
+	"logging.rotation.max_age",
+	"logging.rotation.max_backups",
+	"logging.rotation.compress",
+	"logging.sinks.level",
+	"logging.sinks.output",
+	"logging.sinks.rotation.max_size",
+	"logging.sinks.rotation.max_age",
+	"logging.sinks.rotation.max_backups",
+	"logging.sinks.rotation.compress",
+	"network.max_connections",
+	"network.idle_timeout",
+	"network.tls.cert_file",
+	"network.tls.key_file",
+	"network.tls.ca_file",
+	"network.tls.min_version",
+	"network.tls.require_client_cert",
+	"network.listeners.max_connections",
+	"network.listeners.idle_timeout",
+	"network.listeners.tls.cert_file",
+	"network.listeners.tls.key_file",
+	"network.listeners.tls.ca_file",
+	"network.listeners.tls.min_version",
+	"network.listeners.tls.require_client_cert",
+	"slowlog.threshold",
+}
+
+var indexPattern = regexp.MustCompile(`\[\d+\]`)
+
+// Reloader applies config file to running server without dropping connections.
+// Values which need new listeners or handlers are left as is until restart.
+type Reloader struct {
+	path      string
+	overrides []serverConfig.Override // Environment and flags are applied on every reload.
+	conf      *serverConfig.Config
+	logger    *zap.Logger
+	logging   *Logging
+	db        *database.Database
+	config    *dynamic.Config
+	servers   []*server.TCPServer // Servers of conf.Network.EffectiveListeners() in the same order.
+	ws        *server.TCPServer   // Server of websocket connections, nil when disabled.
+}
+
+func NewReloader(
+	path string,
+	overrides []serverConfig.Override,
+	conf *serverConfig.Config,
+	logger *zap.Logger,
+	logging *Logging,
+	db *database.Database,
+	config *dynamic.Config,
+	servers []*server.TCPServer,
+	ws *server.TCPServer,
+) *Reloader {
+	return &Reloader{
+		path:      path,
+		overrides: overrides,
+		conf:      conf,
+		logger:    logger,
+		logging:   logging,
+		db:        db,
+		config:    config,
+		servers:   servers,
+		ws:        ws,
+	}
+}
+
+// Reload reads and validates config file and applies changed values. Invalid
+// config is not applied at all. Certificates are always reread, so renewed
+// files can be picked up with the same paths, as well as log file moved by logrotate.
+func (r *Reloader) Reload() error {
+	conf, _, err := LoadConfig(r.path, r.overrides)
+	if err != nil {
+		return err
+	}
+
+	changed := serverConfig.Diff(r.conf, conf)
+
+	// Listeners can be matched only when their number is the same.
+	listeners := conf.Network.EffectiveListeners()
+	sameListeners := len(listeners) == len(r.conf.Network.EffectiveListeners())
+
+	tlsConfigs := make([]*tls.Config, len(r.servers))
+	if sameListeners {
+		for i, listener := range listeners {
+			if !listener.TLS.Enabled || !r.conf.Network.EffectiveListeners()[i].TLS.Enabled {
+				continue
+			}
+
+			tlsConfig, err := newTLSConfig(listener.TLS)
+			if err != nil {
+				return fmt.Errorf("listener %s: %w", listener.Name, err)
+			}
+			tlsConfigs[i] = tlsConfig
+		}
+	}
+
+	sameSinks := len(conf.Logging.EffectiveSinks()) == len(r.conf.Logging.EffectiveSinks())
+	if sameSinks {
+		if err := r.logging.Reload(conf.Logging); err != nil {
+			return err
+		}
+	}
+
+	r.config.Update(func() {
+		if sameSinks {
+			applySink(&r.conf.Logging.LogSinkConfig, conf.Logging.LogSinkConfig)
+			for i := range r.conf.Logging.Sinks {
+				applySink(&r.conf.Logging.Sinks[i], conf.Logging.Sinks[i])
+			}
+		}
+
+		r.db.SetSlowLogThreshold(conf.SlowLog.Threshold)
+		r.conf.SlowLog.Threshold = conf.SlowLog.Threshold
+
+		if !sameListeners {
+			return
+		}
+
+		applyListener(&r.conf.Network.ListenerConfig, conf.Network.ListenerConfig)
+		for i := range r.conf.Network.Listeners {
+			applyListener(&r.conf.Network.Listeners[i], conf.Network.Listeners[i])
+		}
+
+		for i, listener := range r.conf.Network.EffectiveListeners() {
+			r.servers[i].SetMaxConn(listener.MaxConnections)
+			r.servers[i].SetIdleTimeout(listener.IdleTimeout)
+			if tlsConfigs[i] != nil {
+				_ = r.servers[i].SetTLSConfig(tlsConfigs[i])
+			}
+		}
+
+		if r.ws != nil {
+			if listener, err := findListener(r.conf, r.conf.HTTP.WebSocket.Listener); err == nil {
+				r.ws.SetMaxConn(listener.MaxConnections)
+				r.ws.SetIdleTimeout(listener.IdleTimeout)
+			}
+		}
+	})
+
+	applied, ignored := make([]string, 0), make([]string, 0)
+	for _, path := range changed {
+		applies := slices.Contains(reloadable, indexPattern.ReplaceAllString(path, ""))
+		switch {
+		case strings.HasPrefix(path, "network."):
+			applies = applies && sameListeners
+		case strings.HasPrefix(path, "logging."):
+			applies = applies && sameSinks
+		}
+
+		if applies {
+			applied = append(applied, path)
+		} else {
+			ignored = append(ignored, path)
+		}
+	}
+
+	r.logger.Info("config reloaded", zap.Strings("changed", applied))
+	if len(ignored) != 0 {
+		r.logger.Warn(
+			"config values require restart and were not applied",
+			zap.String("values", strings.Join(ignored, ", ")),
+		)
+	}
+
+	return nil
+}
+
+// applySink copies reloadable values of log sink.
+func applySink(dst *serverConfig.LogSinkConfig, src serverConfig.LogSinkConfig) {
+	dst.Level = src.Level
+	dst.Output = src.Output
+	dst.Rotation = src.Rotation
+}
+
+// applyListener copies reloadable values of listener.
+func applyListener(dst *serverConfig.ListenerConfig, src serverConfig.ListenerConfig) {
+	dst.MaxConnections = src.MaxConnections
+	dst.IdleTimeout = src.IdleTimeout
+	if dst.TLS.Enabled && src.TLS.Enabled {
+		dst.TLS = src.TLS
+	}
+}
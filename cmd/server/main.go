@@ -4,36 +4,96 @@ import (
 	"context"
 	"flag"
 	"kvdb/cmd/server/config"
+	"kvdb/internal/metrics"
+	"kvdb/internal/rpc/rest"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
 	"go.uber.org/zap"
+
+	serverConfig "kvdb/internal/config/server"
 )
 
 func main() {
-	configPath := flag.String("config", "etc/server.yaml", "config path")
+	configPath := flag.String("config", "etc/server.yaml", "config path, empty to use only defaults, environment and flags")
+	checkConfig := flag.Bool("check-config", false, "validate config, print effective values and exit")
+	hashPassword := flag.Bool("hash-password", false, "read password from stdin, print its hash for security.passwords and exit")
+	flagOverrides := serverConfig.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
+	if *hashPassword {
+		os.Exit(config.PrintPasswordHash(os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	mainLogger := zap.NewExample()
 
-	conf, err := config.LoadConfig(*configPath)
+	// Flags are applied after environment, so they win.
+	overrides, ignoredEnv, err := serverConfig.EnvOverrides(os.Environ())
+	overrides = append(overrides, flagOverrides()...)
+	if len(ignoredEnv) != 0 {
+		mainLogger.Warn("ignored environment variables which are not config values", zap.Strings("names", ignoredEnv))
+	}
+
+	var (
+		conf    *serverConfig.Config
+		sources serverConfig.Sources
+	)
+	if err == nil {
+		conf, sources, err = config.LoadConfig(*configPath, overrides)
+	}
+	if *checkConfig {
+		os.Exit(config.PrintConfig(os.Stdout, os.Stderr, conf, sources, err))
+	}
 	if err != nil {
 		mainLogger.Fatal("failed load config", zap.Error(err))
 	}
 
-	logger, err := config.InitLogger(conf)
+	logger, logging, err := config.InitLogger(conf)
 	if err != nil {
 		mainLogger.Fatal("failed init logger", zap.Error(err))
 	}
 
-	db := config.InitDatabase(logger)
+	accessControl, err := config.InitACL(conf)
+	if err != nil {
+		mainLogger.Fatal("failed init acl", zap.Error(err))
+	}
 
-	tcpServer, err := config.InitServer(conf, logger, db)
+	registry := metrics.NewRegistry()
+	db := config.InitDatabase(conf, logger, accessControl, registry)
+
+	auditLog, err := config.InitAudit(conf, db)
+	if err != nil {
+		mainLogger.Fatal("failed init audit log", zap.Error(err))
+	}
+
+	authenticator := config.InitAuthenticator(conf, accessControl)
+
+	servers, err := config.InitServers(conf, logger, db, authenticator, registry)
 	if err != nil {
 		mainLogger.Fatal("failed init server", zap.Error(err))
 	}
 
+	httpServer, wsServer, err := config.InitHTTPServer(conf, logger.With(zap.String("listener", "http")), db, authenticator, registry)
+	if err != nil {
+		mainLogger.Fatal("failed init http server", zap.Error(err))
+	}
+	tcpServers := servers
+	if wsServer != nil {
+		servers = append(servers, wsServer)
+	}
+
+	config.InitClients(db, servers)
+	config.InitInfo(conf, logging, db, servers)
+	dynamicConfig := config.InitDynamicConfig(conf, *configPath, logging, db, servers)
+	reloader := config.NewReloader(*configPath, overrides, conf, logger, logging, db, dynamicConfig, tcpServers, wsServer)
+
+	metricsServer, err := config.InitMetricsServer(conf, logger.With(zap.String("listener", "metrics")), registry)
+	if err != nil {
+		mainLogger.Fatal("failed init metrics server", zap.Error(err))
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -45,5 +105,53 @@ func main() {
 		cancel()
 	}()
 
-	tcpServer.Listen(ctx)
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reloadChan:
+				if err := reloader.Reload(); err != nil {
+					logger.Error("failed reload config", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	// All listeners share shutdown context and stop together.
+	wg := sync.WaitGroup{}
+	for _, tcpServer := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tcpServer.Listen(ctx)
+		}()
+	}
+
+	for _, httpServer := range []*rest.Server{httpServer, metricsServer} {
+		if httpServer == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			httpServer.Listen(ctx)
+		}()
+	}
+
+	wg.Wait()
+
+	// Commands are not accepted anymore, buffered audit entries can be flushed.
+	if auditLog != nil {
+		if err := auditLog.Close(); err != nil {
+			logger.Error("failed close audit log", zap.Error(err))
+		}
+		if dropped := auditLog.Dropped(); dropped > 0 {
+			logger.Warn("audit log entries dropped", zap.Uint64("dropped", dropped))
+		}
+	}
 }
@@ -0,0 +1,184 @@
+package kvdb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kvdb"
+	"log"
+)
+
+func ExampleOpen() {
+	db, err := kvdb.Open(kvdb.Options{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := db.Set(ctx, "greeting", "hello"); err != nil {
+		log.Fatal(err)
+	}
+
+	value, ok, err := db.Get(ctx, "greeting")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(value, ok)
+	// Output: hello true
+}
+
+func ExampleDB_Get() {
+	db, _ := kvdb.Open(kvdb.Options{})
+	defer db.Close()
+
+	ctx := context.Background()
+	_ = db.Set(ctx, "key", "value")
+	_ = db.Delete(ctx, "key")
+
+	_, ok, err := db.Get(ctx, "key")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(ok)
+	// Output: false
+}
+
+func ExampleDB_Scan() {
+	db, _ := kvdb.Open(kvdb.Options{})
+	defer db.Close()
+
+	ctx := context.Background()
+	_ = db.Set(ctx, "user:2", "bob")
+	_ = db.Set(ctx, "user:1", "alice")
+	_ = db.Set(ctx, "order:1", "book")
+
+	err := db.Scan(ctx, "user:*", func(key, value string) error {
+		fmt.Println(key, value)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// user:1 alice
+	// user:2 bob
+}
+
+func ExampleDB_Write() {
+	db, _ := kvdb.Open(kvdb.Options{})
+	defer db.Close()
+
+	ctx := context.Background()
+	_ = db.Set(ctx, "stale", "value")
+
+	batch := kvdb.NewBatch().
+		Set("a", "1").
+		Set("b", "2").
+		Delete("stale")
+	if err := db.Write(ctx, batch); err != nil {
+		log.Fatal(err)
+	}
+
+	_ = db.Scan(ctx, "*", func(key, value string) error {
+		fmt.Println(key, value)
+		return nil
+	})
+	// Output:
+	// a 1
+	// b 2
+}
+
+func ExampleDB_Update() {
+	db, _ := kvdb.Open(kvdb.Options{})
+	defer db.Close()
+
+	ctx := context.Background()
+	_ = db.Set(ctx, "from", "10")
+
+	// Move value between keys, other writers never see both or none of them.
+	err := db.Update(ctx, func(tx *kvdb.Tx) error {
+		value, ok, err := tx.Get("from")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("nothing to move")
+		}
+		if err := tx.Delete("from"); err != nil {
+			return err
+		}
+		return tx.Set("to", value)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	value, _, _ := db.Get(ctx, "to")
+	fmt.Println(value)
+	// Output: 10
+}
+
+func ExampleDB_View() {
+	db, _ := kvdb.Open(kvdb.Options{})
+	defer db.Close()
+
+	ctx := context.Background()
+	_ = db.Set(ctx, "first", "John")
+	_ = db.Set(ctx, "last", "Smith")
+
+	err := db.View(ctx, func(tx *kvdb.Tx) error {
+		first, _, err := tx.Get("first")
+		if err != nil {
+			return err
+		}
+		last, _, err := tx.Get("last")
+		if err != nil {
+			return err
+		}
+		fmt.Println(first, last)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output: John Smith
+}
+
+func ExampleTx() {
+	db, _ := kvdb.Open(kvdb.Options{})
+	defer db.Close()
+
+	ctx := context.Background()
+	_ = db.Set(ctx, "stock", "3")
+
+	// Every read and write of transaction goes through tx. Calling db.Get or
+	// db.Set here would wait for the transaction to end and deadlock, with
+	// tx.Context() they return ErrInTransaction.
+	err := db.Update(ctx, func(tx *kvdb.Tx) error {
+		stock, _, err := tx.Get("stock")
+		if err != nil {
+			return err
+		}
+		if stock == "0" {
+			return errors.New("out of stock")
+		}
+		if err := tx.Set("stock", "2"); err != nil {
+			return err
+		}
+
+		// Transaction reads its own writes before they are applied.
+		stock, _, err = tx.Get("stock")
+		if err != nil {
+			return err
+		}
+		return tx.Set("reserved", "1, stock left "+stock)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	reserved, _, _ := db.Get(ctx, "reserved")
+	fmt.Println(reserved)
+	// Output: 1, stock left 2
+}
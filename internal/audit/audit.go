@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Policy tells what happens to entry recorded when buffer is full.
+type Policy string
+
+const (
+	PolicyBlock      Policy = "block"       // Wait until writer catches up, slowing down commands.
+	PolicyDropNewest Policy = "drop_newest" // Drop recorded entry.
+	PolicyDropOldest Policy = "drop_oldest" // Drop the oldest buffered entry to make room.
+)
+
+// Policies lists supported policies.
+var Policies = []Policy{PolicyBlock, PolicyDropNewest, PolicyDropOldest}
+
+const (
+	DefaultBufferSize = 1024
+
+	valueRedacted = "(redacted)"
+)
+
+var ErrUnknownPolicy = errors.New("unknown policy")
+
+// Entry is a record of command which changes data or server state.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	ClientID  uint64    `json:"client_id,omitempty"`
+	CommandID uint64    `json:"command_id,omitempty"`
+	TraceID   string    `json:"trace_id,omitempty"` // Provided by client.
+	Addr      string    `json:"addr,omitempty"`
+	User      string    `json:"user"`
+	Identity  string    `json:"identity,omitempty"` // Subject of TLS client certificate.
+	Command   string    `json:"command"`
+	Key       string    `json:"key,omitempty"`
+	Value     string    `json:"value,omitempty"`
+	Args      []string  `json:"args,omitempty"` // Arguments of commands without key.
+	Error     string    `json:"error,omitempty"`
+}
+
+type Options struct {
+	BufferSize   int    // Number of entries waiting to be written, DefaultBufferSize if not positive.
+	Policy       Policy // PolicyBlock if empty.
+	RedactValues bool   // Replace values of keys with placeholder.
+}
+
+// Log writes entries as JSON lines in background, so commands don't wait for
+// disk. Entries are kept in order they were recorded.
+type Log struct {
+	w    io.Writer
+	opts Options
+
+	mu      sync.RWMutex // Guards closed against recording to closed channel.
+	closed  bool
+	entries chan Entry
+	done    chan struct{}
+
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+// New starts writing entries to w. Log must be closed to flush buffered entries.
+func New(w io.Writer, opts Options) (*Log, error) {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	if opts.Policy == "" {
+		opts.Policy = PolicyBlock
+	}
+	switch opts.Policy {
+	case PolicyBlock, PolicyDropNewest, PolicyDropOldest:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPolicy, opts.Policy)
+	}
+
+	l := &Log{
+		w:       w,
+		opts:    opts,
+		entries: make(chan Entry, opts.BufferSize),
+		done:    make(chan struct{}),
+	}
+	go l.run()
+
+	return l, nil
+}
+
+// Record adds entry to the log. Entries recorded after Close are dropped.
+func (l *Log) Record(entry Entry) {
+	if l.opts.RedactValues && entry.Value != "" {
+		entry.Value = valueRedacted
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.closed {
+		l.dropped.Add(1)
+		return
+	}
+
+	switch l.opts.Policy {
+	case PolicyBlock:
+		l.entries <- entry
+	case PolicyDropNewest:
+		select {
+		case l.entries <- entry:
+		default:
+			l.dropped.Add(1)
+		}
+	case PolicyDropOldest:
+		for {
+			select {
+			case l.entries <- entry:
+				return
+			default:
+			}
+
+			select {
+			case <-l.entries:
+				l.dropped.Add(1)
+			default:
+			}
+		}
+	}
+}
+
+// Dropped returns number of entries lost because buffer was full or log was closed.
+func (l *Log) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+// Failed returns number of entries which writer failed to write.
+func (l *Log) Failed() uint64 {
+	return l.failed.Load()
+}
+
+// Close writes buffered entries and closes writer if it is io.Closer.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.entries)
+	l.mu.Unlock()
+
+	<-l.done
+
+	if closer, ok := l.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (l *Log) run() {
+	defer close(l.done)
+
+	encoder := json.NewEncoder(l.w)
+	for entry := range l.entries {
+		if err := encoder.Encode(entry); err != nil {
+			l.failed.Add(1)
+		}
+	}
+}
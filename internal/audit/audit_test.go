@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingWriter blocks writes until it is released.
+type blockingWriter struct {
+	release chan struct{}
+	mu      sync.Mutex
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func readEntries(t *testing.T, r io.Reader) []Entry {
+	t.Helper()
+
+	entries := make([]Entry, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var entry Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestLog_Record(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := New(&buf, Options{})
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	log.Record(Entry{Time: now, ClientID: 7, Addr: "127.0.0.1:5000", User: "alice", Command: "set", Key: "k", Value: "v"})
+	log.Record(Entry{Time: now, User: "default", Command: "config", Args: []string{"set", "slowlog.threshold", "5ms"}})
+	require.NoError(t, log.Close())
+
+	assert.Equal(t, []Entry{
+		{Time: now, ClientID: 7, Addr: "127.0.0.1:5000", User: "alice", Command: "set", Key: "k", Value: "v"},
+		{Time: now, User: "default", Command: "config", Args: []string{"set", "slowlog.threshold", "5ms"}},
+	}, readEntries(t, &buf))
+
+	log.Record(Entry{Command: "del"})
+	assert.Equal(t, uint64(1), log.Dropped())
+}
+
+func TestLog_RedactValues(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := New(&buf, Options{RedactValues: true})
+	require.NoError(t, err)
+
+	log.Record(Entry{Command: "set", Key: "k", Value: "secret"})
+	log.Record(Entry{Command: "del", Key: "k"})
+	require.NoError(t, log.Close())
+
+	entries := readEntries(t, &buf)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "(redacted)", entries[0].Value)
+	assert.Equal(t, "", entries[1].Value)
+}
+
+func TestLog_Policies(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   Policy
+		expected []string
+	}{
+		{name: "drop newest", policy: PolicyDropNewest, expected: []string{"0", "1", "2"}},
+		{name: "drop oldest", policy: PolicyDropOldest, expected: []string{"0", "3", "4"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &blockingWriter{release: make(chan struct{})}
+			log, err := New(w, Options{BufferSize: 2, Policy: tc.policy})
+			require.NoError(t, err)
+
+			// The first entry is taken by writer, which blocks, two more fill the buffer.
+			log.Record(Entry{Command: "set", Key: "0"})
+			require.Eventually(t, func() bool { return len(log.entries) == 0 }, time.Second, time.Millisecond)
+			for i := 1; i < 5; i++ {
+				log.Record(Entry{Command: "set", Key: strconv.Itoa(i)})
+			}
+
+			close(w.release)
+			require.NoError(t, log.Close())
+
+			keys := make([]string, 0)
+			for _, entry := range readEntries(t, &w.buf) {
+				keys = append(keys, entry.Key)
+			}
+			assert.Equal(t, tc.expected, keys)
+			assert.Equal(t, uint64(2), log.Dropped())
+		})
+	}
+}
+
+func TestLog_PolicyBlock(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	log, err := New(w, Options{BufferSize: 1, Policy: PolicyBlock})
+	require.NoError(t, err)
+
+	log.Record(Entry{Command: "set", Key: "0"})
+	require.Eventually(t, func() bool { return len(log.entries) == 0 }, time.Second, time.Millisecond)
+	log.Record(Entry{Command: "set", Key: "1"})
+
+	recorded := make(chan struct{})
+	go func() {
+		log.Record(Entry{Command: "set", Key: "2"})
+		close(recorded)
+	}()
+
+	select {
+	case <-recorded:
+		t.Fatal("record didn't wait for full buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(w.release)
+	<-recorded
+	require.NoError(t, log.Close())
+
+	assert.Len(t, readEntries(t, &w.buf), 3)
+	assert.Zero(t, log.Dropped())
+}
+
+func TestNew_UnknownPolicy(t *testing.T) {
+	_, err := New(io.Discard, Options{Policy: "drop_all"})
+	assert.ErrorIs(t, err, ErrUnknownPolicy)
+}
@@ -2,7 +2,9 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 )
 
@@ -30,7 +32,13 @@ func Run(ctx context.Context, input Reader, client Client) {
 		fmt.Print("> ")
 
 		request, err := input.ReadString('\n')
-		if err != nil {
+		// Input is closed, e.g. all piped queries are sent. The last query may
+		// have no trailing newline, then it is sent and EOF is returned again.
+		if errors.Is(err, io.EOF) && request == "" {
+			fmt.Println("bye")
+			return
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
 			fmt.Println("read input error:", err)
 			continue
 		}
@@ -44,7 +52,8 @@ func Run(ctx context.Context, input Reader, client Client) {
 
 		output, err := client.Send(ctx, []byte(request))
 		if err != nil {
-			fmt.Println("failed send")
+			fmt.Println("failed send:", err)
+			continue
 		}
 
 		fmt.Println(">", string(output))
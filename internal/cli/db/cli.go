@@ -2,12 +2,15 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"kvdb/internal/model"
 	"strings"
 )
 
 type executor interface {
-	RunCommand(ctx context.Context, rawQuery string) string
+	RunCommand(ctx context.Context, rawQuery string) model.Result
 }
 
 type reader interface {
@@ -28,7 +31,12 @@ func Run(ctx context.Context, input reader, exec executor) {
 		fmt.Print("> ")
 
 		rawQuery, err := input.ReadString('\n')
-		if err != nil {
+		// Input is closed, the last query may have no trailing newline.
+		if errors.Is(err, io.EOF) && rawQuery == "" {
+			fmt.Println("Выход из программы.")
+			return
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
 			fmt.Println("read input error:", err)
 			continue
 		}
@@ -40,8 +48,12 @@ func Run(ctx context.Context, input reader, exec executor) {
 			break
 		}
 
-		output := exec.RunCommand(ctx, rawQuery)
+		result := exec.RunCommand(ctx, rawQuery)
+		if result.Status == model.StatusError {
+			fmt.Println("> (error)", result.Code, result.String())
+			continue
+		}
 
-		fmt.Println(">", output)
+		fmt.Println(">", result.String())
 	}
 }
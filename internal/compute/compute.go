@@ -10,23 +10,50 @@ import (
 )
 
 var (
-	ErrInvalidQuery   = errors.New("invalid query")
-	ErrUnknownCommand = errors.New("unknown command")
-	ErrInvalidArgs    = errors.New("invalid args")
+	ErrInvalidQuery   = model.WithCode(model.CodeSyntax, errors.New("invalid query"))
+	ErrUnknownCommand = model.WithCode(model.CodeUnknownCmd, errors.New("unknown command"))
+	ErrInvalidArgs    = model.WithCode(model.CodeWrongArgs, errors.New("invalid args"))
 )
 
+// Characters having special meaning for shlex.
+const specialChars = " \t\r\n\"'\\#"
+
 type Compute struct{}
 
 var commandsMap = map[string]model.Command{
-	"get": model.CommandGET,
-	"set": model.CommandSET,
-	"del": model.CommandDEL,
+	"get":     model.CommandGET,
+	"set":     model.CommandSET,
+	"del":     model.CommandDEL,
+	"acl":     model.CommandACL,
+	"info":    model.CommandINFO,
+	"slowlog": model.CommandSLOWLOG,
+	"client":  model.CommandCLIENT,
+	"config":  model.CommandCONFIG,
+	"incr":    model.CommandINCR,
+	"incrby":  model.CommandINCRBY,
+	"expire":  model.CommandEXPIRE,
+	"ttl":     model.CommandTTL,
+}
+
+// argsLen is an allowed number of args, max < 0 means unlimited.
+type argsLen struct {
+	min int
+	max int
 }
 
-var argsLenMap = map[model.Command]int{
-	model.CommandGET: model.CommandGETArgsLen,
-	model.CommandSET: model.CommandSETArgsLen,
-	model.CommandDEL: model.CommandDELArgsLen,
+var argsLenMap = map[model.Command]argsLen{
+	model.CommandGET:     {min: model.CommandGETArgsLen, max: model.CommandGETArgsLen},
+	model.CommandSET:     {min: model.CommandSETMinArgsLen, max: model.CommandSETMaxArgsLen},
+	model.CommandDEL:     {min: model.CommandDELArgsLen, max: model.CommandDELArgsLen},
+	model.CommandACL:     {min: model.CommandACLMinArgsLen, max: -1},
+	model.CommandINFO:    {min: 0, max: model.CommandINFOMaxArgsLen},
+	model.CommandSLOWLOG: {min: model.CommandSLOWLOGMinArgsLen, max: model.CommandSLOWLOGMaxArgsLen},
+	model.CommandCLIENT:  {min: model.CommandCLIENTMinArgsLen, max: model.CommandCLIENTMaxArgsLen},
+	model.CommandCONFIG:  {min: model.CommandCONFIGMinArgsLen, max: model.CommandCONFIGMaxArgsLen},
+	model.CommandINCR:    {min: model.CommandINCRArgsLen, max: model.CommandINCRArgsLen},
+	model.CommandINCRBY:  {min: model.CommandINCRBYArgsLen, max: model.CommandINCRBYArgsLen},
+	model.CommandEXPIRE:  {min: model.CommandEXPIREArgsLen, max: model.CommandEXPIREArgsLen},
+	model.CommandTTL:     {min: model.CommandTTLArgsLen, max: model.CommandTTLArgsLen},
 }
 
 func New() *Compute {
@@ -36,7 +63,7 @@ func New() *Compute {
 func (c *Compute) Parse(query string) (model.Query, error) {
 	queryParts, err := shlex.Split(query)
 	if err != nil {
-		return model.Query{}, fmt.Errorf("failed to parse query: %w", err)
+		return model.Query{}, model.WithCode(model.CodeSyntax, fmt.Errorf("failed to parse query: %w", err))
 	}
 
 	if len(queryParts) == 0 {
@@ -45,8 +72,8 @@ func (c *Compute) Parse(query string) (model.Query, error) {
 
 	command, ok := mapCommand(queryParts[0])
 	if !ok {
-		return model.Query{}, fmt.Errorf(
-			"%w: unknown command: %s", ErrInvalidQuery, queryParts[0])
+		return model.Query{}, model.WithCode(model.CodeUnknownCmd, fmt.Errorf(
+			"%w: unknown command: %s", ErrInvalidQuery, queryParts[0]))
 	}
 
 	args := queryParts[1:]
@@ -60,6 +87,15 @@ func (c *Compute) Parse(query string) (model.Query, error) {
 	}, nil
 }
 
+// Quote escapes argument so Parse returns it unchanged.
+func Quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, specialChars) {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
+
 func mapCommand(commandRaw string) (model.Command, bool) {
 	command, ok := commandsMap[strings.ToLower(commandRaw)]
 	if !ok {
@@ -74,9 +110,16 @@ func validateArgs(command model.Command, args []string) error {
 		return fmt.Errorf("%w: command %d", ErrUnknownCommand, command)
 	}
 
-	if len(args) != wantArgsLen {
-		return fmt.Errorf("%w: want %d args %v", ErrInvalidArgs, wantArgsLen, args)
+	if len(args) >= wantArgsLen.min && (wantArgsLen.max < 0 || len(args) <= wantArgsLen.max) {
+		return nil
 	}
 
-	return nil
+	switch {
+	case wantArgsLen.min == wantArgsLen.max:
+		return fmt.Errorf("%w: want %d args %v", ErrInvalidArgs, wantArgsLen.min, args)
+	case wantArgsLen.max < 0:
+		return fmt.Errorf("%w: want at least %d args %v", ErrInvalidArgs, wantArgsLen.min, args)
+	default:
+		return fmt.Errorf("%w: want %d to %d args %v", ErrInvalidArgs, wantArgsLen.min, wantArgsLen.max, args)
+	}
 }
@@ -40,6 +40,81 @@ func TestParse(t *testing.T) {
 			},
 			expectedErr: nil,
 		},
+		{
+			name:  "valid ACL command",
+			query: `acl setuser alice on >secret`,
+			expected: model.Query{
+				Command: model.CommandACL,
+				Args:    []string{"setuser", "alice", "on", ">secret"},
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid ACL args",
+			query:       `acl`,
+			expected:    model.Query{},
+			expectedErr: ErrInvalidArgs,
+		},
+		{
+			name:  "valid INFO command",
+			query: `info`,
+			expected: model.Query{
+				Command: model.CommandINFO,
+				Args:    []string{},
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid INFO args",
+			query:       `info server clients`,
+			expected:    model.Query{},
+			expectedErr: ErrInvalidArgs,
+		},
+		{
+			name:  "valid SLOWLOG command",
+			query: `slowlog get 5`,
+			expected: model.Query{
+				Command: model.CommandSLOWLOG,
+				Args:    []string{"get", "5"},
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid SLOWLOG args",
+			query:       `slowlog`,
+			expected:    model.Query{},
+			expectedErr: ErrInvalidArgs,
+		},
+		{
+			name:  "valid CLIENT command",
+			query: `client kill 127.0.0.1:4242`,
+			expected: model.Query{
+				Command: model.CommandCLIENT,
+				Args:    []string{"kill", "127.0.0.1:4242"},
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid CLIENT args",
+			query:       `client setname a b`,
+			expected:    model.Query{},
+			expectedErr: ErrInvalidArgs,
+		},
+		{
+			name:  "valid CONFIG command",
+			query: `config set logging.level debug`,
+			expected: model.Query{
+				Command: model.CommandCONFIG,
+				Args:    []string{"set", "logging.level", "debug"},
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid CONFIG args",
+			query:       `config`,
+			expected:    model.Query{},
+			expectedErr: ErrInvalidArgs,
+		},
 		{
 			name:        "empty command",
 			query:       ``,
@@ -58,6 +133,36 @@ func TestParse(t *testing.T) {
 			expected:    model.Query{},
 			expectedErr: ErrInvalidArgs,
 		},
+		{
+			name:  "valid INCRBY command",
+			query: `incrby counter -5`,
+			expected: model.Query{
+				Command: model.CommandINCRBY,
+				Args:    []string{"counter", "-5"},
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid INCR args",
+			query:       `incr counter 5`,
+			expected:    model.Query{},
+			expectedErr: ErrInvalidArgs,
+		},
+		{
+			name:  "valid EXPIRE command",
+			query: `EXPIRE session 60`,
+			expected: model.Query{
+				Command: model.CommandEXPIRE,
+				Args:    []string{"session", "60"},
+			},
+			expectedErr: nil,
+		},
+		{
+			name:        "invalid TTL args",
+			query:       `ttl`,
+			expected:    model.Query{},
+			expectedErr: ErrInvalidArgs,
+		},
 		{
 			name:        "invalid SET args",
 			query:       `set key`,
@@ -208,3 +313,35 @@ func TestValidateArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestQuote(t *testing.T) {
+	args := []string{
+		"key",
+		"",
+		"hello world",
+		"it's",
+		`say "hi"`,
+		`back\slash`,
+		"#hash",
+		"tab\tseparated",
+	}
+
+	c := New()
+
+	for _, arg := range args {
+		t.Run(arg, func(t *testing.T) {
+			query, err := c.Parse("set key " + Quote(arg))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if query.Args[1] != arg {
+				t.Errorf("expected arg: %q, got: %q", arg, query.Args[1])
+			}
+		})
+	}
+
+	if Quote("key") != "key" {
+		t.Errorf("expected plain arg to stay unquoted, got: %s", Quote("key"))
+	}
+}
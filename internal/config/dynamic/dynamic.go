@@ -0,0 +1,225 @@
+package dynamic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"kvdb/internal/glob"
+	"kvdb/internal/model"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrUnknownParam = model.WithCode(model.CodeNotFound, errors.New("unknown parameter"))
+	ErrInvalidValue = model.WithCode(model.CodeWrongArgs, errors.New("invalid value"))
+	ErrNoConfigFile = model.WithCode(model.CodeDisabled, errors.New("server started without config file"))
+)
+
+// Param is a name and current value of parameter.
+type Param struct {
+	Name  string
+	Value string
+}
+
+// GetFunc returns current value of parameter.
+type GetFunc func() string
+
+// SetFunc validates and applies value of parameter.
+type SetFunc func(value string) error
+
+type param struct {
+	get GetFunc
+	set SetFunc
+}
+
+// Config keeps parameters which can be read and changed while server is running.
+// Names of parameters are dot separated paths in YAML config, like logging.level,
+// so current values can be written back to config file.
+type Config struct {
+	path string // Path of YAML config file, empty when server started without it.
+
+	mu     sync.Mutex // Serializes changes and rewrites.
+	params map[string]param
+}
+
+func New(path string) *Config {
+	return &Config{
+		path:   path,
+		params: make(map[string]param),
+	}
+}
+
+// Register adds parameter, replacing parameter with the same name.
+func (c *Config) Register(name string, get GetFunc, set SetFunc) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.params[name] = param{get: get, set: set}
+	return c
+}
+
+// Get returns parameters matching glob pattern sorted by name.
+func (c *Config) Get(pattern string) []Param {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	params := make([]Param, 0)
+	for name, p := range c.params {
+		if glob.Match(pattern, name) {
+			params = append(params, Param{Name: name, Value: p.get()})
+		}
+	}
+
+	slices.SortFunc(params, func(a, b Param) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return params
+}
+
+// Set changes value of parameter.
+func (c *Config) Set(name, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.params[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownParam, name)
+	}
+
+	if err := p.set(value); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidValue, name, err)
+	}
+
+	return nil
+}
+
+// Update runs fn while parameters can't be changed, e.g. to apply reloaded
+// config without racing with Set.
+func (c *Config) Update(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fn()
+}
+
+// Rewrite writes current values of parameters to config file. Other values
+// and comments of the file are kept.
+func (c *Config) Rewrite() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.path == "" {
+		return ErrNoConfigFile
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed read config: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed parse config: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("failed parse config: want mapping at top level")
+	}
+
+	names := make([]string, 0, len(c.params))
+	for name := range c.params {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if err := setValue(root, strings.Split(name, "."), c.params[name].get()); err != nil {
+			return fmt.Errorf("failed set %s: %w", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("failed encode config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed encode config: %w", err)
+	}
+
+	return writeFile(c.path, buf.Bytes())
+}
+
+// setValue sets scalar at path of mapping, creating missing mappings.
+func setValue(node *yaml.Node, path []string, value string) error {
+	for i, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s is not a mapping", strings.Join(path[:i], "."))
+		}
+
+		var child *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == key {
+				child = node.Content[j+1]
+				break
+			}
+		}
+
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+		}
+
+		if i == len(path)-1 {
+			*child = yaml.Node{Kind: yaml.ScalarNode, Value: value, LineComment: child.LineComment}
+			return nil
+		}
+		node = child
+	}
+
+	return nil
+}
+
+// writeFile replaces file atomically, so the config is never left half written.
+func writeFile(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed create config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed write config: %w", err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed write config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed write config: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed replace config: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package dynamic
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestConfig(path string) (*Config, *string, *int) {
+	level, maxConn := "info", 50
+
+	config := New(path).
+		Register("logging.level", func() string { return level }, func(value string) error {
+			level = value
+			return nil
+		}).
+		Register("network.max_connections", func() string { return strconv.Itoa(maxConn) }, func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return errors.New("want integer")
+			}
+			maxConn = n
+			return nil
+		})
+
+	return config, &level, &maxConn
+}
+
+func TestConfig_GetSet(t *testing.T) {
+	config, level, maxConn := newTestConfig("")
+
+	assert.Equal(t, []Param{
+		{Name: "logging.level", Value: "info"},
+		{Name: "network.max_connections", Value: "50"},
+	}, config.Get("*"))
+	assert.Equal(t, []Param{{Name: "logging.level", Value: "info"}}, config.Get("logging.*"))
+	assert.Empty(t, config.Get("unknown"))
+
+	require.NoError(t, config.Set("LOGGING.LEVEL", "debug"))
+	require.NoError(t, config.Set("network.max_connections", "10"))
+	assert.Equal(t, "debug", *level)
+	assert.Equal(t, 10, *maxConn)
+
+	err := config.Set("network.max_connections", "many")
+	assert.ErrorIs(t, err, ErrInvalidValue)
+	assert.EqualError(t, err, "invalid value: network.max_connections: want integer")
+	assert.ErrorIs(t, config.Set("unknown", "1"), ErrUnknownParam)
+
+	assert.ErrorIs(t, config.Rewrite(), ErrNoConfigFile)
+}
+
+func TestConfig_Rewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`# Server config.
+engine:
+  type: in_memory
+logging:
+  level: info # Log level.
+  output: stdout
+`), 0o600))
+
+	config, _, _ := newTestConfig(path)
+	require.NoError(t, config.Set("logging.level", "warn"))
+	require.NoError(t, config.Set("network.max_connections", "10"))
+	require.NoError(t, config.Rewrite())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `# Server config.
+engine:
+  type: in_memory
+logging:
+  level: warn # Log level.
+  output: stdout
+network:
+  max_connections: 10
+`, string(data))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Missing file is created.
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, config.Rewrite())
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "logging:\n  level: warn\nnetwork:\n  max_connections: 10\n", string(data))
+}
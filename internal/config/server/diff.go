@@ -0,0 +1,72 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Diff returns YAML paths of values which differ in configs, like
+// network.listeners[1].max_connections. Derived values are skipped.
+func Diff(a, b *Config) []string {
+	return diffValues("", reflect.ValueOf(*a), reflect.ValueOf(*b))
+}
+
+func diffValues(path string, a, b reflect.Value) []string {
+	switch a.Kind() {
+	case reflect.Struct:
+		changed := make([]string, 0)
+		for i := range a.NumField() {
+			field := a.Type().Field(i)
+			name, inline := yamlName(field)
+			if name == "-" {
+				continue
+			}
+
+			fieldPath := path
+			if !inline {
+				fieldPath = joinPath(path, name)
+			}
+			changed = append(changed, diffValues(fieldPath, a.Field(i), b.Field(i))...)
+		}
+		return changed
+	case reflect.Slice:
+		if a.Type().Elem().Kind() != reflect.Struct {
+			break
+		}
+		if a.Len() != b.Len() {
+			return []string{path}
+		}
+
+		changed := make([]string, 0)
+		for i := range a.Len() {
+			changed = append(changed, diffValues(fmt.Sprintf("%s[%d]", path, i), a.Index(i), b.Index(i))...)
+		}
+		return changed
+	}
+
+	if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+		return []string{path}
+	}
+	return nil
+}
+
+// yamlName returns name of field in YAML and whether it is inlined.
+func yamlName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("yaml")
+	name, options, _ := strings.Cut(tag, ",")
+	if options == "inline" {
+		return "", true
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
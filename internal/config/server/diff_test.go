@@ -0,0 +1,55 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	load := func(data string) *Config {
+		config, err := LoadConfig(bytes.NewBufferString(data))
+		require.NoError(t, err)
+		return config
+	}
+
+	a := load(`
+network:
+  max_connections: 10
+  listeners:
+    - address: "127.0.0.1:8080"
+    - address: "127.0.0.1:8081"
+`)
+	b := load(`
+network:
+  max_connections: 10
+  max_message_size: "4KB"
+  listeners:
+    - address: "127.0.0.1:8080"
+    - address: "127.0.0.1:8081"
+      idle_timeout: "5m"
+      allowed_categories: ["read"]
+logging:
+  level: "debug"
+`)
+
+	assert.Empty(t, Diff(a, a))
+	assert.Equal(t, []string{
+		"network.max_message_size",
+		"network.listeners[0].max_message_size",
+		"network.listeners[1].max_message_size",
+		"network.listeners[1].idle_timeout",
+		"network.listeners[1].allowed_categories",
+		"logging.level",
+	}, Diff(a, b))
+
+	c := load(`
+network:
+  max_connections: 10
+  listeners:
+    - address: "127.0.0.1:8080"
+`)
+	assert.Equal(t, []string{"network.listeners"}, Diff(a, c))
+}
@@ -0,0 +1,516 @@
+package server
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts names of environment variables which override config values.
+const EnvPrefix = "KVDB_"
+
+// Source tells where effective config value comes from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+)
+
+// EnvSource is a source of value set by environment variable.
+func EnvSource(name string) Source {
+	return Source("env " + name)
+}
+
+// FlagSource is a source of value set by command line flag.
+func FlagSource(name string) Source {
+	return Source("flag -" + name)
+}
+
+// Override replaces config value at YAML path, like network.address or
+// network.listeners[0].max_connections. Lists are comma separated.
+type Override struct {
+	Path   string
+	Value  string
+	Source Source
+}
+
+// Sources maps YAML paths of effective config values to their sources.
+type Sources map[string]Source
+
+var (
+	ErrUnknownEnv = errors.New("unknown environment variable")
+
+	pathIndexPattern = regexp.MustCompile(`^(.+)\[(\d+)\]$`)
+)
+
+// inheritingList is a list which entries inherit values of its parent, like
+// network.listeners inherit network values.
+type inheritingList struct {
+	path       string
+	parent     string
+	entryType  reflect.Type
+	envPattern *regexp.Regexp
+}
+
+var inheritingLists = []inheritingList{
+	newInheritingList("network.listeners", reflect.TypeFor[ListenerConfig]()),
+	newInheritingList("logging.sinks", reflect.TypeFor[LogSinkConfig]()),
+}
+
+func newInheritingList(path string, entryType reflect.Type) inheritingList {
+	return inheritingList{
+		path:       path,
+		parent:     path[:strings.LastIndex(path, ".")],
+		entryType:  entryType,
+		envPattern: regexp.MustCompile(`^` + EnvName(path) + `_(\d+)_(.+)$`),
+	}
+}
+
+// Paths returns YAML paths of config values which can be overridden by name.
+// Values of network.listeners and logging.sinks entries are overridden by index,
+// see EnvOverrides.
+func Paths() []string {
+	return typePaths("", reflect.TypeFor[Config]())
+}
+
+// EnvName returns name of environment variable overriding value at path, e.g.
+// KVDB_NETWORK_ADDRESS for network.address and KVDB_NETWORK_LISTENERS_0_ADDRESS
+// for network.listeners[0].address.
+func EnvName(path string) string {
+	replacer := strings.NewReplacer(".", "_", "[", "_", "]", "")
+	return EnvPrefix + strings.ToUpper(replacer.Replace(path))
+}
+
+// EnvOverrides returns overrides from KVDB_* variables of environ, which has
+// "key=value" format of os.Environ. Unknown variables which start with a section of
+// config, like KVDB_NETWORK_ADRESS, are reported as errors, so typos are not silently
+// ignored. Other unknown variables are returned as ignored: Kubernetes sets
+// KVDB_PORT and KVDB_SERVICE_HOST for a service named kvdb.
+func EnvOverrides(environ []string) (overrides []Override, ignored []string, err error) {
+	paths := make(map[string]string)
+	sections := make(map[string]bool)
+	for _, path := range Paths() {
+		paths[EnvName(path)] = path
+		section, _, _ := strings.Cut(path, ".")
+		sections[EnvName(section)] = true
+	}
+
+	entryPaths := make([]map[string]string, len(inheritingLists))
+	for i, list := range inheritingLists {
+		entryPaths[i] = make(map[string]string)
+		for _, path := range typePaths("", list.entryType) {
+			entryPaths[i][strings.TrimPrefix(EnvName(path), EnvPrefix)] = path
+		}
+	}
+
+	overrides = make([]Override, 0)
+	unknown := make([]string, 0)
+	for _, env := range environ {
+		name, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+
+		if path, ok := paths[name]; ok {
+			overrides = append(overrides, Override{Path: path, Value: value, Source: EnvSource(name)})
+			continue
+		}
+
+		if path, ok := entryPath(name, entryPaths); ok {
+			overrides = append(overrides, Override{Path: path, Value: value, Source: EnvSource(name)})
+			continue
+		}
+
+		section, _, _ := strings.Cut(strings.TrimPrefix(name, EnvPrefix), "_")
+		if sections[EnvPrefix+section] {
+			unknown = append(unknown, name)
+		} else {
+			ignored = append(ignored, name)
+		}
+	}
+
+	if len(unknown) != 0 {
+		slices.Sort(unknown)
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnknownEnv, strings.Join(unknown, ", "))
+	}
+	slices.Sort(ignored)
+
+	// Environment has no order, sort to apply overrides of the same entry predictably.
+	slices.SortFunc(overrides, func(a, b Override) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return overrides, ignored, nil
+}
+
+// entryPath returns path of list entry value overridden by environment variable.
+func entryPath(name string, entryPaths []map[string]string) (string, bool) {
+	for i, list := range inheritingLists {
+		match := list.envPattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		if path, ok := entryPaths[i][match[2]]; ok {
+			return fmt.Sprintf("%s[%s].%s", list.path, match[1], path), true
+		}
+	}
+	return "", false
+}
+
+// RegisterFlags defines flag for every path of Paths, named like -network.address.
+// Returned function lists overrides of flags set on command line, call it after parse.
+func RegisterFlags(fs *flag.FlagSet) func() []Override {
+	values := make(map[string]*string)
+	for _, path := range Paths() {
+		values[path] = fs.String(path, "", "override "+path+" of config file")
+	}
+
+	return func() []Override {
+		overrides := make([]Override, 0)
+		fs.Visit(func(f *flag.Flag) {
+			if value, ok := values[f.Name]; ok {
+				overrides = append(overrides, Override{Path: f.Name, Value: *value, Source: FlagSource(f.Name)})
+			}
+		})
+		return overrides
+	}
+}
+
+// LoadLayers decodes config from layers: defaults, YAML of r and overrides applied
+// in order, so later overrides win. It returns sources of all effective values.
+func LoadLayers(r io.Reader, overrides []Override) (*Config, Sources, error) {
+	config := &Config{}
+	config.setDefaults()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed read file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed parse yaml: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("failed parse yaml: want mapping at top level")
+	}
+
+	v := &validator{}
+
+	// Node decoding has no known fields check, so unknown keys are looked up here.
+	for _, problem := range unknownFields(root, reflect.TypeFor[Config]()) {
+		v.addf("", "failed parse yaml: %s", problem)
+	}
+
+	explicit := make(map[string]Source)
+	nodePaths("", root, func(path string) {
+		explicit[path] = SourceFile
+	})
+
+	for _, override := range overrides {
+		if err := setOverride(root, override); err != nil {
+			v.addf(override.Path, "%s: %s", override.Source, err)
+			continue
+		}
+		explicit[override.Path] = override.Source
+	}
+
+	if err := root.Decode(config); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, nil, fmt.Errorf("failed parse yaml: %w", err)
+		}
+
+		// Values which were decoded are still validated to report all problems.
+		for _, problem := range typeErr.Errors {
+			v.addf("", "failed parse yaml: %s", problem)
+		}
+	}
+
+	for i := range config.Network.Listeners {
+		listener := &config.Network.Listeners[i]
+		if listener.Name == "" {
+			listener.Name = listener.Address
+		}
+	}
+
+	config.validate(v)
+	if err := v.err(); err != nil {
+		return nil, nil, err
+	}
+
+	return config, config.sources(explicit), nil
+}
+
+// sources returns source of every value. Values of listeners and sinks which are
+// not set explicitly are inherited from top level network and logging values.
+func (c *Config) sources(explicit map[string]Source) Sources {
+	sources := make(Sources)
+	valuePaths("", reflect.ValueOf(*c), func(path string) {
+		source, ok := explicit[path]
+		for _, list := range inheritingLists {
+			if _, field, found := strings.Cut(path, "]."); !ok && found && strings.HasPrefix(path, list.path+"[") {
+				source, ok = explicit[list.parent+"."+field]
+			}
+		}
+		if !ok {
+			source = SourceDefault
+		}
+		sources[path] = source
+	})
+	return sources
+}
+
+// EncodeWithSources writes config as YAML, commenting every value with its source.
+func EncodeWithSources(w io.Writer, config *Config, sources Sources) error {
+	var root yaml.Node
+	if err := root.Encode(config); err != nil {
+		return fmt.Errorf("failed encode config: %w", err)
+	}
+
+	nodeValues("", &root, nil, func(path string, key, value *yaml.Node) {
+		source, ok := sources[path]
+		switch {
+		case !ok:
+		case value.Kind == yaml.SequenceNode && key != nil:
+			// Comment of block sequence is placed after its key.
+			key.LineComment = string(source)
+		default:
+			value.LineComment = string(source)
+		}
+	})
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&root); err != nil {
+		return fmt.Errorf("failed encode config: %w", err)
+	}
+	return encoder.Close()
+}
+
+// setOverride sets value at path of config mapping, creating missing mappings.
+// Entries of lists must exist in the file to be overridden by index.
+func setOverride(root *yaml.Node, override Override) error {
+	segments := strings.Split(override.Path, ".")
+
+	fieldType, ok := pathType(reflect.TypeFor[Config](), segments)
+	if !ok {
+		return fmt.Errorf("unknown config value")
+	}
+
+	value, err := overrideNode(fieldType, override.Value)
+	if err != nil {
+		return err
+	}
+
+	node := root
+	for i, segment := range segments {
+		name, index := segment, -1
+		if match := pathIndexPattern.FindStringSubmatch(segment); match != nil {
+			name = match[1]
+			index, _ = strconv.Atoi(match[2])
+		}
+
+		child := mappingValue(node, name)
+		if child == nil {
+			if index >= 0 {
+				return fmt.Errorf("%s has no entry %d in config file", name, index)
+			}
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, child)
+		}
+
+		if index >= 0 {
+			if child.Kind != yaml.SequenceNode || index >= len(child.Content) {
+				return fmt.Errorf("%s has no entry %d in config file", name, index)
+			}
+			child = child.Content[index]
+		}
+
+		if i == len(segments)-1 {
+			*child = *value
+			return nil
+		}
+		if child.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s is not a mapping in config file", strings.Join(segments[:i+1], "."))
+		}
+		node = child
+	}
+
+	return nil
+}
+
+// overrideNode converts override value to YAML node of field type.
+func overrideNode(t reflect.Type, value string) (*yaml.Node, error) {
+	scalar := func(tag, value string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+	}
+
+	switch {
+	case t == reflect.TypeFor[time.Duration]():
+		if _, err := time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("want duration like 5s, got %q", value)
+		}
+		return scalar("!!str", value), nil
+	case t.Kind() == reflect.Int:
+		if _, err := strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("want integer, got %q", value)
+		}
+		return scalar("!!int", value), nil
+	case t.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("want true or false, got %q", value)
+		}
+		return scalar("!!bool", strconv.FormatBool(b)), nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				node.Content = append(node.Content, scalar("!!str", item))
+			}
+		}
+		return node, nil
+	case t.Kind() == reflect.String:
+		return scalar("!!str", value), nil
+	}
+
+	return nil, fmt.Errorf("value can't be overridden")
+}
+
+// pathType returns type of config value at path segments.
+func pathType(t reflect.Type, segments []string) (reflect.Type, bool) {
+	for _, segment := range segments {
+		name, indexed := segment, false
+		if match := pathIndexPattern.FindStringSubmatch(segment); match != nil {
+			name, indexed = match[1], true
+		}
+
+		fields := make(map[string]reflect.Type)
+		if t.Kind() != reflect.Struct {
+			return nil, false
+		}
+		collectFields(t, fields)
+
+		fieldType, ok := fields[name]
+		if !ok {
+			return nil, false
+		}
+		if indexed {
+			if fieldType.Kind() != reflect.Slice {
+				return nil, false
+			}
+			fieldType = fieldType.Elem()
+		}
+		t = fieldType
+	}
+
+	return t, !isStructList(t) && t.Kind() != reflect.Struct
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// typePaths returns paths of values of struct type, except lists of structs.
+func typePaths(path string, t reflect.Type) []string {
+	if t.Kind() != reflect.Struct {
+		if isStructList(t) {
+			return nil
+		}
+		return []string{path}
+	}
+
+	paths := make([]string, 0)
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, inline := yamlName(field)
+		switch {
+		case name == "-":
+		case inline:
+			paths = append(paths, typePaths(path, field.Type)...)
+		default:
+			paths = append(paths, typePaths(joinPath(path, name), field.Type)...)
+		}
+	}
+	return paths
+}
+
+// valuePaths calls fn for path of every value, including values of list entries.
+func valuePaths(path string, v reflect.Value, fn func(path string)) {
+	switch {
+	case v.Kind() == reflect.Struct:
+		for i := range v.NumField() {
+			name, inline := yamlName(v.Type().Field(i))
+			switch {
+			case name == "-":
+			case inline:
+				valuePaths(path, v.Field(i), fn)
+			default:
+				valuePaths(joinPath(path, name), v.Field(i), fn)
+			}
+		}
+	case isStructList(v.Type()):
+		for i := range v.Len() {
+			valuePaths(fmt.Sprintf("%s[%d]", path, i), v.Index(i), fn)
+		}
+	default:
+		fn(path)
+	}
+}
+
+// nodePaths calls fn for path of every value set in YAML mapping.
+func nodePaths(path string, node *yaml.Node, fn func(path string)) {
+	nodeValues(path, node, nil, func(path string, _, _ *yaml.Node) {
+		fn(path)
+	})
+}
+
+// nodeValues calls fn for every scalar or list of scalars in YAML node with
+// its mapping key, nil for list entries.
+func nodeValues(path string, node, key *yaml.Node, fn func(path string, key, value *yaml.Node)) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			nodeValues(path, child, nil, fn)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			nodeValues(joinPath(path, node.Content[i].Value), node.Content[i+1], node.Content[i], fn)
+		}
+	case yaml.SequenceNode:
+		if len(node.Content) != 0 && node.Content[0].Kind == yaml.MappingNode {
+			for i, child := range node.Content {
+				nodeValues(fmt.Sprintf("%s[%d]", path, i), child, nil, fn)
+			}
+			return
+		}
+		fn(path, key, node)
+	default:
+		fn(path, key, node)
+	}
+}
+
+func isStructList(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct
+}
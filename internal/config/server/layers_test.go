@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "KVDB_NETWORK_ADDRESS", EnvName("network.address"))
+	assert.Equal(t, "KVDB_NETWORK_TLS_CERT_FILE", EnvName("network.tls.cert_file"))
+	assert.Equal(t, "KVDB_NETWORK_LISTENERS_1_MAX_CONNECTIONS", EnvName("network.listeners[1].max_connections"))
+}
+
+func TestPaths(t *testing.T) {
+	paths := Paths()
+
+	assert.Contains(t, paths, "network.address")
+	assert.Contains(t, paths, "network.tls.require_client_cert")
+	assert.Contains(t, paths, "http.websocket.listener")
+	assert.Contains(t, paths, "security.passwords")
+	assert.NotContains(t, paths, "network.listeners")
+	assert.NotContains(t, paths, "network.max_message_size_bytes")
+}
+
+func TestEnvOverrides(t *testing.T) {
+	overrides, ignored, err := EnvOverrides([]string{
+		"HOME=/root",
+		"KVDB_NETWORK_ADDRESS=0.0.0.0:7000",
+		"KVDB_NETWORK_LISTENERS_0_TLS_ENABLED=true",
+		"KVDB_SECURITY_PASSWORDS=a,b",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []Override{
+		{Path: "network.address", Value: "0.0.0.0:7000", Source: EnvSource("KVDB_NETWORK_ADDRESS")},
+		{Path: "network.listeners[0].tls.enabled", Value: "true", Source: EnvSource("KVDB_NETWORK_LISTENERS_0_TLS_ENABLED")},
+		{Path: "security.passwords", Value: "a,b", Source: EnvSource("KVDB_SECURITY_PASSWORDS")},
+	}, overrides)
+	assert.Empty(t, ignored)
+
+	overrides, _, err = EnvOverrides([]string{"KVDB_LOGGING_SINKS_1_ROTATION_MAX_SIZE=1MB"})
+	require.NoError(t, err)
+	assert.Equal(t, "logging.sinks[1].rotation.max_size", overrides[0].Path)
+
+	_, _, err = EnvOverrides([]string{"KVDB_NETWORK_ADRESS=0.0.0.0:7000", "KVDB_NETWORK_LISTENERS_0_PORT=1"})
+	require.ErrorIs(t, err, ErrUnknownEnv)
+	assert.Contains(t, err.Error(), "KVDB_NETWORK_ADRESS, KVDB_NETWORK_LISTENERS_0_PORT")
+}
+
+// TestEnvOverrides_Kubernetes tests that service variables set by Kubernetes are ignored.
+func TestEnvOverrides_Kubernetes(t *testing.T) {
+	overrides, ignored, err := EnvOverrides([]string{
+		"KVDB_PORT=tcp://10.0.0.1:6379",
+		"KVDB_PORT_6379_TCP_ADDR=10.0.0.1",
+		"KVDB_SERVICE_HOST=10.0.0.1",
+		"KVDB_SERVICE_PORT=6379",
+		"KVDB_LOGGING_LEVEL=debug",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []Override{
+		{Path: "logging.level", Value: "debug", Source: EnvSource("KVDB_LOGGING_LEVEL")},
+	}, overrides)
+	assert.Equal(t, []string{"KVDB_PORT", "KVDB_PORT_6379_TCP_ADDR", "KVDB_SERVICE_HOST", "KVDB_SERVICE_PORT"}, ignored)
+}
+
+func TestRegisterFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	overrides := RegisterFlags(fs)
+
+	require.NoError(t, fs.Parse([]string{"-network.max_connections", "7", "-logging.level=debug"}))
+
+	assert.Equal(t, []Override{
+		{Path: "logging.level", Value: "debug", Source: FlagSource("logging.level")},
+		{Path: "network.max_connections", Value: "7", Source: FlagSource("network.max_connections")},
+	}, overrides())
+}
+
+// TestLoadLayers tests that later layers win and sources of values are tracked.
+func TestLoadLayers(t *testing.T) {
+	yamlData := `
+network:
+  address: "127.0.0.1:8080"
+  max_connections: 10
+  listeners:
+    - name: "public"
+      address: "0.0.0.0:8443"
+    - name: "admin"
+      address: "127.0.0.1:8444"
+      max_connections: 2
+logging:
+  level: "info"
+`
+
+	overrides := []Override{
+		{Path: "network.max_connections", Value: "20", Source: EnvSource("KVDB_NETWORK_MAX_CONNECTIONS")},
+		{Path: "network.listeners[1].idle_timeout", Value: "5s", Source: EnvSource("KVDB_NETWORK_LISTENERS_1_IDLE_TIMEOUT")},
+		{Path: "logging.level", Value: "warn", Source: EnvSource("KVDB_LOGGING_LEVEL")},
+		{Path: "logging.level", Value: "debug", Source: FlagSource("logging.level")},
+		{Path: "network.allowed_categories", Value: "read, write", Source: FlagSource("network.allowed_categories")},
+	}
+
+	config, sources, err := LoadLayers(bytes.NewBufferString(yamlData), overrides)
+	require.NoError(t, err)
+
+	assert.Equal(t, "debug", config.Logging.Level)
+	assert.Equal(t, 20, config.Network.MaxConnections)
+
+	public, admin := config.Network.Listeners[0], config.Network.Listeners[1]
+	assert.Equal(t, 20, public.MaxConnections, "top level override is inherited")
+	assert.Equal(t, []string{"read", "write"}, public.AllowedCategories)
+	assert.Equal(t, 2, admin.MaxConnections)
+	assert.Equal(t, 5*time.Second, admin.IdleTimeout)
+
+	assert.Equal(t, FlagSource("logging.level"), sources["logging.level"])
+	assert.Equal(t, SourceDefault, sources["logging.output"])
+	assert.Equal(t, SourceFile, sources["network.address"])
+	assert.Equal(t, EnvSource("KVDB_NETWORK_MAX_CONNECTIONS"), sources["network.listeners[0].max_connections"])
+	assert.Equal(t, SourceFile, sources["network.listeners[1].max_connections"])
+	assert.Equal(t, EnvSource("KVDB_NETWORK_LISTENERS_1_IDLE_TIMEOUT"), sources["network.listeners[1].idle_timeout"])
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeWithSources(&buf, config, sources))
+	assert.Contains(t, buf.String(), "level: debug # flag -logging.level")
+	assert.Contains(t, buf.String(), "output: /var/log/app.log # default")
+}
+
+// TestLoadLayers_WithoutFile tests that config can be built from overrides only.
+func TestLoadLayers_WithoutFile(t *testing.T) {
+	overrides := []Override{
+		{Path: "network.address", Value: "0.0.0.0:7000", Source: EnvSource("KVDB_NETWORK_ADDRESS")},
+	}
+
+	config, sources, err := LoadLayers(strings.NewReader(""), overrides)
+	require.NoError(t, err)
+
+	assert.Equal(t, "0.0.0.0:7000", config.Network.Address)
+	assert.Equal(t, SourceDefault, sources["engine.type"])
+}
+
+// TestLoadLayers_InvalidOverrides tests that invalid overrides are reported with other problems.
+func TestLoadLayers_InvalidOverrides(t *testing.T) {
+	overrides := []Override{
+		{Path: "network.max_connections", Value: "many", Source: FlagSource("network.max_connections")},
+		{Path: "network.listeners[3].address", Value: "0.0.0.0:7000", Source: EnvSource("KVDB_NETWORK_LISTENERS_3_ADDRESS")},
+		{Path: "http.enabled", Value: "yes please", Source: EnvSource("KVDB_HTTP_ENABLED")},
+		{Path: "slowlog.max_len", Value: "-1", Source: EnvSource("KVDB_SLOWLOG_MAX_LEN")},
+	}
+
+	_, _, err := LoadLayers(strings.NewReader(""), overrides)
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		`network.max_connections: flag -network.max_connections: want integer, got "many"`,
+		"network.listeners[3].address: env KVDB_NETWORK_LISTENERS_3_ADDRESS: listeners has no entry 3 in config file",
+		`http.enabled: env KVDB_HTTP_ENABLED: want true or false, got "yes please"`,
+		"slowlog.max_len: must be non-negative, got -1",
+	}, validationErr.Problems)
+}
@@ -1,67 +1,264 @@
 package server
 
 import (
-	"fmt"
+	"errors"
 	"io"
+	"kvdb/internal/audit"
+	"os"
+	"slices"
 	"time"
 
-	humanize "github.com/dustin/go-humanize"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Engine  EngineConfig  `yaml:"engine"`
-	Network NetworkConfig `yaml:"network"`
-	Logging LoggingConfig `yaml:"logging"`
+	Engine   EngineConfig   `yaml:"engine"`
+	Network  NetworkConfig  `yaml:"network"`
+	Logging  LoggingConfig  `yaml:"logging"`
+	Security SecurityConfig `yaml:"security"`
+	HTTP     HTTPConfig     `yaml:"http"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
+	SlowLog  SlowLogConfig  `yaml:"slowlog"`
+	Audit    AuditConfig    `yaml:"audit"`
 }
 
 type EngineConfig struct {
 	Type string `yaml:"type"`
 }
 
+// NetworkConfig describes listeners. Top level values configure the single
+// listener when Listeners is empty, otherwise they are defaults for every listener.
 type NetworkConfig struct {
+	ListenerConfig `yaml:",inline"`
+	Listeners      []ListenerConfig `yaml:"listeners"`
+}
+
+type ListenerConfig struct {
+	Name                string        `yaml:"name"`
 	Address             string        `yaml:"address"`
+	Protocol            string        `yaml:"protocol"` // text, framed or resp.
 	MaxConnections      int           `yaml:"max_connections"`
 	MaxMessageSize      string        `yaml:"max_message_size"`
 	MaxMessageSizeBytes uint64        `yaml:"-"`
 	IdleTimeout         time.Duration `yaml:"idle_timeout"`
+	UnixSocketPerm      string        `yaml:"unix_socket_perm"` // Octal permissions of unix socket file.
+	UnixSocketPermMode  os.FileMode   `yaml:"-"`
+	TLS                 TLSConfig     `yaml:"tls"`
+	AllowedCategories   []string      `yaml:"allowed_categories"` // read, write, admin or all.
+}
+
+type TLSConfig struct {
+	Enabled           bool   `yaml:"enabled"`
+	CertFile          string `yaml:"cert_file"`
+	KeyFile           string `yaml:"key_file"`
+	CAFile            string `yaml:"ca_file"`
+	MinVersion        string `yaml:"min_version"`
+	RequireClientCert bool   `yaml:"require_client_cert"`
 }
 
+// LoggingConfig describes log sinks. Top level values configure the single sink
+// when Sinks is empty, otherwise they are defaults for every sink.
 type LoggingConfig struct {
-	Level  string `yaml:"level"`
-	Output string `yaml:"output"`
+	LogSinkConfig `yaml:",inline"`
+	Sinks         []LogSinkConfig `yaml:"sinks"`
+}
+
+type LogSinkConfig struct {
+	Level    string         `yaml:"level"`
+	Output   string         `yaml:"output"`   // stdout, stderr or file path.
+	Encoding string         `yaml:"encoding"` // json or console.
+	Rotation RotationConfig `yaml:"rotation"` // Ignored for stdout and stderr.
+}
+
+// RotationConfig describes rotation of log file. Zero values disable rotation.
+type RotationConfig struct {
+	MaxSize      string        `yaml:"max_size"` // Rotate when file grows over size, like 100MB.
+	MaxSizeBytes uint64        `yaml:"-"`
+	MaxAge       time.Duration `yaml:"max_age"`     // Rotate file opened longer ago.
+	MaxBackups   int           `yaml:"max_backups"` // Number of rotated files to keep, 0 keeps all.
+	Compress     bool          `yaml:"compress"`    // Compress rotated files with gzip.
+}
+
+type SecurityConfig struct {
+	Passwords         []string      `yaml:"passwords"` // Hashes printed by -hash-password, or legacy hex sha256.
+	MaxAuthFailures   int           `yaml:"max_auth_failures"`
+	AuthBlockDuration time.Duration `yaml:"auth_block_duration"`
+	ACLFile           string        `yaml:"acl_file"`
+}
+
+// HTTPConfig describes optional JSON gateway.
+type HTTPConfig struct {
+	Enabled           bool            `yaml:"enabled"`
+	Address           string          `yaml:"address"`
+	ReadTimeout       time.Duration   `yaml:"read_timeout"`
+	WriteTimeout      time.Duration   `yaml:"write_timeout"`
+	MaxBodySize       string          `yaml:"max_body_size"`
+	MaxBodySizeBytes  uint64          `yaml:"-"`
+	AllowedCategories []string        `yaml:"allowed_categories"` // read, write, admin or all.
+	WebSocket         WebSocketConfig `yaml:"websocket"`
+}
+
+// WebSocketConfig describes websocket endpoint of the HTTP gateway. Connections
+// are served with authentication and limits of the named listener.
+type WebSocketConfig struct {
+	Enabled        bool     `yaml:"enabled"`
+	Listener       string   `yaml:"listener"`        // Name of listener, first listener when empty.
+	AllowedOrigins []string `yaml:"allowed_origins"` // Origins of pages of other hosts, "*" allows any.
+}
+
+// MetricsConfig describes endpoint of metrics in Prometheus text format.
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address"`
+	Path    string `yaml:"path"`
+}
+
+// SlowLogConfig describes log of slow commands. Negative threshold disables it.
+type SlowLogConfig struct {
+	Threshold time.Duration `yaml:"threshold"`
+	MaxLen    int           `yaml:"max_len"`
+}
+
+// AuditConfig describes log of commands which change data or server state.
+type AuditConfig struct {
+	Enabled      bool           `yaml:"enabled"`
+	Output       string         `yaml:"output"`        // stdout or file path.
+	BufferSize   int            `yaml:"buffer_size"`   // Entries waiting to be written.
+	FullPolicy   string         `yaml:"full_policy"`   // block, drop_newest or drop_oldest.
+	RedactValues bool           `yaml:"redact_values"` // Don't write values of keys.
+	Rotation     RotationConfig `yaml:"rotation"`
+}
+
+// UnmarshalYAML decodes listeners on top of the top level values, so listeners inherit them.
+func (c *NetworkConfig) UnmarshalYAML(value *yaml.Node) error {
+	typeErrs := make([]string, 0)
+
+	var raw struct {
+		ListenerConfig `yaml:",inline"`
+		Listeners      []yaml.Node `yaml:"listeners"`
+	}
+	raw.ListenerConfig = c.ListenerConfig
+
+	if err := value.Decode(&raw); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return err
+		}
+		typeErrs = append(typeErrs, typeErr.Errors...)
+	}
+	c.ListenerConfig = raw.ListenerConfig
+
+	c.Listeners = make([]ListenerConfig, 0, len(raw.Listeners))
+	for i := range raw.Listeners {
+		listener := c.ListenerConfig
+		listener.Name = ""
+		listener.AllowedCategories = slices.Clone(c.AllowedCategories)
+
+		if err := raw.Listeners[i].Decode(&listener); err != nil {
+			var typeErr *yaml.TypeError
+			if !errors.As(err, &typeErr) {
+				return err
+			}
+			typeErrs = append(typeErrs, typeErr.Errors...)
+		}
+		c.Listeners = append(c.Listeners, listener)
+	}
+
+	if len(typeErrs) != 0 {
+		return &yaml.TypeError{Errors: typeErrs}
+	}
+
+	return nil
+}
+
+// UnmarshalYAML decodes sinks on top of the top level values, so sinks inherit them.
+func (c *LoggingConfig) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		LogSinkConfig `yaml:",inline"`
+		Sinks         []yaml.Node `yaml:"sinks"`
+	}
+	raw.LogSinkConfig = c.LogSinkConfig
+
+	typeErrs := make([]string, 0)
+	if err := value.Decode(&raw); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return err
+		}
+		typeErrs = append(typeErrs, typeErr.Errors...)
+	}
+	c.LogSinkConfig = raw.LogSinkConfig
+
+	c.Sinks = make([]LogSinkConfig, 0, len(raw.Sinks))
+	for i := range raw.Sinks {
+		sink := c.LogSinkConfig
+		if err := raw.Sinks[i].Decode(&sink); err != nil {
+			var typeErr *yaml.TypeError
+			if !errors.As(err, &typeErr) {
+				return err
+			}
+			typeErrs = append(typeErrs, typeErr.Errors...)
+		}
+		c.Sinks = append(c.Sinks, sink)
+	}
+
+	if len(typeErrs) != 0 {
+		return &yaml.TypeError{Errors: typeErrs}
+	}
+
+	return nil
+}
+
+// EffectiveSinks returns sinks to write logs to.
+func (c *LoggingConfig) EffectiveSinks() []LogSinkConfig {
+	if len(c.Sinks) == 0 {
+		return []LogSinkConfig{c.LogSinkConfig}
+	}
+	return c.Sinks
+}
+
+// EffectiveListeners returns listeners to start.
+func (c *NetworkConfig) EffectiveListeners() []ListenerConfig {
+	if len(c.Listeners) == 0 {
+		return []ListenerConfig{c.ListenerConfig}
+	}
+	return c.Listeners
 }
 
 func (c *Config) setDefaults() {
 	c.Engine.Type = "in_memory"
+	c.Network.Name = "default"
 	c.Network.Address = "127.0.0.1:8080"
+	c.Network.Protocol = "text"
 	c.Network.MaxConnections = 50
 	c.Network.MaxMessageSize = "2KB"
 	c.Network.MaxMessageSizeBytes = 2048
 	c.Network.IdleTimeout = 1 * time.Minute
+	c.Network.TLS.MinVersion = "1.2"
+	c.Network.AllowedCategories = []string{"all"}
 	c.Logging.Level = "info"
 	c.Logging.Output = "/var/log/app.log"
+	c.Logging.Encoding = "json"
+	c.Security.MaxAuthFailures = 5
+	c.Security.AuthBlockDuration = 1 * time.Minute
+	c.HTTP.Address = "127.0.0.1:8081"
+	c.HTTP.ReadTimeout = 10 * time.Second
+	c.HTTP.WriteTimeout = 10 * time.Second
+	c.HTTP.AllowedCategories = []string{"all"}
+	c.HTTP.MaxBodySize = "1MB"
+	c.HTTP.MaxBodySizeBytes = 1000 * 1000
+	c.Metrics.Address = "127.0.0.1:9100"
+	c.Metrics.Path = "/metrics"
+	c.SlowLog.Threshold = 10 * time.Millisecond
+	c.SlowLog.MaxLen = 128
+	c.Audit.Output = "/var/log/kvdb/audit.log"
+	c.Audit.BufferSize = audit.DefaultBufferSize
+	c.Audit.FullPolicy = string(audit.PolicyBlock)
 }
 
+// LoadConfig decodes YAML config on top of defaults. Unknown fields and invalid
+// values are reported together in ValidationError.
 func LoadConfig(r io.Reader) (*Config, error) {
-	config := &Config{}
-	config.setDefaults()
-
-	data, err := io.ReadAll(r)
-	if err != nil {
-		return nil, fmt.Errorf("failed read file: %w", err)
-	}
-
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
-		return nil, fmt.Errorf("failed parse yaml: %w", err)
-	}
-
-	maxMessageSizeBytes, err := humanize.ParseBytes(config.Network.MaxMessageSize)
-	if err != nil {
-		return nil, fmt.Errorf("failed parse bytes %s: %w", config.Network.MaxMessageSize, err)
-	}
-	config.Network.MaxMessageSizeBytes = maxMessageSizeBytes
-
-	return config, nil
+	config, _, err := LoadLayers(r, nil)
+	return config, err
 }
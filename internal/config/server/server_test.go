@@ -3,6 +3,9 @@ package server
 import (
 	"bytes"
 	"errors"
+	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -21,37 +24,251 @@ func TestLoadConfig_DefaultValues(t *testing.T) {
 	assert.Equal(t, "2KB", config.Network.MaxMessageSize)
 	assert.Equal(t, uint64(2*1024), config.Network.MaxMessageSizeBytes)
 	assert.Equal(t, 1*time.Minute, config.Network.IdleTimeout)
+	assert.False(t, config.Network.TLS.Enabled)
+	assert.Equal(t, "1.2", config.Network.TLS.MinVersion)
 	assert.Equal(t, "info", config.Logging.Level)
 	assert.Equal(t, "/var/log/app.log", config.Logging.Output)
+	assert.Equal(t, "json", config.Logging.Encoding)
+	assert.Empty(t, config.Security.Passwords)
+	assert.Equal(t, 5, config.Security.MaxAuthFailures)
+	assert.Equal(t, 1*time.Minute, config.Security.AuthBlockDuration)
+	assert.False(t, config.HTTP.Enabled)
+	assert.Equal(t, "127.0.0.1:8081", config.HTTP.Address)
+	assert.Equal(t, uint64(1000*1000), config.HTTP.MaxBodySizeBytes)
+	assert.Equal(t, MetricsConfig{Address: "127.0.0.1:9100", Path: "/metrics"}, config.Metrics)
+	assert.Equal(t, SlowLogConfig{Threshold: 10 * time.Millisecond, MaxLen: 128}, config.SlowLog)
 }
 
 // TestLoadConfig_FromYAML tests loading config from a YAML file.
 func TestLoadConfig_FromYAML(t *testing.T) {
 	yamlData := `
 engine:
-  type: "redis"
+  type: "in_memory"
 network:
   address: "0.0.0.0:8081"
   max_connections: 100
   max_message_size: "4KB"
   idle_timeout: "2m"
+  unix_socket_perm: "0660"
+  tls:
+    enabled: true
+    cert_file: "/etc/kvdb/server.pem"
+    key_file: "/etc/kvdb/server.key"
+    ca_file: "/etc/kvdb/ca.pem"
+    min_version: "1.3"
+    require_client_cert: true
 logging:
   level: "debug"
   output: "/var/log/debug.log"
+security:
+  passwords:
+    - "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+    - "pbkdf2:600000:669a79fdd6324de793c2d84beb653c94:3bc5184f9a326705d8cccf2b2a59eb0d3bbd3bb7192e956c330b65da4ba3db83"
+  max_auth_failures: 3
+  auth_block_duration: "30s"
+  acl_file: "/etc/kvdb/users.acl"
+metrics:
+  enabled: true
+  address: "0.0.0.0:9200"
+  path: "/prometheus"
+slowlog:
+  threshold: "50ms"
+  max_len: 16
 `
 
 	reader := bytes.NewBufferString(yamlData)
 	config, err := LoadConfig(reader)
 	require.NoError(t, err)
 
-	assert.Equal(t, "redis", config.Engine.Type)
+	assert.Equal(t, "in_memory", config.Engine.Type)
 	assert.Equal(t, "0.0.0.0:8081", config.Network.Address)
 	assert.Equal(t, 100, config.Network.MaxConnections)
 	assert.Equal(t, "4KB", config.Network.MaxMessageSize)
 	assert.Equal(t, uint64(4000), config.Network.MaxMessageSizeBytes)
 	assert.Equal(t, 2*time.Minute, config.Network.IdleTimeout)
+	assert.Equal(t, os.FileMode(0660), config.Network.UnixSocketPermMode)
+	assert.Equal(t, TLSConfig{
+		Enabled:           true,
+		CertFile:          "/etc/kvdb/server.pem",
+		KeyFile:           "/etc/kvdb/server.key",
+		CAFile:            "/etc/kvdb/ca.pem",
+		MinVersion:        "1.3",
+		RequireClientCert: true,
+	}, config.Network.TLS)
 	assert.Equal(t, "debug", config.Logging.Level)
 	assert.Equal(t, "/var/log/debug.log", config.Logging.Output)
+	assert.Equal(t, []string{
+		"2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b",
+		"pbkdf2:600000:669a79fdd6324de793c2d84beb653c94:3bc5184f9a326705d8cccf2b2a59eb0d3bbd3bb7192e956c330b65da4ba3db83",
+	}, config.Security.Passwords)
+	assert.Equal(t, 3, config.Security.MaxAuthFailures)
+	assert.Equal(t, 30*time.Second, config.Security.AuthBlockDuration)
+	assert.Equal(t, "/etc/kvdb/users.acl", config.Security.ACLFile)
+	assert.Equal(t, MetricsConfig{Enabled: true, Address: "0.0.0.0:9200", Path: "/prometheus"}, config.Metrics)
+	assert.Equal(t, SlowLogConfig{Threshold: 50 * time.Millisecond, MaxLen: 16}, config.SlowLog)
+}
+
+// TestLoadConfig_Listeners tests that listeners inherit top level network values.
+func TestLoadConfig_Listeners(t *testing.T) {
+	yamlData := `
+network:
+  max_connections: 10
+  idle_timeout: "2m"
+  listeners:
+    - name: "public"
+      address: "0.0.0.0:8443"
+      protocol: "resp"
+      max_message_size: "1KB"
+      allowed_categories: ["read", "write"]
+      tls:
+        enabled: true
+        cert_file: "/etc/kvdb/server.pem"
+        key_file: "/etc/kvdb/server.key"
+    - address: "127.0.0.1:8081"
+      max_connections: 0
+`
+
+	reader := bytes.NewBufferString(yamlData)
+	config, err := LoadConfig(reader)
+	require.NoError(t, err)
+
+	listeners := config.Network.EffectiveListeners()
+	require.Len(t, listeners, 2)
+
+	public := listeners[0]
+	assert.Equal(t, "public", public.Name)
+	assert.Equal(t, "0.0.0.0:8443", public.Address)
+	assert.Equal(t, "resp", public.Protocol)
+	assert.Equal(t, 10, public.MaxConnections)
+	assert.Equal(t, uint64(1000), public.MaxMessageSizeBytes)
+	assert.Equal(t, 2*time.Minute, public.IdleTimeout)
+	assert.Equal(t, []string{"read", "write"}, public.AllowedCategories)
+	assert.True(t, public.TLS.Enabled)
+	assert.Equal(t, "1.2", public.TLS.MinVersion)
+
+	admin := listeners[1]
+	assert.Equal(t, "127.0.0.1:8081", admin.Name)
+	assert.Equal(t, "text", admin.Protocol)
+	assert.Equal(t, 0, admin.MaxConnections)
+	assert.Equal(t, uint64(2000), admin.MaxMessageSizeBytes)
+	assert.Equal(t, []string{"all"}, admin.AllowedCategories)
+	assert.False(t, admin.TLS.Enabled)
+}
+
+// TestLoadConfig_LogSinks tests that log sinks inherit top level logging values.
+func TestLoadConfig_LogSinks(t *testing.T) {
+	yamlData := `
+logging:
+  level: "info"
+  encoding: "console"
+  rotation:
+    max_size: "10MB"
+    max_backups: 3
+  sinks:
+    - output: "stdout"
+    - output: "/var/log/kvdb/debug.log"
+      level: "debug"
+      encoding: "json"
+      rotation:
+        max_age: "24h"
+        compress: true
+`
+
+	config, err := LoadConfig(bytes.NewBufferString(yamlData))
+	require.NoError(t, err)
+
+	sinks := config.Logging.EffectiveSinks()
+	require.Len(t, sinks, 2)
+
+	assert.Equal(t, LogSinkConfig{
+		Level:    "info",
+		Output:   "stdout",
+		Encoding: "console",
+		Rotation: RotationConfig{MaxSize: "10MB", MaxSizeBytes: 10 * 1000 * 1000, MaxBackups: 3},
+	}, sinks[0])
+	assert.Equal(t, LogSinkConfig{
+		Level:    "debug",
+		Output:   "/var/log/kvdb/debug.log",
+		Encoding: "json",
+		Rotation: RotationConfig{
+			MaxSize:      "10MB",
+			MaxSizeBytes: 10 * 1000 * 1000,
+			MaxAge:       24 * time.Hour,
+			MaxBackups:   3,
+			Compress:     true,
+		},
+	}, sinks[1])
+
+	_, err = LoadConfig(bytes.NewBufferString("logging:\n  sinks:\n    - encoding: \"xml\"\n      rotation:\n        max_backups: -1\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "logging.sinks[0].encoding: unsupported encoding")
+	assert.Contains(t, err.Error(), "logging.sinks[0].rotation.max_backups: must be non-negative")
+}
+
+// TestLoadConfig_HTTP tests loading of the HTTP gateway section.
+func TestLoadConfig_HTTP(t *testing.T) {
+	yamlData := `
+network:
+  listeners:
+    - name: "public"
+      address: "0.0.0.0:8443"
+http:
+  enabled: true
+  address: "0.0.0.0:9090"
+  read_timeout: "5s"
+  write_timeout: "15s"
+  max_body_size: "64KB"
+  allowed_categories: ["read"]
+  websocket:
+    enabled: true
+    listener: "public"
+    allowed_origins: ["https://app.example.com"]
+`
+
+	reader := bytes.NewBufferString(yamlData)
+	config, err := LoadConfig(reader)
+	require.NoError(t, err)
+
+	assert.True(t, config.HTTP.Enabled)
+	assert.Equal(t, "0.0.0.0:9090", config.HTTP.Address)
+	assert.Equal(t, 5*time.Second, config.HTTP.ReadTimeout)
+	assert.Equal(t, 15*time.Second, config.HTTP.WriteTimeout)
+	assert.Equal(t, uint64(64000), config.HTTP.MaxBodySizeBytes)
+	assert.Equal(t, []string{"read"}, config.HTTP.AllowedCategories)
+	assert.Equal(t, WebSocketConfig{
+		Enabled:        true,
+		Listener:       "public",
+		AllowedOrigins: []string{"https://app.example.com"},
+	}, config.HTTP.WebSocket)
+
+	_, err = LoadConfig(bytes.NewBufferString("http:\n  max_body_size: \"invalid\"\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "http.max_body_size: failed parse bytes")
+
+	_, err = LoadConfig(bytes.NewBufferString(`
+network:
+  tls:
+    enabled: true
+    cert_file: "/etc/kvdb/server.pem"
+    key_file: "/etc/kvdb/server.key"
+http:
+  enabled: true
+  websocket:
+    enabled: true
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `http.websocket.listener: listener "default" uses tls`)
+}
+
+// TestLoadConfig_SingleListener tests that top level network values form the only listener.
+func TestLoadConfig_SingleListener(t *testing.T) {
+	config := &Config{}
+	config.setDefaults()
+
+	listeners := config.Network.EffectiveListeners()
+	require.Len(t, listeners, 1)
+	assert.Equal(t, "default", listeners[0].Name)
+	assert.Equal(t, "127.0.0.1:8080", listeners[0].Address)
 }
 
 // TestLoadConfig_ReadError tests handling of a read error.
@@ -93,6 +310,125 @@ network:
 	assert.Contains(t, err.Error(), "failed parse bytes")
 }
 
+// TestLoadConfig_ParseUnixSocketPermError tests handling of an error when parsing unix_socket_perm.
+func TestLoadConfig_ParseUnixSocketPermError(t *testing.T) {
+	invalidYAML := `
+network:
+  address: "unix:///var/run/kvdb.sock"
+  unix_socket_perm: "rw-rw----"
+`
+
+	reader := bytes.NewBufferString(invalidYAML)
+	_, err := LoadConfig(reader)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed parse unix socket permissions")
+}
+
+// TestLoadConfig_UnknownFields tests that unknown keys are rejected, including keys of listeners.
+func TestLoadConfig_UnknownFields(t *testing.T) {
+	invalidYAML := `
+engine:
+  typo: "in_memory"
+network:
+  max_conections: 10
+  tls:
+    cert: "/etc/kvdb/server.pem"
+  listeners:
+    - address: "127.0.0.1:8081"
+      protocl: "resp"
+`
+
+	_, err := LoadConfig(bytes.NewBufferString(invalidYAML))
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 4)
+	for _, field := range []string{"typo", "max_conections", "cert", "protocl"} {
+		assert.Contains(t, err.Error(), "field "+field+" not found")
+	}
+}
+
+// TestLoadConfig_Validation tests that every invalid value is reported with its path.
+func TestLoadConfig_Validation(t *testing.T) {
+	invalidYAML := `
+engine:
+  type: "redis"
+network:
+  address: "localhost"
+  protocol: "http"
+  max_connections: -1
+  idle_timeout: 0s
+  tls:
+    enabled: true
+    min_version: "1.4"
+  allowed_categories: ["read", "everything"]
+  listeners:
+    - name: "public"
+      address: "0.0.0.0:8443"
+    - name: "public"
+      address: "0.0.0.0:99999"
+logging:
+  level: "verbose"
+security:
+  passwords: ["secret", "pbkdf2:1:aa:bb"]
+  max_auth_failures: -3
+http:
+  enabled: true
+  read_timeout: -1s
+  allowed_categories: ["writes"]
+  websocket:
+    enabled: true
+    listener: "private"
+    allowed_origins: ["*", "app.example.com"]
+metrics:
+  enabled: true
+  path: "metrics"
+slowlog:
+  max_len: -1
+audit:
+  enabled: true
+  buffer_size: 0
+  full_policy: "drop_all"
+`
+
+	_, err := LoadConfig(bytes.NewBufferString(invalidYAML))
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+
+	expectedPaths := []string{
+		"engine.type",
+		"network.address",
+		"network.protocol",
+		"network.max_connections",
+		"network.idle_timeout",
+		"network.tls.min_version",
+		"network.tls",
+		"network.allowed_categories[1]",
+		"network.listeners[1].name",
+		"network.listeners[1].address",
+		"logging.level",
+		"security.passwords[0]",
+		"security.passwords[1]",
+		"security.max_auth_failures",
+		"http.read_timeout",
+		"http.allowed_categories[0]",
+		"http.websocket.listener",
+		"http.websocket.allowed_origins[1]",
+		"metrics.path",
+		"slowlog.max_len",
+		"audit.buffer_size",
+		"audit.full_policy",
+	}
+	for _, path := range expectedPaths {
+		assert.True(t, slices.ContainsFunc(validationErr.Problems, func(problem string) bool {
+			return strings.HasPrefix(problem, path+": ")
+		}), "no problem for %s in %v", path, validationErr.Problems)
+	}
+}
+
 // errorReader is a mock io.Reader that always returns an error.
 type errorReader struct {
 	err error
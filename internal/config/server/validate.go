@@ -0,0 +1,338 @@
+package server
+
+import (
+	"encoding/hex"
+	"fmt"
+	"kvdb/internal/audit"
+	"kvdb/internal/model"
+	"kvdb/internal/network/endpoint"
+	"kvdb/internal/network/tlsconf"
+	"kvdb/internal/security/acl"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	humanize "github.com/dustin/go-humanize"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	engines      = []string{"in_memory"}
+	logEncodings = []string{"json", "console"}
+	protocols    = []string{"text", "framed", "resp"} // Codecs of internal/rpc/query.
+)
+
+// ValidationError lists every problem found in config, so all of them can be
+// fixed at once.
+type ValidationError struct {
+	Problems []string // Prefixed with YAML path of value, like network.listeners[0].address.
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(path, format string, args ...any) {
+	problem := fmt.Sprintf(format, args...)
+	if path != "" {
+		problem = path + ": " + problem
+	}
+	v.problems = append(v.problems, problem)
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// validate checks values and fills fields derived from human readable values.
+func (c *Config) validate(v *validator) {
+	if !slices.Contains(engines, c.Engine.Type) {
+		v.addf("engine.type", "unsupported engine %q, want one of %s", c.Engine.Type, strings.Join(engines, ", "))
+	}
+
+	c.Network.ListenerConfig.validate(v, "network")
+
+	names := make(map[string]bool, len(c.Network.Listeners))
+	for i := range c.Network.Listeners {
+		listener := &c.Network.Listeners[i]
+		path := fmt.Sprintf("network.listeners[%d]", i)
+
+		listener.validate(v, path)
+		if names[listener.Name] {
+			v.addf(path+".name", "duplicate listener name %q", listener.Name)
+		}
+		names[listener.Name] = true
+	}
+
+	c.Logging.LogSinkConfig.validate(v, "logging")
+	for i := range c.Logging.Sinks {
+		c.Logging.Sinks[i].validate(v, fmt.Sprintf("logging.sinks[%d]", i))
+	}
+
+	c.Security.validate(v)
+	c.HTTP.validate(v)
+
+	if c.HTTP.Enabled && c.HTTP.WebSocket.Enabled {
+		listeners := c.Network.EffectiveListeners()
+		i := 0
+		if c.HTTP.WebSocket.Listener != "" {
+			i = slices.IndexFunc(listeners, func(l ListenerConfig) bool {
+				return l.Name == c.HTTP.WebSocket.Listener
+			})
+		}
+
+		switch {
+		case i < 0:
+			v.addf("http.websocket.listener", "unknown listener %q", c.HTTP.WebSocket.Listener)
+		case listeners[i].TLS.Enabled:
+			// Websocket is served over plain HTTP, borrowing auth of a TLS
+			// listener would let clients skip its client certificates.
+			v.addf("http.websocket.listener", "listener %q uses tls, websocket is served over plain http", listeners[i].Name)
+		}
+	}
+
+	if c.Metrics.Enabled {
+		validateHostPort(v, "metrics.address", c.Metrics.Address)
+		if !strings.HasPrefix(c.Metrics.Path, "/") {
+			v.addf("metrics.path", "must start with /, got %q", c.Metrics.Path)
+		}
+	}
+
+	if c.SlowLog.MaxLen < 0 {
+		v.addf("slowlog.max_len", "must be non-negative, got %d", c.SlowLog.MaxLen)
+	}
+
+	c.Audit.validate(v)
+}
+
+func (c *ListenerConfig) validate(v *validator, path string) {
+	if c.Name == "" {
+		v.addf(path+".name", "must not be empty")
+	}
+
+	network, address := endpoint.Parse(c.Address)
+	if network == endpoint.NetworkUnix {
+		if address == "" {
+			v.addf(path+".address", "want unix socket path")
+		}
+	} else {
+		validateHostPort(v, path+".address", address)
+	}
+
+	if !slices.Contains(protocols, c.Protocol) {
+		v.addf(path+".protocol", "unsupported protocol %q, want one of %s", c.Protocol, strings.Join(protocols, ", "))
+	}
+	if c.MaxConnections < 0 {
+		v.addf(path+".max_connections", "must be non-negative, 0 means unlimited, got %d", c.MaxConnections)
+	}
+
+	maxMessageSizeBytes, err := humanize.ParseBytes(c.MaxMessageSize)
+	switch {
+	case err != nil:
+		v.addf(path+".max_message_size", "failed parse bytes %s: %s", c.MaxMessageSize, err)
+	case maxMessageSizeBytes == 0:
+		v.addf(path+".max_message_size", "must be positive")
+	}
+	c.MaxMessageSizeBytes = maxMessageSizeBytes
+
+	if c.IdleTimeout <= 0 {
+		v.addf(path+".idle_timeout", "must be positive, got %s", c.IdleTimeout)
+	}
+
+	if c.UnixSocketPerm != "" {
+		perm, err := strconv.ParseUint(c.UnixSocketPerm, 8, 32)
+		if err != nil || perm > uint64(os.ModePerm) {
+			v.addf(path+".unix_socket_perm", "failed parse unix socket permissions %s: want octal mode like 0660", c.UnixSocketPerm)
+		}
+		c.UnixSocketPermMode = os.FileMode(perm)
+	}
+
+	if _, err := tlsconf.ParseVersion(c.TLS.MinVersion); err != nil {
+		v.addf(path+".tls.min_version", "%s", err)
+	}
+	if c.TLS.Enabled && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		v.addf(path+".tls", "cert_file and key_file are required when tls is enabled")
+	}
+	if c.TLS.Enabled && c.TLS.RequireClientCert && c.TLS.CAFile == "" {
+		v.addf(path+".tls.ca_file", "required to verify client certificates")
+	}
+
+	validateCategories(v, path+".allowed_categories", c.AllowedCategories)
+}
+
+func validateCategories(v *validator, path string, names []string) {
+	for i, name := range names {
+		if _, ok := model.ParseCategory(name); !ok {
+			v.addf(fmt.Sprintf("%s[%d]", path, i), "unknown category %q, want read, write, admin or all", name)
+		}
+	}
+}
+
+func (c *LogSinkConfig) validate(v *validator, path string) {
+	if _, err := zapcore.ParseLevel(c.Level); err != nil {
+		v.addf(path+".level", "unknown level %q", c.Level)
+	}
+	if c.Output == "" {
+		v.addf(path+".output", "must be stdout, stderr or file path")
+	}
+	if !slices.Contains(logEncodings, c.Encoding) {
+		v.addf(path+".encoding", "unsupported encoding %q, want one of %s", c.Encoding, strings.Join(logEncodings, ", "))
+	}
+
+	c.Rotation.validate(v, path+".rotation")
+}
+
+func (c *RotationConfig) validate(v *validator, path string) {
+	if c.MaxSize != "" {
+		maxSizeBytes, err := humanize.ParseBytes(c.MaxSize)
+		if err != nil {
+			v.addf(path+".max_size", "failed parse bytes %s: %s", c.MaxSize, err)
+		}
+		c.MaxSizeBytes = maxSizeBytes
+	}
+	if c.MaxAge < 0 {
+		v.addf(path+".max_age", "must be non-negative, 0 disables rotation by age, got %s", c.MaxAge)
+	}
+	if c.MaxBackups < 0 {
+		v.addf(path+".max_backups", "must be non-negative, 0 keeps all files, got %d", c.MaxBackups)
+	}
+}
+
+func (c *AuditConfig) validate(v *validator) {
+	c.Rotation.validate(v, "audit.rotation")
+
+	if !c.Enabled {
+		return
+	}
+
+	if c.Output == "" {
+		v.addf("audit.output", "must be stdout or file path")
+	}
+	if c.BufferSize <= 0 {
+		v.addf("audit.buffer_size", "must be positive, got %d", c.BufferSize)
+	}
+	if !slices.Contains(audit.Policies, audit.Policy(c.FullPolicy)) {
+		v.addf("audit.full_policy", "unsupported policy %q, want one of block, drop_newest, drop_oldest", c.FullPolicy)
+	}
+}
+
+func (c *SecurityConfig) validate(v *validator) {
+	for i, password := range c.Passwords {
+		if strings.HasPrefix(password, "pbkdf2:") {
+			if err := acl.ValidateHash(password); err != nil {
+				v.addf(fmt.Sprintf("security.passwords[%d]", i), "want pbkdf2 hash printed by -hash-password")
+			}
+		} else if decoded, err := hex.DecodeString(password); err != nil || len(decoded) != 32 {
+			v.addf(fmt.Sprintf("security.passwords[%d]", i), "want pbkdf2 hash printed by -hash-password or hex encoded sha256")
+		}
+	}
+	if c.MaxAuthFailures < 0 {
+		v.addf("security.max_auth_failures", "must be non-negative, 0 disables blocking, got %d", c.MaxAuthFailures)
+	}
+	if c.MaxAuthFailures > 0 && c.AuthBlockDuration <= 0 {
+		v.addf("security.auth_block_duration", "must be positive, got %s", c.AuthBlockDuration)
+	}
+}
+
+func (c *HTTPConfig) validate(v *validator) {
+	maxBodySizeBytes, err := humanize.ParseBytes(c.MaxBodySize)
+	switch {
+	case err != nil:
+		v.addf("http.max_body_size", "failed parse bytes %s: %s", c.MaxBodySize, err)
+	case maxBodySizeBytes == 0:
+		v.addf("http.max_body_size", "must be positive")
+	}
+	c.MaxBodySizeBytes = maxBodySizeBytes
+	validateCategories(v, "http.allowed_categories", c.AllowedCategories)
+
+	if !c.Enabled {
+		return
+	}
+
+	validateHostPort(v, "http.address", c.Address)
+	if c.ReadTimeout <= 0 {
+		v.addf("http.read_timeout", "must be positive, got %s", c.ReadTimeout)
+	}
+	if c.WriteTimeout <= 0 {
+		v.addf("http.write_timeout", "must be positive, got %s", c.WriteTimeout)
+	}
+
+	for i, origin := range c.WebSocket.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+			v.addf(fmt.Sprintf("http.websocket.allowed_origins[%d]", i), "want scheme://host[:port] or *, got %q", origin)
+		}
+	}
+}
+
+func validateHostPort(v *validator, path, address string) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		v.addf(path, "want host:port, got %q", address)
+		return
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		v.addf(path, "invalid port %q", port)
+	}
+}
+
+// unknownFields returns errors for keys of YAML mapping which are not fields of
+// struct t, including nested mappings. It keeps decoding strict for types with
+// custom unmarshalers, which are decoded without known fields check.
+func unknownFields(node *yaml.Node, t reflect.Type) []string {
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := make(map[string]reflect.Type)
+		collectFields(t, fields)
+
+		errs := make([]string, 0)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("line %d: field %s not found in type %s", key.Line, key.Value, t))
+				continue
+			}
+			errs = append(errs, unknownFields(value, fieldType)...)
+		}
+		return errs
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		errs := make([]string, 0)
+		for _, item := range node.Content {
+			errs = append(errs, unknownFields(item, t.Elem())...)
+		}
+		return errs
+	}
+
+	return nil
+}
+
+// collectFields maps YAML names of struct fields to their types.
+func collectFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, inline := yamlName(field)
+		switch {
+		case name == "-":
+		case inline:
+			collectFields(field.Type, fields)
+		default:
+			fields[name] = field.Type
+		}
+	}
+}
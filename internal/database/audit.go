@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"kvdb/internal/audit"
+	"kvdb/internal/model"
+	"kvdb/internal/security/acl"
+	"kvdb/internal/session"
+	"kvdb/internal/trace"
+	"time"
+)
+
+// auditLog records commands which change data or server state.
+type auditLog interface {
+	Record(entry audit.Entry)
+}
+
+// WithAudit records every write and admin command, including denied and failed ones.
+func (db *Database) WithAudit(log auditLog) *Database {
+	db.auditLog = log
+	return db
+}
+
+func (db *Database) recordAudit(ctx context.Context, query model.Query, start time.Time, err error) {
+	if db.auditLog == nil {
+		return
+	}
+
+	category := query.Command.Category()
+	if category != model.CategoryWrite && category != model.CategoryAdmin {
+		return
+	}
+
+	entry := audit.Entry{
+		Time:    start.UTC(),
+		User:    acl.DefaultUser,
+		Command: query.Command.String(),
+	}
+	if ids, ok := trace.FromContext(ctx); ok {
+		entry.CommandID = ids.CommandID
+		entry.TraceID = ids.TraceID
+	}
+	if sess, ok := session.FromContext(ctx); ok {
+		entry.ClientID = sess.ID()
+		entry.Addr = sess.RemoteAddr()
+		entry.Identity = sess.Identity()
+		if user := sess.User(); user != "" {
+			entry.User = user
+		}
+	}
+
+	switch {
+	case category == model.CategoryWrite && len(query.Args) > 0:
+		entry.Key = query.Args[0]
+		if len(query.Args) > 1 {
+			entry.Value = query.Args[1]
+		}
+	default:
+		entry.Args = redactArgs(query)
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	db.auditLog.Record(entry)
+}
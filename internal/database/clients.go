@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kvdb/internal/model"
+	"kvdb/internal/security/acl"
+	"kvdb/internal/session"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	subcommandID      = "id"
+	subcommandSETNAME = "setname"
+	subcommandGETNAME = "getname"
+	subcommandKILL    = "kill"
+	// subcommandLIST is shared with ACL.
+)
+
+var (
+	ErrClientsDisabled = model.WithCode(model.CodeDisabled, errors.New("client registry is disabled"))
+	ErrNoSuchClient    = model.WithCode(model.CodeNotFound, errors.New("no such client"))
+)
+
+// clientRegistry keeps sessions of connected clients.
+type clientRegistry interface {
+	List() []*session.Session
+	Kill(id uint64) bool
+	KillAddr(addr string) bool
+}
+
+// WithClients enables CLIENT LIST and CLIENT KILL for clients in registry.
+func (db *Database) WithClients(clients clientRegistry) *Database {
+	db.clients = clients
+	return db
+}
+
+// execCLIENT handles CLIENT ID, CLIENT SETNAME name, CLIENT GETNAME, CLIENT LIST
+// and CLIENT KILL id|addr.
+func (db *Database) execCLIENT(ctx context.Context, query model.Query) (model.Value, error) {
+	if len(query.Args) < model.CommandCLIENTMinArgsLen {
+		return model.Value{}, fmt.Errorf("%w: want at least %d args", ErrInvalidArgs, model.CommandCLIENTMinArgsLen)
+	}
+
+	subcommand, args := strings.ToLower(query.Args[0]), query.Args[1:]
+	switch subcommand {
+	case subcommandID, subcommandGETNAME, subcommandSETNAME:
+		sess, ok := session.FromContext(ctx)
+		if !ok {
+			return model.Value{}, ErrNoSuchClient
+		}
+		return execClientSelf(sess, subcommand, args)
+	case subcommandLIST:
+		if len(args) != 0 {
+			return model.Value{}, fmt.Errorf("%w: want no args", ErrInvalidArgs)
+		}
+		if db.clients == nil {
+			return model.Value{}, ErrClientsDisabled
+		}
+
+		now := time.Now()
+		sessions := db.clients.List()
+		lines := make([]string, 0, len(sessions))
+		for _, sess := range sessions {
+			lines = append(lines, formatClient(sess, now))
+		}
+		return model.StringsValue(lines), nil
+	case subcommandKILL:
+		if len(args) != 1 {
+			return model.Value{}, fmt.Errorf("%w: want client id or address", ErrInvalidArgs)
+		}
+		if db.clients == nil {
+			return model.Value{}, ErrClientsDisabled
+		}
+
+		killed := false
+		if id, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+			killed = db.clients.Kill(id)
+		} else {
+			killed = db.clients.KillAddr(args[0])
+		}
+		if !killed {
+			return model.Value{}, fmt.Errorf("%w: %s", ErrNoSuchClient, args[0])
+		}
+		return model.NoneValue(), nil
+	default:
+		return model.Value{}, fmt.Errorf("%w: unknown client subcommand %s", ErrInvalidArgs, query.Args[0])
+	}
+}
+
+// execClientSelf handles CLIENT subcommands about the calling client.
+func execClientSelf(sess *session.Session, subcommand string, args []string) (model.Value, error) {
+	switch subcommand {
+	case subcommandSETNAME:
+		if len(args) != 1 {
+			return model.Value{}, fmt.Errorf("%w: want client name", ErrInvalidArgs)
+		}
+		if strings.ContainsFunc(args[0], func(r rune) bool { return r <= ' ' }) {
+			return model.Value{}, fmt.Errorf("%w: client name can't contain spaces", ErrInvalidArgs)
+		}
+		sess.SetName(args[0])
+		return model.NoneValue(), nil
+	case subcommandGETNAME:
+		if len(args) != 0 {
+			return model.Value{}, fmt.Errorf("%w: want no args", ErrInvalidArgs)
+		}
+		if name := sess.Name(); name != "" {
+			return model.StringValue(name), nil
+		}
+		return model.NilValue(), nil
+	default:
+		if len(args) != 0 {
+			return model.Value{}, fmt.Errorf("%w: want no args", ErrInvalidArgs)
+		}
+		return model.IntValue(int64(sess.ID())), nil
+	}
+}
+
+// formatClient formats client as "key=value" fields of CLIENT LIST output.
+func formatClient(sess *session.Session, now time.Time) string {
+	user := sess.User()
+	if user == "" {
+		user = acl.DefaultUser
+	}
+
+	command, lastActiveAt := sess.LastCommand()
+	if command == "" {
+		command = "-"
+	}
+
+	bytesIn, bytesOut := sess.Bytes()
+
+	return fmt.Sprintf(
+		"id=%d addr=%s name=%s user=%s connected_at=%s age=%d idle=%d cmd=%s bytes_in=%d bytes_out=%d",
+		sess.ID(),
+		sess.RemoteAddr(),
+		sess.Name(),
+		user,
+		sess.ConnectedAt().UTC().Format(time.RFC3339),
+		int64(now.Sub(sess.ConnectedAt()).Seconds()),
+		int64(now.Sub(lastActiveAt).Seconds()),
+		command,
+		bytesIn,
+		bytesOut,
+	)
+}
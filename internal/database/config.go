@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	parser "kvdb/internal/compute"
+	"kvdb/internal/config/dynamic"
+	"kvdb/internal/model"
+	"kvdb/internal/trace"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	subcommandSET     = "set"
+	subcommandREWRITE = "rewrite"
+	// subcommandGET is shared with SLOWLOG.
+)
+
+var ErrConfigDisabled = model.WithCode(model.CodeDisabled, errors.New("runtime config is disabled"))
+
+// runtimeConfig keeps parameters changeable while server is running.
+type runtimeConfig interface {
+	Get(pattern string) []dynamic.Param
+	Set(name, value string) error
+	Rewrite() error
+}
+
+// WithConfig enables CONFIG command for parameters of config.
+func (db *Database) WithConfig(config runtimeConfig) *Database {
+	db.config = config
+	return db
+}
+
+// execCONFIG handles CONFIG GET pattern, CONFIG SET name value and CONFIG REWRITE.
+func (db *Database) execCONFIG(ctx context.Context, query model.Query) (model.Value, error) {
+	if len(query.Args) < model.CommandCONFIGMinArgsLen {
+		return model.Value{}, fmt.Errorf("%w: want at least %d args", ErrInvalidArgs, model.CommandCONFIGMinArgsLen)
+	}
+	if db.config == nil {
+		return model.Value{}, ErrConfigDisabled
+	}
+
+	subcommand, args := strings.ToLower(query.Args[0]), query.Args[1:]
+	switch subcommand {
+	case subcommandGET:
+		if len(args) != 1 {
+			return model.Value{}, fmt.Errorf("%w: want pattern", ErrInvalidArgs)
+		}
+
+		params := db.config.Get(args[0])
+		if len(params) == 0 {
+			return model.NilValue(), nil
+		}
+
+		lines := make([]string, 0, len(params))
+		for _, param := range params {
+			lines = append(lines, param.Name+" "+parser.Quote(param.Value))
+		}
+		return model.StringsValue(lines), nil
+	case subcommandSET:
+		if len(args) != 2 {
+			return model.Value{}, fmt.Errorf("%w: want name and value", ErrInvalidArgs)
+		}
+		if err := db.config.Set(args[0], args[1]); err != nil {
+			return model.Value{}, err
+		}
+
+		trace.Logger(ctx, db.logger).Info("config changed", zap.String("name", args[0]), zap.String("value", args[1]))
+		return model.NoneValue(), nil
+	case subcommandREWRITE:
+		if len(args) != 0 {
+			return model.Value{}, fmt.Errorf("%w: want no args", ErrInvalidArgs)
+		}
+		if err := db.config.Rewrite(); err != nil {
+			return model.Value{}, err
+		}
+		return model.NoneValue(), nil
+	default:
+		return model.Value{}, fmt.Errorf("%w: unknown config subcommand %s", ErrInvalidArgs, query.Args[0])
+	}
+}
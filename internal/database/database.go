@@ -4,19 +4,51 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"kvdb/internal/glob"
+	"kvdb/internal/metrics"
 	"kvdb/internal/model"
+	"kvdb/internal/pubsub"
+	"kvdb/internal/security/acl"
+	"kvdb/internal/session"
+	"kvdb/internal/trace"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
 
+// monitorBufferSize is a number of queries buffered for slow monitor before they are dropped.
+const monitorBufferSize = 1024
+
+const (
+	subcommandSETUSER = "setuser"
+	subcommandGETUSER = "getuser"
+	subcommandDELUSER = "deluser"
+	subcommandLIST    = "list"
+	subcommandWHOAMI  = "whoami"
+)
+
+// Conditions of SET.
 const (
-	messageOK         = "ok"
-	messageEmptyValue = "nil"
+	setIfNotExists = "nx"
+	setIfExists    = "xx"
+)
+
+// Replies of TTL for keys without time left.
+const (
+	ttlNoExpiration = -1
+	ttlMissingKey   = -2
 )
 
 var (
-	ErrUnknownCommand = errors.New("unknown command")
-	ErrInvalidArgs    = errors.New("invalid arguments")
+	ErrUnknownCommand = model.WithCode(model.CodeUnknownCmd, errors.New("unknown command"))
+	ErrInvalidArgs    = model.WithCode(model.CodeWrongArgs, errors.New("invalid arguments"))
+	ErrACLDisabled    = model.WithCode(model.CodeDisabled, errors.New("acl is disabled"))
+	ErrNotAllowed     = model.WithCode(model.CodeNoPerm, errors.New("command not allowed"))
+	ErrNotInteger     = model.WithCode(model.CodeWrongType, errors.New("value is not an integer"))
+	ErrOverflow       = model.WithCode(model.CodeWrongArgs, errors.New("increment would overflow"))
 )
 
 //go:generate mockery --name compute --exported --case underscore --with-expecter
@@ -29,16 +61,45 @@ type storage interface {
 	Get(ctx context.Context, key string) (string, bool)
 	Set(ctx context.Context, key, value string)
 	Del(ctx context.Context, key string)
+	Keys(ctx context.Context, pattern string) []string
+	Update(ctx context.Context, key string, fn func(value string, ok bool) (string, bool))
+	Expire(ctx context.Context, key string, ttl time.Duration) bool
+	TTL(ctx context.Context, key string) (time.Duration, bool)
+}
+
+//go:generate mockery --name accessControl --exported --case underscore --with-expecter
+type accessControl interface {
+	Check(user string, query model.Query) error
+	SetUser(name string, rules []string) error
+	GetUser(name string) (string, error)
+	DelUser(name string) error
+	List() []string
 }
 
 type Database struct {
-	logger      *zap.Logger
-	compute     compute
-	storage     storage
-	commandsMap map[model.Command]commandExecFunc
+	logger       *zap.Logger
+	compute      compute
+	storage      storage
+	acl          accessControl
+	clients      clientRegistry
+	config       runtimeConfig
+	events       *pubsub.Hub[model.KeyEvent]
+	queries      *pubsub.Hub[model.QueryEvent]
+	metrics      *databaseMetrics
+	stats        stats
+	slowLog      *slowLog
+	auditLog     auditLog
+	infoSections map[string]InfoFunc
+	commandsMap  map[model.Command]commandExecFunc
+}
+
+type databaseMetrics struct {
+	commands *metrics.CounterVec
+	errors   *metrics.CounterVec
+	latency  *metrics.HistogramVec
 }
 
-type commandExecFunc func(ctx context.Context, query model.Query) (string, error)
+type commandExecFunc func(ctx context.Context, query model.Query) (model.Value, error)
 
 func New(
 	logger *zap.Logger,
@@ -49,73 +110,455 @@ func New(
 		logger:  logger,
 		compute: compute,
 		storage: storage,
+		events:  pubsub.New[model.KeyEvent](),
+		queries: pubsub.New[model.QueryEvent](),
+
+		infoSections: make(map[string]InfoFunc),
+		slowLog:      newSlowLog(DefaultSlowLogThreshold, DefaultSlowLogMaxLen),
 	}
 	db.commandsMap = map[model.Command]commandExecFunc{
-		model.CommandGET: db.execGET,
-		model.CommandSET: db.execSET,
-		model.CommandDEL: db.execDEL,
+		model.CommandGET:     db.execGET,
+		model.CommandSET:     db.execSET,
+		model.CommandDEL:     db.execDEL,
+		model.CommandACL:     db.execACL,
+		model.CommandINFO:    db.execINFO,
+		model.CommandSLOWLOG: db.execSLOWLOG,
+		model.CommandCLIENT:  db.execCLIENT,
+		model.CommandCONFIG:  db.execCONFIG,
+		model.CommandINCR:    db.execINCR,
+		model.CommandINCRBY:  db.execINCR,
+		model.CommandEXPIRE:  db.execEXPIRE,
+		model.CommandTTL:     db.execTTL,
 	}
 
 	return db
 }
 
-func (db *Database) RunCommand(ctx context.Context, rawQuery string) string {
-	zapArgs := []zap.Field{
-		zap.String("raw_query", rawQuery),
+// WithACL checks user permissions before running commands.
+func (db *Database) WithACL(accessControl accessControl) *Database {
+	db.acl = accessControl
+	return db
+}
+
+// WithMetrics collects number of commands, errors and latency per command.
+func (db *Database) WithMetrics(registry *metrics.Registry) *Database {
+	db.metrics = &databaseMetrics{
+		commands: registry.Counter("kvdb_commands_total", "Number of executed commands.", "command"),
+		errors:   registry.Counter("kvdb_command_errors_total", "Number of failed commands.", "command"),
+		latency: registry.Histogram(
+			"kvdb_command_duration_seconds", "Latency of commands.", metrics.LatencyBuckets, "command",
+		),
 	}
+	return db
+}
+
+// RunCommand parses and runs query. Failures are reported in result, its text
+// rendering matches messages of previous versions.
+func (db *Database) RunCommand(ctx context.Context, rawQuery string) model.Result {
+	// Every log entry and monitor get the same redacted query.
+	loggedQuery := redactQuery(rawQuery)
+	zapArgs := append(trace.Fields(ctx), zap.String("raw_query", loggedQuery))
 	db.logger.Debug("run command", zapArgs...)
 
+	start := time.Now()
+	query, runErr := model.Query{Command: model.CommandUNK}, error(nil)
+	defer func() {
+		db.observe(ctx, query, start, runErr)
+	}()
+
 	query, err := db.compute.Parse(rawQuery)
+	db.publishQuery(ctx, start, loggedQuery)
 	if err != nil {
+		runErr = err
 		zapArgs = append(zapArgs, zap.Error(err))
 		db.logger.Error("failed parse query", zapArgs...)
-		return fmt.Sprintf("failed parse query: %s", err.Error())
+		return model.Failure(fmt.Errorf("failed parse query: %w", err), model.CodeSyntax)
+	}
+
+	if err := db.checkAccess(ctx, query); err != nil {
+		runErr = err
+		zapArgs = append(zapArgs, zap.Error(err))
+		db.logger.Warn("access denied", zapArgs...)
+		return model.Failure(fmt.Errorf("failed check access: %w", err), model.CodeNoPerm)
 	}
 
 	exec, ok := db.commandsMap[query.Command]
 	if !ok {
+		runErr = ErrUnknownCommand
 		zapArgs = append(zapArgs, zap.Error(ErrUnknownCommand))
 		db.logger.Error("unknown command", zapArgs...)
-		return ErrUnknownCommand.Error()
+		return model.Failure(ErrUnknownCommand, model.CodeErr)
 	}
 
 	output, err := exec(ctx, query)
 	if err != nil {
+		runErr = err
 		zapArgs = append(zapArgs, zap.Error(err))
 		db.logger.Error("failed run query", zapArgs...)
-		return fmt.Sprintf("failed run query: %s", err.Error())
+		return model.Failure(fmt.Errorf("failed run query: %w", err), model.CodeErr)
 	}
 
-	return output
+	return model.OK(output)
 }
 
-func (db *Database) execGET(ctx context.Context, query model.Query) (string, error) {
+// Get returns value of key. Access is checked the same way as for GET command.
+func (db *Database) Get(ctx context.Context, key string) (_ string, _ bool, err error) {
+	query := model.Query{Command: model.CommandGET, Args: []string{key}}
+	defer db.observeSince(ctx, query, time.Now(), &err)
+	if err := db.checkAccess(ctx, query); err != nil {
+		return "", false, err
+	}
+
+	value, ok := db.storage.Get(ctx, key)
+	return value, ok, nil
+}
+
+// Set sets value of key. Access is checked the same way as for SET command.
+func (db *Database) Set(ctx context.Context, key, value string) (err error) {
+	query := model.Query{Command: model.CommandSET, Args: []string{key, value}}
+	defer db.observeSince(ctx, query, time.Now(), &err)
+	if err := db.checkAccess(ctx, query); err != nil {
+		return err
+	}
+
+	db.storage.Set(ctx, key, value)
+	db.events.Publish(model.KeyEvent{Command: model.CommandSET, Key: key})
+	return nil
+}
+
+// Del deletes key. Access is checked the same way as for DEL command.
+func (db *Database) Del(ctx context.Context, key string) (err error) {
+	query := model.Query{Command: model.CommandDEL, Args: []string{key}}
+	defer db.observeSince(ctx, query, time.Now(), &err)
+	if err := db.checkAccess(ctx, query); err != nil {
+		return err
+	}
+
+	db.storage.Del(ctx, key)
+	db.events.Publish(model.KeyEvent{Command: model.CommandDEL, Key: key})
+	return nil
+}
+
+// Keys returns sorted keys matching glob pattern. Access is checked the same
+// way as for GET command, keys user may not read are skipped.
+func (db *Database) Keys(ctx context.Context, pattern string) ([]string, error) {
+	if err := db.checkAccess(ctx, model.Query{Command: model.CommandGET}); err != nil {
+		return nil, err
+	}
+
+	keys := db.storage.Keys(ctx, pattern)
+	if db.acl == nil {
+		return keys, nil
+	}
+
+	allowed := keys[:0]
+	for _, key := range keys {
+		if db.checkAccess(ctx, model.Query{Command: model.CommandGET, Args: []string{key}}) == nil {
+			allowed = append(allowed, key)
+		}
+	}
+	return allowed, nil
+}
+
+// Subscribe returns subscription to changes of keys matching any of patterns.
+// Subscriber needs read permission and receives only events of keys it may read.
+func (db *Database) Subscribe(ctx context.Context, patterns []string) (*pubsub.Subscription[model.KeyEvent], error) {
+	if err := db.checkAccess(ctx, model.Query{Command: model.CommandGET}); err != nil {
+		return nil, err
+	}
+
+	filter := func(event model.KeyEvent) bool {
+		matched := false
+		for _, pattern := range patterns {
+			if glob.Match(pattern, event.Key) {
+				matched = true
+				break
+			}
+		}
+
+		query := model.Query{Command: model.CommandGET, Args: []string{event.Key}}
+		return matched && db.checkAccess(ctx, query) == nil
+	}
+
+	return db.events.Subscribe(filter, 0), nil
+}
+
+// Monitor returns subscription to every query received by database. Monitor
+// needs admin permission.
+func (db *Database) Monitor(ctx context.Context) (*pubsub.Subscription[model.QueryEvent], error) {
+	query := model.Query{Command: model.CommandMONITOR}
+	err := db.checkAccess(ctx, query)
+	db.recordAudit(ctx, query, time.Now(), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.queries.Subscribe(nil, monitorBufferSize), nil
+}
+
+// publishQuery sends query redacted by redactQuery to monitors.
+func (db *Database) publishQuery(ctx context.Context, start time.Time, rawQuery string) {
+	if db.queries.Len() == 0 {
+		return
+	}
+
+	event := model.QueryEvent{Time: start, Query: rawQuery}
+	if sess, ok := session.FromContext(ctx); ok {
+		event.ClientAddr = sess.RemoteAddr()
+	}
+
+	db.queries.Publish(event)
+}
+
+// observe records statistics, metrics, slow log and audit log of executed query.
+func (db *Database) observe(ctx context.Context, query model.Query, start time.Time, err error) {
+	elapsed := time.Since(start)
+	failed := err != nil
+	db.stats.observe(query.Command, elapsed, failed)
+	db.slowLog.record(ctx, query, start, elapsed)
+	db.recordAudit(ctx, query, start, err)
+
+	if db.metrics == nil {
+		return
+	}
+
+	name := query.Command.String()
+	db.metrics.commands.With(name).Inc()
+	db.metrics.latency.With(name).Observe(elapsed.Seconds())
+	if failed {
+		db.metrics.errors.With(name).Inc()
+	}
+}
+
+func (db *Database) observeSince(ctx context.Context, query model.Query, start time.Time, err *error) {
+	db.observe(ctx, query, start, *err)
+}
+
+// checkAccess checks listener restrictions and user permissions.
+func (db *Database) checkAccess(ctx context.Context, query model.Query) error {
+	if sess, ok := session.FromContext(ctx); ok {
+		category := query.Command.Category()
+		allowed := sess.AllowedCategories()
+		if allowed&category != category {
+			err := fmt.Errorf("%w: %s commands are disabled on this listener", ErrNotAllowed, category)
+			if category == model.CategoryWrite && allowed&model.CategoryRead != 0 {
+				// Listener serves reads only, tell client to send writes elsewhere.
+				return model.WithCode(model.CodeReadOnly, err)
+			}
+			return err
+		}
+	}
+
+	if db.acl == nil {
+		return nil
+	}
+
+	return db.acl.Check(userFromContext(ctx), query)
+}
+
+func (db *Database) execGET(ctx context.Context, query model.Query) (model.Value, error) {
 	if len(query.Args) != model.CommandGETArgsLen {
-		return "", fmt.Errorf("%w: want %d args", ErrInvalidArgs, model.CommandGETArgsLen)
+		return model.Value{}, fmt.Errorf("%w: want %d args", ErrInvalidArgs, model.CommandGETArgsLen)
 	}
 
 	value, ok := db.storage.Get(ctx, query.Args[0])
 	if !ok {
-		return messageEmptyValue, nil
+		return model.NilValue(), nil
+	}
+
+	return model.StringValue(value), nil
+}
+
+// execSET handles SET key value [NX|XX]. With NX value is set only when key
+// doesn't exist, with XX only when it exists. Nil is returned when value is not set.
+// Set value has no TTL.
+func (db *Database) execSET(ctx context.Context, query model.Query) (model.Value, error) {
+	if len(query.Args) < model.CommandSETMinArgsLen || len(query.Args) > model.CommandSETMaxArgsLen {
+		return model.Value{}, fmt.Errorf(
+			"%w: want %d to %d args", ErrInvalidArgs, model.CommandSETMinArgsLen, model.CommandSETMaxArgsLen)
+	}
+
+	key, value := query.Args[0], query.Args[1]
+	if len(query.Args) == model.CommandSETMinArgsLen {
+		db.storage.Set(ctx, key, value)
+	} else {
+		condition := strings.ToLower(query.Args[2])
+		if condition != setIfNotExists && condition != setIfExists {
+			return model.Value{}, fmt.Errorf("%w: unknown set condition %s", ErrInvalidArgs, query.Args[2])
+		}
+
+		stored := false
+		db.storage.Update(ctx, key, func(_ string, ok bool) (string, bool) {
+			stored = ok == (condition == setIfExists)
+			return value, stored
+		})
+		if !stored {
+			return model.NilValue(), nil
+		}
+		// Update keeps TTL of replaced value.
+		db.storage.Expire(ctx, key, 0)
+	}
+
+	db.events.Publish(model.KeyEvent{Command: model.CommandSET, Key: key})
+	return model.NoneValue(), nil
+}
+
+// execINCR handles INCR key and INCRBY key delta. Missing key is treated as 0,
+// new value is returned.
+func (db *Database) execINCR(ctx context.Context, query model.Query) (model.Value, error) {
+	wantArgsLen, delta := model.CommandINCRArgsLen, int64(1)
+	if query.Command == model.CommandINCRBY {
+		wantArgsLen = model.CommandINCRBYArgsLen
+	}
+	if len(query.Args) != wantArgsLen {
+		return model.Value{}, fmt.Errorf("%w: want %d args", ErrInvalidArgs, wantArgsLen)
+	}
+	if query.Command == model.CommandINCRBY {
+		var err error
+		if delta, err = strconv.ParseInt(query.Args[1], 10, 64); err != nil {
+			return model.Value{}, fmt.Errorf("%w: delta %s is not an integer", ErrInvalidArgs, query.Args[1])
+		}
+	}
+
+	var (
+		result int64
+		err    error
+	)
+	db.storage.Update(ctx, query.Args[0], func(value string, ok bool) (string, bool) {
+		current := int64(0)
+		if ok {
+			if current, err = strconv.ParseInt(value, 10, 64); err != nil {
+				err = ErrNotInteger
+				return "", false
+			}
+		}
+		if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+			err = ErrOverflow
+			return "", false
+		}
+
+		result = current + delta
+		return strconv.FormatInt(result, 10), true
+	})
+	if err != nil {
+		return model.Value{}, err
+	}
+
+	db.events.Publish(model.KeyEvent{Command: query.Command, Key: query.Args[0]})
+	return model.IntValue(result), nil
+}
+
+// execEXPIRE handles EXPIRE key seconds. It returns 1 when TTL is set and 0 when
+// key doesn't exist. Key expiring in 0 or less seconds is deleted at once.
+func (db *Database) execEXPIRE(ctx context.Context, query model.Query) (model.Value, error) {
+	if len(query.Args) != model.CommandEXPIREArgsLen {
+		return model.Value{}, fmt.Errorf("%w: want %d args", ErrInvalidArgs, model.CommandEXPIREArgsLen)
+	}
+
+	key := query.Args[0]
+	seconds, err := strconv.ParseInt(query.Args[1], 10, 64)
+	if err != nil || seconds > int64(math.MaxInt64/time.Second) {
+		return model.Value{}, fmt.Errorf("%w: seconds %s is not an integer in range", ErrInvalidArgs, query.Args[1])
+	}
+
+	if seconds <= 0 {
+		if _, ok := db.storage.Get(ctx, key); !ok {
+			return model.IntValue(0), nil
+		}
+		db.storage.Del(ctx, key)
+		db.events.Publish(model.KeyEvent{Command: model.CommandDEL, Key: key})
+		return model.IntValue(1), nil
+	}
+
+	if !db.storage.Expire(ctx, key, time.Duration(seconds)*time.Second) {
+		return model.IntValue(0), nil
 	}
 
-	return value, nil
+	db.events.Publish(model.KeyEvent{Command: model.CommandEXPIRE, Key: key})
+	return model.IntValue(1), nil
 }
 
-func (db *Database) execSET(ctx context.Context, query model.Query) (string, error) {
-	if len(query.Args) != model.CommandSETArgsLen {
-		return "", fmt.Errorf("%w: want %d args", ErrInvalidArgs, model.CommandSETArgsLen)
+// execTTL handles TTL key. It returns seconds left until key expires, rounded
+// up, -1 when key has no TTL and -2 when key doesn't exist.
+func (db *Database) execTTL(ctx context.Context, query model.Query) (model.Value, error) {
+	if len(query.Args) != model.CommandTTLArgsLen {
+		return model.Value{}, fmt.Errorf("%w: want %d args", ErrInvalidArgs, model.CommandTTLArgsLen)
 	}
 
-	db.storage.Set(ctx, query.Args[0], query.Args[1])
-	return messageOK, nil
+	ttl, ok := db.storage.TTL(ctx, query.Args[0])
+	switch {
+	case !ok:
+		return model.IntValue(ttlMissingKey), nil
+	case ttl == 0:
+		return model.IntValue(ttlNoExpiration), nil
+	default:
+		return model.IntValue(int64((ttl + time.Second - 1) / time.Second)), nil
+	}
 }
 
-func (db *Database) execDEL(ctx context.Context, query model.Query) (string, error) {
+func (db *Database) execDEL(ctx context.Context, query model.Query) (model.Value, error) {
 	if len(query.Args) != model.CommandDELArgsLen {
-		return "", fmt.Errorf("%w: want %d args", ErrInvalidArgs, model.CommandDELArgsLen)
+		return model.Value{}, fmt.Errorf("%w: want %d args", ErrInvalidArgs, model.CommandDELArgsLen)
 	}
 
 	db.storage.Del(ctx, query.Args[0])
-	return messageOK, nil
+	db.events.Publish(model.KeyEvent{Command: model.CommandDEL, Key: query.Args[0]})
+	return model.NoneValue(), nil
+}
+
+func (db *Database) execACL(ctx context.Context, query model.Query) (model.Value, error) {
+	if len(query.Args) < model.CommandACLMinArgsLen {
+		return model.Value{}, fmt.Errorf("%w: want at least %d args", ErrInvalidArgs, model.CommandACLMinArgsLen)
+	}
+
+	subcommand, args := strings.ToLower(query.Args[0]), query.Args[1:]
+	if subcommand == subcommandWHOAMI {
+		if user := userFromContext(ctx); user != "" {
+			return model.StringValue(user), nil
+		}
+		return model.StringValue(acl.DefaultUser), nil
+	}
+
+	if db.acl == nil {
+		return model.Value{}, ErrACLDisabled
+	}
+
+	switch subcommand {
+	case subcommandSETUSER:
+		if len(args) < 1 {
+			return model.Value{}, fmt.Errorf("%w: want user name", ErrInvalidArgs)
+		}
+		if err := db.acl.SetUser(args[0], args[1:]); err != nil {
+			return model.Value{}, err
+		}
+		return model.NoneValue(), nil
+	case subcommandGETUSER:
+		if len(args) != 1 {
+			return model.Value{}, fmt.Errorf("%w: want user name", ErrInvalidArgs)
+		}
+		user, err := db.acl.GetUser(args[0])
+		if err != nil {
+			return model.Value{}, err
+		}
+		return model.StringValue(user), nil
+	case subcommandDELUSER:
+		if len(args) != 1 {
+			return model.Value{}, fmt.Errorf("%w: want user name", ErrInvalidArgs)
+		}
+		if err := db.acl.DelUser(args[0]); err != nil {
+			return model.Value{}, err
+		}
+		return model.NoneValue(), nil
+	case subcommandLIST:
+		return model.StringsValue(db.acl.List()), nil
+	default:
+		return model.Value{}, fmt.Errorf("%w: unknown acl subcommand %s", ErrInvalidArgs, query.Args[0])
+	}
+}
+
+func userFromContext(ctx context.Context) string {
+	if sess, ok := session.FromContext(ctx); ok {
+		return sess.User()
+	}
+	return ""
 }
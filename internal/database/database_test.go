@@ -3,14 +3,26 @@ package database
 import (
 	"context"
 	"errors"
+	"math"
+	"net"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"kvdb/internal/audit"
+	parser "kvdb/internal/compute"
+	"kvdb/internal/config/dynamic"
 	"kvdb/internal/database/mocks"
+	"kvdb/internal/metrics"
 	"kvdb/internal/model"
+	"kvdb/internal/session"
+	"kvdb/internal/storage/inmemory"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestDatabase_RunCommand_OK(t *testing.T) {
@@ -43,9 +55,9 @@ func TestDatabase_RunCommand_OK(t *testing.T) {
 				Args:    []string{"key", "value"},
 			},
 			parseError:     nil,
-			execResult:     messageOK,
+			execResult:     "ok",
 			execError:      nil,
-			expectedOutput: messageOK,
+			expectedOutput: "ok",
 		},
 		{
 			name:     "valid DEL command",
@@ -55,9 +67,9 @@ func TestDatabase_RunCommand_OK(t *testing.T) {
 				Args:    []string{"key"},
 			},
 			parseError:     nil,
-			execResult:     messageOK,
+			execResult:     "ok",
 			execError:      nil,
-			expectedOutput: messageOK,
+			expectedOutput: "ok",
 		},
 		{
 			name:           "parse error",
@@ -108,7 +120,7 @@ func TestDatabase_RunCommand_OK(t *testing.T) {
 			}
 
 			// Выполняем команду
-			output := db.RunCommand(context.Background(), tt.rawQuery)
+			output := db.RunCommand(context.Background(), tt.rawQuery).String()
 
 			// Проверяем результат
 			assert.Equal(t, tt.expectedOutput, output, "unexpected output")
@@ -119,3 +131,746 @@ func TestDatabase_RunCommand_OK(t *testing.T) {
 		})
 	}
 }
+
+func TestDatabase_RunCommand_AccessDenied(t *testing.T) {
+	query := model.Query{
+		Command: model.CommandSET,
+		Args:    []string{"key", "value"},
+	}
+
+	mockCompute := mocks.NewCompute(t)
+	mockCompute.On("Parse", "set key value").Return(query, nil)
+
+	mockStorage := mocks.NewStorage(t)
+
+	mockACL := mocks.NewAccessControl(t)
+	mockACL.On("Check", "alice", query).Return(errors.New("permission denied"))
+
+	db := New(zap.NewNop(), mockCompute, mockStorage).WithACL(mockACL)
+
+	sess := session.New(&net.TCPConn{})
+	sess.SetUser("alice")
+	ctx := session.NewContext(context.Background(), sess)
+
+	output := db.RunCommand(ctx, "set key value").String()
+	assert.Equal(t, "failed check access: permission denied", output)
+}
+
+// TestDatabase_RunCommand_Result tests that payload types and failures can be
+// told apart without parsing text.
+func TestDatabase_RunCommand_Result(t *testing.T) {
+	parseErr := errors.New("parse error")
+
+	mockCompute := mocks.NewCompute(t)
+	mockCompute.On("Parse", "get nil").Return(model.Query{Command: model.CommandGET, Args: []string{"nil"}}, nil)
+	mockCompute.On("Parse", "get key").Return(model.Query{Command: model.CommandGET, Args: []string{"key"}}, nil)
+	mockCompute.On("Parse", "del key").Return(model.Query{Command: model.CommandDEL, Args: []string{"key"}}, nil)
+	mockCompute.On("Parse", "slowlog len").Return(model.Query{Command: model.CommandSLOWLOG, Args: []string{"len"}}, nil)
+	mockCompute.On("Parse", "bad").Return(model.Query{}, parseErr)
+
+	mockStorage := mocks.NewStorage(t)
+	mockStorage.On("Get", mock.Anything, "nil").Return("", false)
+	mockStorage.On("Get", mock.Anything, "key").Return("nil", true)
+	mockStorage.On("Del", mock.Anything, "key").Return()
+
+	db := New(zap.NewNop(), mockCompute, mockStorage)
+	ctx := context.Background()
+
+	assert.Equal(t, model.OK(model.NilValue()), db.RunCommand(ctx, "get nil"))
+	assert.Equal(t, model.OK(model.StringValue("nil")), db.RunCommand(ctx, "get key"), "value nil is not missing value")
+	assert.Equal(t, model.OK(model.NoneValue()), db.RunCommand(ctx, "del key"))
+	assert.Equal(t, model.OK(model.IntValue(0)), db.RunCommand(ctx, "slowlog len"))
+
+	result := db.RunCommand(ctx, "bad")
+	assert.Equal(t, model.StatusError, result.Status)
+	assert.Equal(t, model.CodeSyntax, result.Code)
+	assert.ErrorIs(t, result.Err, parseErr)
+	assert.Equal(t, "failed parse query: parse error", result.String())
+}
+
+func TestDatabase_RunCommand_ACL(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		setupACL       func(m *mocks.AccessControl)
+		expectedOutput string
+	}{
+		{
+			name: "setuser",
+			args: []string{"SETUSER", "alice", "on", ">secret"},
+			setupACL: func(m *mocks.AccessControl) {
+				m.On("SetUser", "alice", []string{"on", ">secret"}).Return(nil)
+			},
+			expectedOutput: "ok",
+		},
+		{
+			name: "setuser error",
+			args: []string{"setuser", "alice", "bad"},
+			setupACL: func(m *mocks.AccessControl) {
+				m.On("SetUser", "alice", []string{"bad"}).Return(errors.New("invalid rule: bad"))
+			},
+			expectedOutput: "failed run query: invalid rule: bad",
+		},
+		{
+			name: "getuser",
+			args: []string{"getuser", "alice"},
+			setupACL: func(m *mocks.AccessControl) {
+				m.On("GetUser", "alice").Return("flags: on", nil)
+			},
+			expectedOutput: "flags: on",
+		},
+		{
+			name: "deluser",
+			args: []string{"deluser", "alice"},
+			setupACL: func(m *mocks.AccessControl) {
+				m.On("DelUser", "alice").Return(nil)
+			},
+			expectedOutput: "ok",
+		},
+		{
+			name: "list",
+			args: []string{"list"},
+			setupACL: func(m *mocks.AccessControl) {
+				m.On("List").Return([]string{"user alice on", "user default on nopass"})
+			},
+			expectedOutput: "user alice on\nuser default on nopass",
+		},
+		{
+			name:           "whoami",
+			args:           []string{"whoami"},
+			setupACL:       func(_ *mocks.AccessControl) {},
+			expectedOutput: "default",
+		},
+		{
+			name:           "unknown subcommand",
+			args:           []string{"unknown"},
+			setupACL:       func(_ *mocks.AccessControl) {},
+			expectedOutput: "failed run query: invalid arguments: unknown acl subcommand unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := model.Query{Command: model.CommandACL, Args: tt.args}
+
+			mockCompute := mocks.NewCompute(t)
+			mockCompute.On("Parse", "acl").Return(query, nil)
+
+			mockACL := mocks.NewAccessControl(t)
+			mockACL.On("Check", "", query).Return(nil)
+			tt.setupACL(mockACL)
+
+			db := New(zap.NewNop(), mockCompute, mocks.NewStorage(t)).WithACL(mockACL)
+
+			output := db.RunCommand(context.Background(), "acl").String()
+			assert.Equal(t, tt.expectedOutput, output)
+		})
+	}
+}
+
+func TestDatabase_RunCommand_ListenerCategories(t *testing.T) {
+	query := model.Query{
+		Command: model.CommandDEL,
+		Args:    []string{"key"},
+	}
+
+	mockCompute := mocks.NewCompute(t)
+	mockCompute.On("Parse", "del key").Return(query, nil)
+
+	db := New(zap.NewNop(), mockCompute, mocks.NewStorage(t))
+
+	sess := session.New(&net.TCPConn{})
+	sess.SetAllowedCategories(model.CategoryRead)
+	ctx := session.NewContext(context.Background(), sess)
+
+	result := db.RunCommand(ctx, "del key")
+	assert.Equal(t, "failed check access: command not allowed: write commands are disabled on this listener", result.String())
+	assert.Equal(t, model.CodeReadOnly, result.Code)
+	assert.ErrorIs(t, result.Err, ErrNotAllowed)
+	assert.ErrorIs(t, result.Err, model.ErrReadOnly)
+}
+
+func TestDatabase_RunCommand_ErrorCodes(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        model.Query
+		expectedCode model.ErrorCode
+		expectedErr  error
+	}{
+		{
+			name:         "unknown command",
+			query:        model.Query{Command: model.CommandUNK},
+			expectedCode: model.CodeUnknownCmd,
+			expectedErr:  model.ErrUnknownCmd,
+		},
+		{
+			name:         "wrong args",
+			query:        model.Query{Command: model.CommandGET},
+			expectedCode: model.CodeWrongArgs,
+			expectedErr:  model.ErrWrongArgs,
+		},
+		{
+			name:         "disabled",
+			query:        model.Query{Command: model.CommandCONFIG, Args: []string{"get", "*"}},
+			expectedCode: model.CodeDisabled,
+			expectedErr:  model.ErrDisabled,
+		},
+		{
+			name:         "no such client",
+			query:        model.Query{Command: model.CommandCLIENT, Args: []string{"kill", "42"}},
+			expectedCode: model.CodeNotFound,
+			expectedErr:  model.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCompute := mocks.NewCompute(t)
+			mockCompute.On("Parse", "query").Return(tt.query, nil)
+
+			db := New(zap.NewNop(), mockCompute, mocks.NewStorage(t)).WithClients(&fakeClients{})
+
+			result := db.RunCommand(context.Background(), "query")
+			assert.Equal(t, model.StatusError, result.Status)
+			assert.Equal(t, tt.expectedCode, result.Code)
+			assert.ErrorIs(t, result.Err, tt.expectedErr)
+		})
+	}
+}
+
+func TestDatabase_Subscribe(t *testing.T) {
+	mockStorage := mocks.NewStorage(t)
+	mockStorage.On("Set", mock.Anything, mock.Anything, "value").Return()
+	mockStorage.On("Del", mock.Anything, mock.Anything).Return()
+
+	mockACL := mocks.NewAccessControl(t)
+	mockACL.On("Check", "", mock.Anything).Return(nil)
+	mockACL.On("Check", "alice", mock.MatchedBy(func(q model.Query) bool {
+		return len(q.Args) == 0 || q.Args[0] != "user:secret"
+	})).Return(nil)
+	mockACL.On("Check", "alice", model.Query{Command: model.CommandGET, Args: []string{"user:secret"}}).
+		Return(errors.New("permission denied"))
+
+	db := New(zap.NewNop(), mocks.NewCompute(t), mockStorage).WithACL(mockACL)
+
+	sess := session.New(&net.TCPConn{})
+	sess.SetUser("alice")
+	ctx := session.NewContext(context.Background(), sess)
+
+	sub, err := db.Subscribe(ctx, []string{"user:*"})
+	assert.NoError(t, err)
+
+	// Changes are made by another client.
+	writeCtx := context.Background()
+	assert.NoError(t, db.Set(writeCtx, "user:1", "value"))
+	assert.NoError(t, db.Set(writeCtx, "order:1", "value"))
+	assert.NoError(t, db.Set(writeCtx, "user:secret", "value"))
+	assert.NoError(t, db.Del(writeCtx, "user:1"))
+	sub.Close()
+
+	var events []model.KeyEvent
+	for event := range sub.C() {
+		events = append(events, event)
+	}
+
+	assert.Equal(t, []model.KeyEvent{
+		{Command: model.CommandSET, Key: "user:1"},
+		{Command: model.CommandDEL, Key: "user:1"},
+	}, events)
+}
+
+func TestDatabase_RunCommand_SET_Conditions(t *testing.T) {
+	db := New(zap.NewNop(), parser.New(), inmemory.New())
+	ctx := context.Background()
+
+	assert.Equal(t, model.OK(model.NilValue()), db.RunCommand(ctx, "set key a XX"), "key doesn't exist")
+	assert.Equal(t, model.OK(model.NoneValue()), db.RunCommand(ctx, "set key a NX"))
+	assert.Equal(t, model.OK(model.NilValue()), db.RunCommand(ctx, "set key b nx"), "key exists")
+	assert.Equal(t, model.OK(model.NoneValue()), db.RunCommand(ctx, "set key c xx"))
+	assert.Equal(t, model.OK(model.StringValue("c")), db.RunCommand(ctx, "get key"))
+
+	result := db.RunCommand(ctx, "set key d always")
+	assert.Equal(t, model.CodeWrongArgs, result.Code)
+	assert.Equal(t, "failed run query: invalid arguments: unknown set condition always", result.String())
+}
+
+func TestDatabase_RunCommand_INCR(t *testing.T) {
+	db := New(zap.NewNop(), parser.New(), inmemory.New())
+	ctx := context.Background()
+
+	assert.Equal(t, model.OK(model.IntValue(1)), db.RunCommand(ctx, "incr counter"))
+	assert.Equal(t, model.OK(model.IntValue(11)), db.RunCommand(ctx, "incrby counter 10"))
+	assert.Equal(t, model.OK(model.IntValue(-4)), db.RunCommand(ctx, "incrby counter -15"))
+	assert.Equal(t, model.OK(model.StringValue("-4")), db.RunCommand(ctx, "get counter"))
+
+	result := db.RunCommand(ctx, "incrby counter ten")
+	assert.Equal(t, model.CodeWrongArgs, result.Code)
+
+	db.RunCommand(ctx, "set name alice")
+	result = db.RunCommand(ctx, "incr name")
+	assert.Equal(t, model.CodeWrongType, result.Code)
+	assert.ErrorIs(t, result.Err, ErrNotInteger)
+	assert.Equal(t, model.OK(model.StringValue("alice")), db.RunCommand(ctx, "get name"), "value is kept")
+
+	db.RunCommand(ctx, "set max "+strconv.FormatInt(math.MaxInt64, 10))
+	result = db.RunCommand(ctx, "incr max")
+	assert.ErrorIs(t, result.Err, ErrOverflow)
+}
+
+func TestDatabase_RunCommand_EXPIRE(t *testing.T) {
+	db := New(zap.NewNop(), parser.New(), inmemory.New())
+	ctx := context.Background()
+
+	assert.Equal(t, model.OK(model.IntValue(-2)), db.RunCommand(ctx, "ttl session"))
+	assert.Equal(t, model.OK(model.IntValue(0)), db.RunCommand(ctx, "expire session 60"), "key doesn't exist")
+
+	db.RunCommand(ctx, "set session token")
+	assert.Equal(t, model.OK(model.IntValue(-1)), db.RunCommand(ctx, "ttl session"))
+	assert.Equal(t, model.OK(model.IntValue(1)), db.RunCommand(ctx, "expire session 60"))
+	assert.Equal(t, model.OK(model.IntValue(60)), db.RunCommand(ctx, "ttl session"))
+
+	// INCR keeps TTL, SET removes it, also with condition.
+	db.RunCommand(ctx, "set counter 1")
+	db.RunCommand(ctx, "expire counter 60")
+	db.RunCommand(ctx, "incr counter")
+	assert.Equal(t, model.OK(model.IntValue(60)), db.RunCommand(ctx, "ttl counter"))
+	db.RunCommand(ctx, "set counter 1 XX")
+	assert.Equal(t, model.OK(model.IntValue(-1)), db.RunCommand(ctx, "ttl counter"))
+
+	assert.Equal(t, model.OK(model.IntValue(1)), db.RunCommand(ctx, "expire session 0"), "deleted at once")
+	assert.Equal(t, model.OK(model.NilValue()), db.RunCommand(ctx, "get session"))
+
+	for _, seconds := range []string{"soon", "9223372036854775807"} {
+		result := db.RunCommand(ctx, "expire counter "+seconds)
+		assert.Equal(t, model.CodeWrongArgs, result.Code, seconds)
+	}
+}
+
+func TestDatabase_Keys(t *testing.T) {
+	mockStorage := mocks.NewStorage(t)
+	mockStorage.On("Keys", mock.Anything, "user:*").Return([]string{"user:1", "user:secret"})
+
+	mockACL := mocks.NewAccessControl(t)
+	mockACL.On("Check", "alice", model.Query{Command: model.CommandGET, Args: []string{"user:secret"}}).
+		Return(errors.New("permission denied"))
+	mockACL.On("Check", "alice", mock.Anything).Return(nil)
+
+	db := New(zap.NewNop(), mocks.NewCompute(t), mockStorage).WithACL(mockACL)
+
+	sess := session.New(&net.TCPConn{})
+	sess.SetUser("alice")
+	ctx := session.NewContext(context.Background(), sess)
+
+	keys, err := db.Keys(ctx, "user:*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user:1"}, keys)
+}
+
+func TestDatabase_Subscribe_NotAllowed(t *testing.T) {
+	db := New(zap.NewNop(), mocks.NewCompute(t), mocks.NewStorage(t))
+
+	sess := session.New(&net.TCPConn{})
+	sess.SetAllowedCategories(model.CategoryWrite)
+	ctx := session.NewContext(context.Background(), sess)
+
+	_, err := db.Subscribe(ctx, []string{"*"})
+	assert.ErrorIs(t, err, ErrNotAllowed)
+}
+
+func TestDatabase_Monitor(t *testing.T) {
+	mockCompute := mocks.NewCompute(t)
+	mockCompute.On("Parse", "get key").Return(model.Query{Command: model.CommandGET, Args: []string{"key"}}, nil)
+	mockCompute.On("Parse", "acl setuser bob >secret").
+		Return(model.Query{Command: model.CommandACL, Args: []string{"setuser", "bob", ">secret"}}, nil)
+	mockCompute.On("Parse", "bad").Return(model.Query{}, errors.New("invalid query"))
+
+	mockStorage := mocks.NewStorage(t)
+	mockStorage.On("Get", mock.Anything, "key").Return("value", true)
+
+	db := New(zap.NewNop(), mockCompute, mockStorage)
+
+	// Queries aren't published without monitors.
+	db.RunCommand(context.Background(), "get key")
+
+	sub, err := db.Monitor(context.Background())
+	assert.NoError(t, err)
+	defer sub.Close()
+
+	ctx := session.NewContext(context.Background(), session.NewWithAddr("127.0.0.1:4242"))
+	db.RunCommand(ctx, "get key")
+	db.RunCommand(ctx, "acl setuser bob >secret")
+	db.RunCommand(context.Background(), "bad")
+
+	for _, expected := range []model.QueryEvent{
+		{ClientAddr: "127.0.0.1:4242", Query: "get key"},
+		{ClientAddr: "127.0.0.1:4242", Query: "acl setuser (redacted)"},
+		{Query: "bad"},
+	} {
+		event := <-sub.C()
+		assert.False(t, event.Time.IsZero())
+		event.Time = time.Time{}
+		assert.Equal(t, expected, event)
+	}
+	assert.Empty(t, sub.C())
+
+	sess := session.New(&net.TCPConn{})
+	sess.SetAllowedCategories(model.CategoryRead | model.CategoryWrite)
+	_, err = db.Monitor(session.NewContext(context.Background(), sess))
+	assert.ErrorIs(t, err, ErrNotAllowed)
+}
+
+// TestDatabase_RunCommand_LogRedacted tests that passwords of ACL rules are not
+// written to log of failed queries.
+func TestDatabase_RunCommand_LogRedacted(t *testing.T) {
+	mockCompute := mocks.NewCompute(t)
+	mockCompute.On("Parse", "ACL SETUSER alice on >secret <bad-rule>").Return(model.Query{
+		Command: model.CommandACL,
+		Args:    []string{"SETUSER", "alice", "on", ">secret", "<bad-rule>"},
+	}, nil)
+	mockCompute.On("Parse", "acl setuser alice >secret 'unterminated").Return(model.Query{}, errors.New("invalid query"))
+
+	var logs strings.Builder
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&logs), zap.DebugLevel)
+	db := New(zap.New(core), mockCompute, mocks.NewStorage(t))
+
+	result := db.RunCommand(context.Background(), "ACL SETUSER alice on >secret <bad-rule>")
+	assert.Equal(t, model.StatusError, result.Status)
+	result = db.RunCommand(context.Background(), "acl setuser alice >secret 'unterminated")
+	assert.Equal(t, model.StatusError, result.Status)
+
+	assert.NotContains(t, logs.String(), "secret")
+	assert.Contains(t, logs.String(), `"raw_query":"acl SETUSER (redacted)"`)
+	assert.Contains(t, logs.String(), `"raw_query":"acl setuser (redacted)"`)
+}
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		rawQuery string
+		expected string
+	}{
+		{rawQuery: "GET key", expected: "GET key"},
+		{rawQuery: "ACL WHOAMI", expected: "ACL WHOAMI"},
+		{rawQuery: "ACL SETUSER bob >secret", expected: "acl SETUSER (redacted)"},
+		{rawQuery: "'acl' setuser bob '>my secret'", expected: "acl setuser (redacted)"},
+		{rawQuery: `"acl" setuser bob ">secret`, expected: "acl setuser (redacted)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rawQuery, func(t *testing.T) {
+			assert.Equal(t, tt.expected, redactQuery(tt.rawQuery))
+		})
+	}
+}
+
+func TestDatabase_RunCommand_Metrics(t *testing.T) {
+	mockCompute := mocks.NewCompute(t)
+	mockCompute.On("Parse", "get key").Return(model.Query{Command: model.CommandGET, Args: []string{"key"}}, nil)
+	mockCompute.On("Parse", "bad").Return(model.Query{}, errors.New("invalid query"))
+
+	mockStorage := mocks.NewStorage(t)
+	mockStorage.On("Get", mock.Anything, "key").Return("value", true)
+
+	registry := metrics.NewRegistry()
+	db := New(zap.NewNop(), mockCompute, mockStorage).WithMetrics(registry)
+
+	db.RunCommand(context.Background(), "get key")
+	db.RunCommand(context.Background(), "get key")
+	db.RunCommand(context.Background(), "bad")
+
+	commands := registry.Counter("kvdb_commands_total", "", "command")
+	errs := registry.Counter("kvdb_command_errors_total", "", "command")
+	latency := registry.Histogram("kvdb_command_duration_seconds", "", metrics.LatencyBuckets, "command")
+
+	assert.Equal(t, uint64(2), commands.With("get").Value())
+	assert.Equal(t, uint64(0), errs.With("get").Value())
+	assert.Equal(t, uint64(2), latency.With("get").Count())
+	assert.Equal(t, uint64(1), commands.With("unknown").Value())
+	assert.Equal(t, uint64(1), errs.With("unknown").Value())
+}
+
+func TestDatabase_RunCommand_INFO(t *testing.T) {
+	mockCompute := mocks.NewCompute(t)
+	mockCompute.On("Parse", "get key").Return(model.Query{Command: model.CommandGET, Args: []string{"key"}}, nil)
+	mockCompute.On("Parse", "info").Return(model.Query{Command: model.CommandINFO}, nil)
+	mockCompute.On("Parse", "info memory").Return(model.Query{Command: model.CommandINFO, Args: []string{"MEMORY"}}, nil)
+	mockCompute.On("Parse", "info unknown").Return(model.Query{Command: model.CommandINFO, Args: []string{"unknown"}}, nil)
+
+	mockStorage := mocks.NewStorage(t)
+	mockStorage.On("Get", mock.Anything, "key").Return("", false)
+
+	db := New(zap.NewNop(), mockCompute, mockStorage).
+		WithInfoSection("custom", func() []InfoField {
+			return []InfoField{{Key: "answer", Value: "42"}}
+		}).
+		WithInfoSection(InfoSectionMemory, func() []InfoField {
+			return []InfoField{{Key: "keys", Value: "0"}}
+		})
+
+	db.RunCommand(context.Background(), "get key")
+
+	output := db.RunCommand(context.Background(), "info").String()
+	assert.Regexp(t, `^# memory
+keys:0
+
+# stats
+total_commands_processed:1
+total_command_errors:0
+cmdstat_get:calls=1,failed=0,usec=\d+,usec_per_call=\d+\.\d\d
+
+# custom
+answer:42$`, output)
+
+	assert.Equal(t, "# memory\nkeys:0", db.RunCommand(context.Background(), "info memory").String())
+	assert.Equal(t, "failed run query: invalid arguments: unknown info section unknown",
+		db.RunCommand(context.Background(), "info unknown").String())
+}
+
+func TestDatabase_RunCommand_SLOWLOG(t *testing.T) {
+	long := strings.Repeat("v", slowLogMaxArgLen+10)
+
+	mockCompute := mocks.NewCompute(t)
+	mockCompute.On("Parse", "get key").Return(model.Query{Command: model.CommandGET, Args: []string{"key"}}, nil)
+	mockCompute.On("Parse", "set key long").Return(model.Query{Command: model.CommandSET, Args: []string{"key", long}}, nil)
+	mockCompute.On("Parse", "slowlog get 1").Return(model.Query{Command: model.CommandSLOWLOG, Args: []string{"GET", "1"}}, nil)
+	mockCompute.On("Parse", "slowlog get x").Return(model.Query{Command: model.CommandSLOWLOG, Args: []string{"get", "x"}}, nil)
+	mockCompute.On("Parse", "slowlog len").Return(model.Query{Command: model.CommandSLOWLOG, Args: []string{"len"}}, nil)
+	mockCompute.On("Parse", "slowlog reset").Return(model.Query{Command: model.CommandSLOWLOG, Args: []string{"reset"}}, nil)
+	mockCompute.On("Parse", "slowlog get").Return(model.Query{Command: model.CommandSLOWLOG, Args: []string{"get"}}, nil)
+	mockCompute.On("Parse", "slowlog unknown").Return(model.Query{Command: model.CommandSLOWLOG, Args: []string{"unknown"}}, nil)
+
+	mockStorage := mocks.NewStorage(t)
+	mockStorage.On("Get", mock.Anything, "key").Return("", false)
+	mockStorage.On("Set", mock.Anything, "key", long).Return()
+
+	// Zero threshold logs every query, only 2 latest are kept.
+	db := New(zap.NewNop(), mockCompute, mockStorage).WithSlowLog(0, 2)
+	ctx := session.NewContext(context.Background(), session.NewWithAddr("127.0.0.1:4242"))
+
+	db.RunCommand(ctx, "get key")
+	db.RunCommand(ctx, "set key long")
+	db.RunCommand(ctx, "get key")
+
+	assert.Equal(t, "2", db.RunCommand(ctx, "slowlog len").String())
+	assert.Regexp(t, `^id=4 timestamp=\S+ duration_us=\d+ client=127\.0\.0\.1:4242 args=slowlog len$`,
+		db.RunCommand(ctx, "slowlog get 1").String())
+
+	db.SetSlowLogThreshold(-1)
+	assert.Equal(t, "ok", db.RunCommand(ctx, "slowlog reset").String())
+	assert.Equal(t, "0", db.RunCommand(ctx, "slowlog len").String())
+	assert.Equal(t, "nil", db.RunCommand(ctx, "slowlog get").String())
+
+	db.SetSlowLogThreshold(0)
+	db.RunCommand(context.Background(), "set key long")
+	assert.Regexp(t, `^id=\d+ timestamp=\S+ duration_us=\d+ client=- args=set key 'v{128}\.\.\. \(10 more bytes\)'$`,
+		db.RunCommand(ctx, "slowlog get 1").String())
+
+	assert.Equal(t, "failed run query: invalid arguments: count must be non-negative integer",
+		db.RunCommand(ctx, "slowlog get x").String())
+	assert.Equal(t, "failed run query: invalid arguments: unknown slowlog subcommand unknown",
+		db.RunCommand(ctx, "slowlog unknown").String())
+}
+
+func TestTruncateArgs(t *testing.T) {
+	args := make([]string, slowLogMaxArgs+5)
+	args[0] = "key"
+
+	truncated := truncateArgs(model.Query{Command: model.CommandGET, Args: args})
+	assert.Len(t, truncated, slowLogMaxArgs+1)
+	assert.Equal(t, "get", truncated[0])
+	assert.Equal(t, "key", truncated[1])
+	assert.Equal(t, "... (6 more arguments)", truncated[slowLogMaxArgs])
+}
+
+// TestDatabase_SlowLogRedacted tests that slow log doesn't keep passwords of ACL rules.
+func TestDatabase_SlowLogRedacted(t *testing.T) {
+	mockCompute := mocks.NewCompute(t)
+	mockCompute.On("Parse", "acl setuser bob on >secret").
+		Return(model.Query{Command: model.CommandACL, Args: []string{"setuser", "bob", "on", ">secret"}}, nil)
+	mockCompute.On("Parse", "slowlog get 1").Return(model.Query{Command: model.CommandSLOWLOG, Args: []string{"get", "1"}}, nil)
+
+	db := New(zap.NewNop(), mockCompute, mocks.NewStorage(t)).WithSlowLog(0, 10)
+	db.RunCommand(context.Background(), "acl setuser bob on >secret")
+
+	entry := db.RunCommand(context.Background(), "slowlog get 1").String()
+	assert.NotContains(t, entry, "secret")
+	assert.Regexp(t, `args=acl setuser \(redacted\)$`, entry)
+}
+
+// fakeClients is a client registry of fixed sessions.
+type fakeClients struct {
+	sessions []*session.Session
+	killed   []string
+}
+
+func (f *fakeClients) List() []*session.Session {
+	return f.sessions
+}
+
+func (f *fakeClients) Kill(id uint64) bool {
+	for _, sess := range f.sessions {
+		if sess.ID() == id {
+			f.killed = append(f.killed, sess.RemoteAddr())
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeClients) KillAddr(addr string) bool {
+	for _, sess := range f.sessions {
+		if sess.RemoteAddr() == addr {
+			f.killed = append(f.killed, addr)
+			return true
+		}
+	}
+	return false
+}
+
+func TestDatabase_RunCommand_CLIENT(t *testing.T) {
+	self := session.NewWithAddr("127.0.0.1:4242")
+	other := session.NewWithAddr("127.0.0.1:4343")
+	other.SetUser("alice")
+	other.SetLastCommand("get")
+	other.AddBytesIn(10)
+	clients := &fakeClients{sessions: []*session.Session{self, other}}
+
+	tests := []struct {
+		args           []string
+		expectedOutput string
+	}{
+		{args: []string{"ID"}, expectedOutput: strconv.FormatUint(self.ID(), 10)},
+		{args: []string{"getname"}, expectedOutput: "nil"},
+		{args: []string{"setname", "my app"}, expectedOutput: "failed run query: invalid arguments: client name can't contain spaces"},
+		{args: []string{"setname", "app"}, expectedOutput: "ok"},
+		{args: []string{"getname"}, expectedOutput: "app"},
+		{args: []string{"kill", "127.0.0.1:4343"}, expectedOutput: "ok"},
+		{args: []string{"kill", strconv.FormatUint(self.ID(), 10)}, expectedOutput: "ok"},
+		{args: []string{"kill", "0"}, expectedOutput: "failed run query: no such client: 0"},
+		{args: []string{"unknown"}, expectedOutput: "failed run query: invalid arguments: unknown client subcommand unknown"},
+	}
+
+	for _, tt := range tests {
+		query := model.Query{Command: model.CommandCLIENT, Args: tt.args}
+		mockCompute := mocks.NewCompute(t)
+		mockCompute.On("Parse", "client").Return(query, nil)
+
+		db := New(zap.NewNop(), mockCompute, mocks.NewStorage(t)).WithClients(clients)
+		ctx := session.NewContext(context.Background(), self)
+		assert.Equal(t, tt.expectedOutput, db.RunCommand(ctx, "client").String(), tt.args)
+	}
+	assert.Equal(t, []string{"127.0.0.1:4343", "127.0.0.1:4242"}, clients.killed)
+
+	mockCompute := mocks.NewCompute(t)
+	mockCompute.On("Parse", "client list").Return(model.Query{Command: model.CommandCLIENT, Args: []string{"list"}}, nil)
+	db := New(zap.NewNop(), mockCompute, mocks.NewStorage(t)).WithClients(clients)
+
+	output := strings.Split(db.RunCommand(context.Background(), "client list").String(), "\n")
+	assert.Len(t, output, 2)
+	assert.Regexp(t, `^id=\d+ addr=127\.0\.0\.1:4242 name=app user=default connected_at=\S+ age=\d+ idle=\d+ cmd=- bytes_in=0 bytes_out=0$`, output[0])
+	assert.Regexp(t, `^id=\d+ addr=127\.0\.0\.1:4343 name= user=alice connected_at=\S+ age=\d+ idle=\d+ cmd=get bytes_in=10 bytes_out=0$`, output[1])
+
+	db.WithClients(nil)
+	assert.Equal(t, "failed run query: client registry is disabled", db.RunCommand(context.Background(), "client list").String())
+}
+
+func TestDatabase_RunCommand_CONFIG(t *testing.T) {
+	level := "info"
+	config := dynamic.New("").
+		Register("logging.level", func() string { return level }, func(value string) error {
+			if value == "" {
+				return errors.New("want level")
+			}
+			level = value
+			return nil
+		}).
+		Register("logging.output", func() string { return "/var/log/kvdb log" }, func(string) error { return nil })
+
+	tests := []struct {
+		args           []string
+		expectedOutput string
+	}{
+		{args: []string{"GET", "logging.*"}, expectedOutput: "logging.level info\nlogging.output '/var/log/kvdb log'"},
+		{args: []string{"get", "unknown"}, expectedOutput: "nil"},
+		{args: []string{"set", "logging.level", "debug"}, expectedOutput: "ok"},
+		{args: []string{"get", "logging.level"}, expectedOutput: "logging.level debug"},
+		{args: []string{"set", "logging.level", ""}, expectedOutput: "failed run query: invalid value: logging.level: want level"},
+		{args: []string{"set", "unknown", "1"}, expectedOutput: "failed run query: unknown parameter: unknown"},
+		{args: []string{"set", "logging.level"}, expectedOutput: "failed run query: invalid arguments: want name and value"},
+		{args: []string{"rewrite"}, expectedOutput: "failed run query: server started without config file"},
+		{args: []string{"unknown"}, expectedOutput: "failed run query: invalid arguments: unknown config subcommand unknown"},
+	}
+
+	for _, tt := range tests {
+		query := model.Query{Command: model.CommandCONFIG, Args: tt.args}
+		mockCompute := mocks.NewCompute(t)
+		mockCompute.On("Parse", "config").Return(query, nil)
+
+		db := New(zap.NewNop(), mockCompute, mocks.NewStorage(t)).WithConfig(config)
+		assert.Equal(t, tt.expectedOutput, db.RunCommand(context.Background(), "config").String(), tt.args)
+	}
+}
+
+type fakeAudit struct {
+	entries []audit.Entry
+}
+
+func (f *fakeAudit) Record(entry audit.Entry) {
+	f.entries = append(f.entries, entry)
+}
+
+func TestDatabase_Audit(t *testing.T) {
+	queries := map[string]model.Query{
+		"get key":          {Command: model.CommandGET, Args: []string{"key"}},
+		"set key value":    {Command: model.CommandSET, Args: []string{"key", "value"}},
+		"del key":          {Command: model.CommandDEL, Args: []string{"key"}},
+		"acl setuser bob":  {Command: model.CommandACL, Args: []string{"setuser", "bob", ">secret"}},
+		"slowlog len":      {Command: model.CommandSLOWLOG, Args: []string{"len"}},
+		"set denied value": {Command: model.CommandSET, Args: []string{"denied", "value"}},
+	}
+
+	mockCompute := mocks.NewCompute(t)
+	for raw, query := range queries {
+		mockCompute.On("Parse", raw).Return(query, nil)
+	}
+
+	mockStorage := mocks.NewStorage(t)
+	mockStorage.On("Get", mock.Anything, "key").Return("value", true)
+	mockStorage.On("Set", mock.Anything, "key", "value").Return()
+	mockStorage.On("Del", mock.Anything, "key").Return()
+
+	mockACL := mocks.NewAccessControl(t)
+	mockACL.On("Check", "alice", queries["set denied value"]).Return(errors.New("permission denied"))
+	mockACL.On("Check", "alice", mock.Anything).Return(nil)
+	mockACL.On("SetUser", "bob", []string{">secret"}).Return(nil)
+
+	auditLog := &fakeAudit{}
+	db := New(zap.NewNop(), mockCompute, mockStorage).WithACL(mockACL).WithAudit(auditLog)
+
+	sess := session.New(&net.TCPConn{})
+	sess.SetUser("alice")
+	ctx := session.NewContext(context.Background(), sess)
+
+	for _, raw := range []string{"get key", "set key value", "del key", "acl setuser bob", "slowlog len", "set denied value"} {
+		db.RunCommand(ctx, raw)
+	}
+
+	type recorded struct {
+		command, key, value, err string
+		args                     []string
+	}
+	actual := make([]recorded, 0, len(auditLog.entries))
+	for _, entry := range auditLog.entries {
+		assert.Equal(t, "alice", entry.User)
+		assert.Equal(t, sess.ID(), entry.ClientID)
+		assert.False(t, entry.Time.IsZero())
+		actual = append(actual, recorded{command: entry.Command, key: entry.Key, value: entry.Value, err: entry.Error, args: entry.Args})
+	}
+
+	assert.Equal(t, []recorded{
+		{command: "set", key: "key", value: "value"},
+		{command: "del", key: "key"},
+		{command: "acl", args: []string{"setuser", "(redacted)"}},
+		{command: "slowlog", args: []string{"len"}},
+		{command: "set", key: "denied", value: "value", err: "permission denied"},
+	}, actual)
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"kvdb/internal/model"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	InfoSectionServer      = "server"
+	InfoSectionClients     = "clients"
+	InfoSectionMemory      = "memory"
+	InfoSectionPersistence = "persistence"
+	InfoSectionStats       = "stats"
+
+	infoSectionAll     = "all"
+	infoSectionDefault = "default"
+)
+
+// infoSectionsOrder is an order of sections in INFO output.
+var infoSectionsOrder = []string{
+	InfoSectionServer,
+	InfoSectionClients,
+	InfoSectionMemory,
+	InfoSectionPersistence,
+	InfoSectionStats,
+}
+
+// InfoField is a single "key:value" line of INFO output.
+type InfoField struct {
+	Key   string
+	Value string
+}
+
+// InfoFunc returns fields of INFO section.
+type InfoFunc func() []InfoField
+
+// WithInfoSection adds section to INFO output, replacing section with the same name.
+func (db *Database) WithInfoSection(name string, fn InfoFunc) *Database {
+	db.infoSections[name] = fn
+	return db
+}
+
+// commandStats keeps counters of a single command.
+type commandStats struct {
+	calls  atomic.Uint64
+	failed atomic.Uint64
+	usec   atomic.Uint64
+}
+
+// stats keeps counters of executed commands for INFO.
+type stats struct {
+	commands sync.Map // model.Command -> *commandStats.
+}
+
+func (s *stats) observe(command model.Command, elapsed time.Duration, failed bool) {
+	value, ok := s.commands.Load(command)
+	if !ok {
+		value, _ = s.commands.LoadOrStore(command, &commandStats{})
+	}
+
+	cs := value.(*commandStats)
+	cs.calls.Add(1)
+	cs.usec.Add(uint64(elapsed.Microseconds()))
+	if failed {
+		cs.failed.Add(1)
+	}
+}
+
+func (s *stats) info() []InfoField {
+	var total, failed uint64
+	cmdstats := make([]InfoField, 0)
+
+	s.commands.Range(func(key, value any) bool {
+		command, cs := key.(model.Command), value.(*commandStats)
+		calls, fails, usec := cs.calls.Load(), cs.failed.Load(), cs.usec.Load()
+
+		total += calls
+		failed += fails
+		cmdstats = append(cmdstats, InfoField{
+			Key: "cmdstat_" + command.String(),
+			Value: fmt.Sprintf(
+				"calls=%d,failed=%d,usec=%d,usec_per_call=%.2f",
+				calls, fails, usec, float64(usec)/float64(max(calls, 1)),
+			),
+		})
+		return true
+	})
+
+	slices.SortFunc(cmdstats, func(a, b InfoField) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	fields := []InfoField{
+		{Key: "total_commands_processed", Value: strconv.FormatUint(total, 10)},
+		{Key: "total_command_errors", Value: strconv.FormatUint(failed, 10)},
+	}
+	return append(fields, cmdstats...)
+}
+
+// execINFO handles INFO [section]. Without section or with "all" every section is reported.
+func (db *Database) execINFO(_ context.Context, query model.Query) (model.Value, error) {
+	if len(query.Args) > model.CommandINFOMaxArgsLen {
+		return model.Value{}, fmt.Errorf("%w: want at most %d args", ErrInvalidArgs, model.CommandINFOMaxArgsLen)
+	}
+
+	sections := map[string]InfoFunc{
+		InfoSectionStats: db.stats.info,
+	}
+	for name, fn := range db.infoSections {
+		sections[name] = fn
+	}
+
+	names := sectionNames(sections)
+	if len(query.Args) == 1 {
+		name := strings.ToLower(query.Args[0])
+		if name != infoSectionAll && name != infoSectionDefault {
+			if _, ok := sections[name]; !ok {
+				return model.Value{}, fmt.Errorf("%w: unknown info section %s", ErrInvalidArgs, query.Args[0])
+			}
+			names = []string{name}
+		}
+	}
+
+	blocks := make([]string, 0, len(names))
+	for _, name := range names {
+		lines := []string{"# " + name}
+		for _, field := range sections[name]() {
+			lines = append(lines, field.Key+":"+field.Value)
+		}
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+
+	return model.StringValue(strings.Join(blocks, "\n\n")), nil
+}
+
+// sectionNames returns known sections in fixed order followed by others sorted by name.
+func sectionNames(sections map[string]InfoFunc) []string {
+	names := make([]string, 0, len(sections))
+	for _, name := range infoSectionsOrder {
+		if _, ok := sections[name]; ok {
+			names = append(names, name)
+		}
+	}
+
+	others := make([]string, 0)
+	for name := range sections {
+		if !slices.Contains(infoSectionsOrder, name) {
+			others = append(others, name)
+		}
+	}
+	slices.Sort(others)
+
+	return append(names, others...)
+}
@@ -6,6 +6,8 @@ import (
 	context "context"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // Storage is an autogenerated mock type for the storage type
@@ -55,6 +57,54 @@ func (_c *Storage_Del_Call) RunAndReturn(run func(context.Context, string)) *Sto
 	return _c
 }
 
+// Expire provides a mock function with given fields: ctx, key, ttl
+func (_m *Storage) Expire(ctx context.Context, key string, ttl time.Duration) bool {
+	ret := _m.Called(ctx, key, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Expire")
+	}
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) bool); ok {
+		r0 = rf(ctx, key, ttl)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// Storage_Expire_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Expire'
+type Storage_Expire_Call struct {
+	*mock.Call
+}
+
+// Expire is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - ttl time.Duration
+func (_e *Storage_Expecter) Expire(ctx interface{}, key interface{}, ttl interface{}) *Storage_Expire_Call {
+	return &Storage_Expire_Call{Call: _e.mock.On("Expire", ctx, key, ttl)}
+}
+
+func (_c *Storage_Expire_Call) Run(run func(ctx context.Context, key string, ttl time.Duration)) *Storage_Expire_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(time.Duration))
+	})
+	return _c
+}
+
+func (_c *Storage_Expire_Call) Return(_a0 bool) *Storage_Expire_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Storage_Expire_Call) RunAndReturn(run func(context.Context, string, time.Duration) bool) *Storage_Expire_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Get provides a mock function with given fields: ctx, key
 func (_m *Storage) Get(ctx context.Context, key string) (string, bool) {
 	ret := _m.Called(ctx, key)
@@ -112,6 +162,55 @@ func (_c *Storage_Get_Call) RunAndReturn(run func(context.Context, string) (stri
 	return _c
 }
 
+// Keys provides a mock function with given fields: ctx, pattern
+func (_m *Storage) Keys(ctx context.Context, pattern string) []string {
+	ret := _m.Called(ctx, pattern)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Keys")
+	}
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string) []string); ok {
+		r0 = rf(ctx, pattern)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
+// Storage_Keys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Keys'
+type Storage_Keys_Call struct {
+	*mock.Call
+}
+
+// Keys is a helper method to define mock.On call
+//   - ctx context.Context
+//   - pattern string
+func (_e *Storage_Expecter) Keys(ctx interface{}, pattern interface{}) *Storage_Keys_Call {
+	return &Storage_Keys_Call{Call: _e.mock.On("Keys", ctx, pattern)}
+}
+
+func (_c *Storage_Keys_Call) Run(run func(ctx context.Context, pattern string)) *Storage_Keys_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *Storage_Keys_Call) Return(_a0 []string) *Storage_Keys_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Storage_Keys_Call) RunAndReturn(run func(context.Context, string) []string) *Storage_Keys_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Set provides a mock function with given fields: ctx, key, value
 func (_m *Storage) Set(ctx context.Context, key string, value string) {
 	_m.Called(ctx, key, value)
@@ -147,6 +246,98 @@ func (_c *Storage_Set_Call) RunAndReturn(run func(context.Context, string, strin
 	return _c
 }
 
+// TTL provides a mock function with given fields: ctx, key
+func (_m *Storage) TTL(ctx context.Context, key string) (time.Duration, bool) {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TTL")
+	}
+
+	var r0 time.Duration
+	var r1 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string) (time.Duration, bool)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) time.Duration); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) bool); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// Storage_TTL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TTL'
+type Storage_TTL_Call struct {
+	*mock.Call
+}
+
+// TTL is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+func (_e *Storage_Expecter) TTL(ctx interface{}, key interface{}) *Storage_TTL_Call {
+	return &Storage_TTL_Call{Call: _e.mock.On("TTL", ctx, key)}
+}
+
+func (_c *Storage_TTL_Call) Run(run func(ctx context.Context, key string)) *Storage_TTL_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *Storage_TTL_Call) Return(_a0 time.Duration, _a1 bool) *Storage_TTL_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Storage_TTL_Call) RunAndReturn(run func(context.Context, string) (time.Duration, bool)) *Storage_TTL_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Update provides a mock function with given fields: ctx, key, fn
+func (_m *Storage) Update(ctx context.Context, key string, fn func(string, bool) (string, bool)) {
+	_m.Called(ctx, key, fn)
+}
+
+// Storage_Update_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Update'
+type Storage_Update_Call struct {
+	*mock.Call
+}
+
+// Update is a helper method to define mock.On call
+//   - ctx context.Context
+//   - key string
+//   - fn func(string , bool)(string , bool)
+func (_e *Storage_Expecter) Update(ctx interface{}, key interface{}, fn interface{}) *Storage_Update_Call {
+	return &Storage_Update_Call{Call: _e.mock.On("Update", ctx, key, fn)}
+}
+
+func (_c *Storage_Update_Call) Run(run func(ctx context.Context, key string, fn func(string, bool) (string, bool))) *Storage_Update_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(func(string, bool) (string, bool)))
+	})
+	return _c
+}
+
+func (_c *Storage_Update_Call) Return() *Storage_Update_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *Storage_Update_Call) RunAndReturn(run func(context.Context, string, func(string, bool) (string, bool))) *Storage_Update_Call {
+	_c.Run(run)
+	return _c
+}
+
 // NewStorage creates a new instance of Storage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewStorage(t interface {
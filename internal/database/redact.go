@@ -0,0 +1,34 @@
+package database
+
+import (
+	"kvdb/internal/model"
+	"strings"
+
+	"github.com/google/shlex"
+)
+
+const messageRedacted = "(redacted)"
+
+// redactArgs returns args of query safe to show in logs. Rules of ACL commands
+// may contain passwords, so only subcommand is kept.
+func redactArgs(query model.Query) []string {
+	if query.Command == model.CommandACL && len(query.Args) > 1 {
+		return []string{query.Args[0], messageRedacted}
+	}
+	return query.Args
+}
+
+// redactQuery returns raw query safe to show in logs, see redactArgs. Query
+// which fails to parse is checked word by word.
+func redactQuery(rawQuery string) string {
+	parts, err := shlex.Split(rawQuery)
+	if err != nil {
+		parts = strings.Fields(rawQuery)
+	}
+
+	command := model.CommandACL.String()
+	if len(parts) > 2 && strings.EqualFold(strings.Trim(parts[0], `'"`), command) {
+		return strings.Join([]string{command, parts[1], messageRedacted}, " ")
+	}
+	return rawQuery
+}
@@ -0,0 +1,225 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	parser "kvdb/internal/compute"
+	"kvdb/internal/model"
+	"kvdb/internal/session"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultSlowLogThreshold = 10 * time.Millisecond
+	DefaultSlowLogMaxLen    = 128
+
+	defaultSlowLogGetCount = 10
+
+	// Limits of arguments kept in slow log entry.
+	slowLogMaxArgs   = 32
+	slowLogMaxArgLen = 128
+)
+
+const (
+	subcommandGET   = "get"
+	subcommandLEN   = "len"
+	subcommandRESET = "reset"
+)
+
+type slowLogEntry struct {
+	id        uint64
+	timestamp time.Time
+	duration  time.Duration
+	client    string
+	args      []string
+}
+
+// slowLog keeps the latest queries which took longer than threshold in ring buffer.
+type slowLog struct {
+	threshold atomic.Int64 // Nanoseconds, negative disables logging.
+
+	mu      sync.Mutex
+	entries []slowLogEntry
+	next    int // Position of the next entry in entries.
+	size    int // Number of entries in buffer.
+	lastID  uint64
+}
+
+func newSlowLog(threshold time.Duration, maxLen int) *slowLog {
+	l := &slowLog{
+		entries: make([]slowLogEntry, max(maxLen, 0)),
+	}
+	l.threshold.Store(int64(threshold))
+	return l
+}
+
+// WithSlowLog records queries slower than threshold keeping maxLen latest
+// of them. Negative threshold disables slow log, zero logs every query.
+func (db *Database) WithSlowLog(threshold time.Duration, maxLen int) *Database {
+	db.slowLog = newSlowLog(threshold, maxLen)
+	return db
+}
+
+// SetSlowLogThreshold changes threshold of slow log at runtime.
+func (db *Database) SetSlowLogThreshold(threshold time.Duration) {
+	db.slowLog.threshold.Store(int64(threshold))
+}
+
+func (db *Database) SlowLogThreshold() time.Duration {
+	return time.Duration(db.slowLog.threshold.Load())
+}
+
+func (l *slowLog) record(ctx context.Context, query model.Query, start time.Time, elapsed time.Duration) {
+	threshold := time.Duration(l.threshold.Load())
+	if threshold < 0 || elapsed < threshold || len(l.entries) == 0 {
+		return
+	}
+
+	var client string
+	if sess, ok := session.FromContext(ctx); ok {
+		client = sess.RemoteAddr()
+	}
+
+	entry := slowLogEntry{
+		timestamp: start,
+		duration:  elapsed,
+		client:    client,
+		args:      truncateArgs(query),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lastID++
+	entry.id = l.lastID
+
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	l.size = min(l.size+1, len(l.entries))
+}
+
+// latest returns up to n entries starting from the newest one.
+func (l *slowLog) latest(n int) []slowLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n = min(n, l.size)
+	entries := make([]slowLogEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		pos := (l.next - i + len(l.entries)) % len(l.entries)
+		entries = append(entries, l.entries[pos])
+	}
+
+	return entries
+}
+
+func (l *slowLog) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.size
+}
+
+func (l *slowLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	clear(l.entries)
+	l.next = 0
+	l.size = 0
+}
+
+// truncateArgs returns command with arguments redacted by redactArgs and
+// shortened to slow log limits.
+func truncateArgs(query model.Query) []string {
+	queryArgs := redactArgs(query)
+	args := make([]string, 0, min(len(queryArgs), slowLogMaxArgs)+1)
+	args = append(args, query.Command.String())
+
+	for i, arg := range queryArgs {
+		if i == slowLogMaxArgs-1 && len(queryArgs) > slowLogMaxArgs {
+			args = append(args, fmt.Sprintf("... (%d more arguments)", len(queryArgs)-i))
+			break
+		}
+
+		if len(arg) > slowLogMaxArgLen {
+			arg = fmt.Sprintf("%s... (%d more bytes)", arg[:slowLogMaxArgLen], len(arg)-slowLogMaxArgLen)
+		}
+		args = append(args, arg)
+	}
+
+	return args
+}
+
+// execSLOWLOG handles SLOWLOG GET [count], SLOWLOG LEN and SLOWLOG RESET.
+func (db *Database) execSLOWLOG(_ context.Context, query model.Query) (model.Value, error) {
+	if len(query.Args) < model.CommandSLOWLOGMinArgsLen {
+		return model.Value{}, fmt.Errorf("%w: want at least %d args", ErrInvalidArgs, model.CommandSLOWLOGMinArgsLen)
+	}
+
+	subcommand, args := strings.ToLower(query.Args[0]), query.Args[1:]
+	switch subcommand {
+	case subcommandGET:
+		count := defaultSlowLogGetCount
+		if len(args) > 1 {
+			return model.Value{}, fmt.Errorf("%w: want optional count", ErrInvalidArgs)
+		}
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 0 {
+				return model.Value{}, fmt.Errorf("%w: count must be non-negative integer", ErrInvalidArgs)
+			}
+			count = n
+		}
+
+		entries := db.slowLog.latest(count)
+		if len(entries) == 0 {
+			return model.NilValue(), nil
+		}
+
+		lines := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			lines = append(lines, formatSlowLogEntry(entry))
+		}
+		return model.StringsValue(lines), nil
+	case subcommandLEN:
+		if len(args) != 0 {
+			return model.Value{}, fmt.Errorf("%w: want no args", ErrInvalidArgs)
+		}
+		return model.IntValue(int64(db.slowLog.len())), nil
+	case subcommandRESET:
+		if len(args) != 0 {
+			return model.Value{}, fmt.Errorf("%w: want no args", ErrInvalidArgs)
+		}
+		db.slowLog.reset()
+		return model.NoneValue(), nil
+	default:
+		return model.Value{}, fmt.Errorf("%w: unknown slowlog subcommand %s", ErrInvalidArgs, query.Args[0])
+	}
+}
+
+// formatSlowLogEntry formats entry as "key=value" fields, args are the last field.
+func formatSlowLogEntry(entry slowLogEntry) string {
+	args := make([]string, 0, len(entry.args))
+	for _, arg := range entry.args {
+		args = append(args, parser.Quote(arg))
+	}
+
+	client := entry.client
+	if client == "" {
+		client = "-"
+	}
+
+	return fmt.Sprintf(
+		"id=%d timestamp=%s duration_us=%d client=%s args=%s",
+		entry.id,
+		entry.timestamp.UTC().Format(time.RFC3339Nano),
+		entry.duration.Microseconds(),
+		client,
+		strings.Join(args, " "),
+	)
+}
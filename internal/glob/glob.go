@@ -0,0 +1,103 @@
+package glob
+
+// Match reports whether s matches glob pattern. Unlike path.Match separators
+// are not special, so "user:*" matches "user:1/profile".
+//
+// Pattern syntax:
+//
+//   - any sequence of characters
+//     ?       any single character
+//     [abc]   any character from the set, [^abc] negates, [a-z] is a range
+//     \c      matches character c literally
+func Match(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+
+	// Position to backtrack to after the last star.
+	starP, starS := -1, 0
+
+	pi, si := 0, 0
+	for si < len(str) {
+		if pi < len(p) {
+			switch p[pi] {
+			case '*':
+				starP, starS = pi, si
+				pi++
+				continue
+			case '?':
+				pi++
+				si++
+				continue
+			case '[':
+				if matched, next, ok := matchClass(p, pi, str[si]); ok && matched {
+					pi = next
+					si++
+					continue
+				}
+			case '\\':
+				if pi+1 < len(p) && p[pi+1] == str[si] {
+					pi += 2
+					si++
+					continue
+				}
+			default:
+				if p[pi] == str[si] {
+					pi++
+					si++
+					continue
+				}
+			}
+		}
+
+		if starP < 0 {
+			return false
+		}
+
+		// Let the last star consume one more character.
+		starS++
+		pi, si = starP+1, starS
+	}
+
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+
+	return pi == len(p)
+}
+
+// matchClass matches character against class starting at p[start] == '['.
+// Returns whether character matched, position after the class and false if class is malformed.
+func matchClass(p []rune, start int, c rune) (bool, int, bool) {
+	i := start + 1
+	negate := false
+	if i < len(p) && p[i] == '^' {
+		negate = true
+		i++
+	}
+
+	matched := false
+	first := true
+	for ; i < len(p); i++ {
+		if p[i] == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+		first = false
+
+		lo := p[i]
+		if lo == '\\' && i+1 < len(p) {
+			i++
+			lo = p[i]
+		}
+
+		hi := lo
+		if i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']' {
+			hi = p[i+2]
+			i += 2
+		}
+
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+
+	return false, 0, false
+}
@@ -0,0 +1,42 @@
+package glob
+
+import (
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		s        string
+		expected bool
+	}{
+		{pattern: "*", s: "", expected: true},
+		{pattern: "*", s: "anything/with/slashes", expected: true},
+		{pattern: "key", s: "key", expected: true},
+		{pattern: "key", s: "key2", expected: false},
+		{pattern: "user:*", s: "user:1/profile", expected: true},
+		{pattern: "user:*", s: "session:1", expected: false},
+		{pattern: "*:cache", s: "svc:a:cache", expected: true},
+		{pattern: "a*b*c", s: "aXXbYYc", expected: true},
+		{pattern: "a*b*c", s: "aXXbYY", expected: false},
+		{pattern: "k?y", s: "key", expected: true},
+		{pattern: "k?y", s: "ky", expected: false},
+		{pattern: "[abc]x", s: "bx", expected: true},
+		{pattern: "[abc]x", s: "dx", expected: false},
+		{pattern: "[^abc]x", s: "dx", expected: true},
+		{pattern: "[a-c]x", s: "cx", expected: true},
+		{pattern: "[a-c]x", s: "zx", expected: false},
+		{pattern: `\*`, s: "*", expected: true},
+		{pattern: `\*`, s: "a", expected: false},
+		{pattern: "[abc", s: "a", expected: false},
+		{pattern: "ключ:*", s: "ключ:значение", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.s, func(t *testing.T) {
+			if result := Match(tt.pattern, tt.s); result != tt.expected {
+				t.Errorf("expected Match(%q, %q) = %v, got: %v", tt.pattern, tt.s, tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is a time of rotation in names of rotated files. It sorts
+// in the order of rotation and has no characters special for file systems.
+const backupTimeFormat = "20060102T150405.000"
+
+const compressSuffix = ".gz"
+
+// RotationOptions describes when file is rotated and how rotated files are kept.
+// Zero value never rotates.
+type RotationOptions struct {
+	MaxSize    uint64        // Rotate before write which makes file larger, 0 disables.
+	MaxAge     time.Duration // Rotate file opened earlier than MaxAge ago, 0 disables.
+	MaxBackups int           // Number of rotated files to keep, 0 keeps all.
+	Compress   bool          // Compress rotated files with gzip.
+}
+
+// RotatingFile is an append-only log file. Rotated files are renamed to
+// <name>-<time><ext> next to the file, e.g. app-20240102T150405.000.log,
+// and compressed and pruned in background.
+type RotatingFile struct {
+	path string
+	opts RotationOptions
+	now  func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	size     uint64
+	openedAt time.Time
+
+	millMu sync.Mutex // Serializes compression and pruning of rotated files.
+	millWg sync.WaitGroup
+}
+
+// OpenRotatingFile opens file for appending, creating it if needed.
+func OpenRotatingFile(path string, opts RotationOptions) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path: path,
+		opts: opts,
+		now:  time.Now,
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.needsRotation(uint64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += uint64(n)
+	return n, err
+}
+
+func (f *RotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.file.Sync()
+}
+
+// Rotate rotates file regardless of options.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Close closes file and waits for compression and pruning of rotated files.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.millWg.Wait()
+	return err
+}
+
+func (f *RotatingFile) needsRotation(writeSize uint64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.opts.MaxSize > 0 && f.size+writeSize > f.opts.MaxSize {
+		return true
+	}
+	return f.opts.MaxAge > 0 && f.now().Sub(f.openedAt) >= f.opts.MaxAge
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed open log file: %w", err)
+	}
+
+	f.file = file
+	f.size = uint64(info.Size())
+	f.openedAt = f.now()
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed close log file: %w", err)
+	}
+	f.file = nil
+
+	if err := os.Rename(f.path, f.backupName(f.now())); err != nil {
+		// Keep writing to the same file rather than losing logs.
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed rotate log file: %w", err)
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.millWg.Add(1)
+	go func() {
+		defer f.millWg.Done()
+		f.mill()
+	}()
+
+	return nil
+}
+
+func (f *RotatingFile) backupName(t time.Time) string {
+	prefix, ext := f.backupParts()
+	return prefix + t.UTC().Format(backupTimeFormat) + ext
+}
+
+// backupParts returns prefix and extension of rotated files names.
+func (f *RotatingFile) backupParts() (string, string) {
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-", ext
+}
+
+// mill compresses rotated files and removes the oldest of them. Errors are
+// ignored, logs can't be written about failed handling of logs.
+func (f *RotatingFile) mill() {
+	f.millMu.Lock()
+	defer f.millMu.Unlock()
+
+	backups := f.backups()
+
+	if f.opts.MaxBackups > 0 && len(backups) > f.opts.MaxBackups {
+		for _, backup := range backups[:len(backups)-f.opts.MaxBackups] {
+			_ = os.Remove(backup)
+		}
+		backups = backups[len(backups)-f.opts.MaxBackups:]
+	}
+
+	if f.opts.Compress {
+		for _, backup := range backups {
+			if !strings.HasSuffix(backup, compressSuffix) {
+				_ = compressFile(backup)
+			}
+		}
+	}
+}
+
+// backups returns rotated files from the oldest to the newest.
+func (f *RotatingFile) backups() []string {
+	prefix, ext := f.backupParts()
+
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return nil
+	}
+
+	backups := make([]string, 0)
+	for _, entry := range entries {
+		name := filepath.Join(filepath.Dir(f.path), entry.Name())
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), compressSuffix), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, name)
+	}
+
+	slices.SortFunc(backups, func(a, b string) int {
+		return strings.Compare(strings.TrimSuffix(a, compressSuffix), strings.TrimSuffix(b, compressSuffix))
+	})
+	return backups
+}
+
+// compressFile replaces file with its gzip compressed copy.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+compressSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+
+	return os.Remove(path)
+}
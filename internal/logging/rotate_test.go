@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock returns time which is moved forward explicitly.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func openTestFile(t *testing.T, opts RotationOptions) (*RotatingFile, *fakeClock, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	clock := &fakeClock{t: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)}
+
+	f, err := OpenRotatingFile(path, opts)
+	require.NoError(t, err)
+	f.now = clock.now
+	f.openedAt = clock.now()
+
+	return f, clock, path
+}
+
+func readDir(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestRotatingFile_MaxSize(t *testing.T) {
+	f, clock, path := openTestFile(t, RotationOptions{MaxSize: 10})
+
+	_, err := f.Write([]byte("12345678\n"))
+	require.NoError(t, err)
+
+	clock.t = clock.t.Add(time.Second)
+	_, err = f.Write([]byte("abc\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, []string{"app-20240102T150406.000.log", "app.log"}, readDir(t, filepath.Dir(path)))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "abc\n", string(data))
+
+	data, err = os.ReadFile(filepath.Join(filepath.Dir(path), "app-20240102T150406.000.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "12345678\n", string(data))
+}
+
+func TestRotatingFile_MaxAge(t *testing.T) {
+	f, clock, path := openTestFile(t, RotationOptions{MaxAge: time.Hour})
+
+	_, err := f.Write([]byte("first\n"))
+	require.NoError(t, err)
+
+	clock.t = clock.t.Add(30 * time.Minute)
+	_, err = f.Write([]byte("second\n"))
+	require.NoError(t, err)
+	assert.Len(t, readDir(t, filepath.Dir(path)), 1)
+
+	clock.t = clock.t.Add(30 * time.Minute)
+	_, err = f.Write([]byte("third\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, []string{"app-20240102T160405.000.log", "app.log"}, readDir(t, filepath.Dir(path)))
+}
+
+func TestRotatingFile_RetentionAndCompression(t *testing.T) {
+	f, clock, path := openTestFile(t, RotationOptions{MaxBackups: 2, Compress: true})
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+
+		clock.t = clock.t.Add(time.Second)
+		require.NoError(t, f.Rotate())
+	}
+	require.NoError(t, f.Close())
+
+	dir := filepath.Dir(path)
+	assert.Equal(t, []string{
+		"app-20240102T150408.000.log.gz",
+		"app-20240102T150409.000.log.gz",
+		"app.log",
+	}, readDir(t, dir))
+
+	gzFile, err := os.Open(filepath.Join(dir, "app-20240102T150409.000.log.gz"))
+	require.NoError(t, err)
+	defer gzFile.Close()
+
+	reader, err := gzip.NewReader(gzFile)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "four\n", string(data))
+}
+
+func TestRotatingFile_Closed(t *testing.T) {
+	f, _, _ := openTestFile(t, RotationOptions{})
+	require.NoError(t, f.Close())
+
+	_, err := f.Write([]byte("line\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
@@ -0,0 +1,365 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// contentType is a content type of Prometheus text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// LatencyBuckets are histogram buckets in seconds suitable for in-memory operations.
+var LatencyBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// Registry keeps metrics and writes them in Prometheus text format. Metrics
+// are created on first use, so components sharing registry share metrics.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+// Counter returns counter family with given label names.
+func (r *Registry) Counter(name, help string, labelNames ...string) *CounterVec {
+	f := r.family(name, help, kindCounter, labelNames, func() child { return &Counter{} })
+	return &CounterVec{family: f}
+}
+
+// Gauge returns gauge family with given label names.
+func (r *Registry) Gauge(name, help string, labelNames ...string) *GaugeVec {
+	f := r.family(name, help, kindGauge, labelNames, func() child { return &Gauge{} })
+	return &GaugeVec{family: f}
+}
+
+// Histogram returns histogram family with given upper bounds of buckets.
+func (r *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	f := r.family(name, help, kindHistogram, labelNames, func() child { return newHistogram(buckets) })
+	return &HistogramVec{family: f}
+}
+
+// GaugeFunc registers gauge which value is computed by fn on every scrape.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	f := r.family(name, help, kindGauge, nil, func() child { return gaugeFunc(fn) })
+	f.with(nil)
+}
+
+func (r *Registry) family(name, help, kind string, labelNames []string, newChild func() child) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		if f.kind != kind || !slices.Equal(f.labelNames, labelNames) {
+			panic(fmt.Sprintf("metrics: %s registered with different type or labels", name))
+		}
+		return f
+	}
+
+	f := &family{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		children:   make(map[string]child),
+		newChild:   newChild,
+	}
+	r.families[name] = f
+
+	return f
+}
+
+// WriteTo writes all metrics sorted by name in Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+
+	slices.SortFunc(families, func(a, b *family) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range families {
+		f.write(bw)
+	}
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_, _ = r.WriteTo(w)
+}
+
+type CounterVec struct {
+	family *family
+}
+
+// With returns counter for label values in order of label names.
+func (v *CounterVec) With(labelValues ...string) *Counter {
+	return v.family.with(labelValues).(*Counter)
+}
+
+type GaugeVec struct {
+	family *family
+}
+
+// With returns gauge for label values in order of label names.
+func (v *GaugeVec) With(labelValues ...string) *Gauge {
+	return v.family.with(labelValues).(*Gauge)
+}
+
+type HistogramVec struct {
+	family *family
+}
+
+// With returns histogram for label values in order of label names.
+func (v *HistogramVec) With(labelValues ...string) *Histogram {
+	return v.family.with(labelValues).(*Histogram)
+}
+
+type Counter struct {
+	value atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) Add(n uint64) {
+	c.value.Add(n)
+}
+
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+func (c *Counter) write(w *bufio.Writer, name, labels string) {
+	writeSample(w, name, labels, strconv.FormatUint(c.Value(), 10))
+}
+
+type Gauge struct {
+	value atomic.Int64
+}
+
+func (g *Gauge) Inc() {
+	g.value.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.value.Add(-1)
+}
+
+func (g *Gauge) Set(value int64) {
+	g.value.Store(value)
+}
+
+func (g *Gauge) Value() int64 {
+	return g.value.Load()
+}
+
+func (g *Gauge) write(w *bufio.Writer, name, labels string) {
+	writeSample(w, name, labels, strconv.FormatInt(g.Value(), 10))
+}
+
+type gaugeFunc func() float64
+
+func (g gaugeFunc) write(w *bufio.Writer, name, labels string) {
+	writeSample(w, name, labels, formatFloat(g()))
+}
+
+type Histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64 // Non-cumulative counts of buckets.
+	count   atomic.Uint64
+	sumBits atomic.Uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]atomic.Uint64, len(buckets)),
+	}
+}
+
+func (h *Histogram) Observe(value float64) {
+	if i, _ := slices.BinarySearch(h.buckets, value); i < len(h.buckets) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+
+	for {
+		old := h.sumBits.Load()
+		sum := math.Float64frombits(old) + value
+		if h.sumBits.CompareAndSwap(old, math.Float64bits(sum)) {
+			return
+		}
+	}
+}
+
+// Count returns number of observations.
+func (h *Histogram) Count() uint64 {
+	return h.count.Load()
+}
+
+// Sum returns sum of observations.
+func (h *Histogram) Sum() float64 {
+	return math.Float64frombits(h.sumBits.Load())
+}
+
+func (h *Histogram) write(w *bufio.Writer, name, labels string) {
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i].Load()
+		writeSample(w, name+"_bucket", joinLabels(labels, `le="`+formatFloat(bound)+`"`), strconv.FormatUint(cumulative, 10))
+	}
+
+	count := h.Count()
+	writeSample(w, name+"_bucket", joinLabels(labels, `le="+Inf"`), strconv.FormatUint(count, 10))
+	writeSample(w, name+"_sum", labels, formatFloat(h.Sum()))
+	writeSample(w, name+"_count", labels, strconv.FormatUint(count, 10))
+}
+
+type child interface {
+	write(w *bufio.Writer, name, labels string)
+}
+
+type family struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	newChild   func() child
+
+	mu       sync.RWMutex
+	children map[string]child // By formatted labels.
+}
+
+func (f *family) with(labelValues []string) child {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+
+	labels := formatLabels(f.labelNames, labelValues)
+
+	f.mu.RLock()
+	c, ok := f.children[labels]
+	f.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if c, ok := f.children[labels]; ok {
+		return c
+	}
+	c = f.newChild()
+	f.children[labels] = c
+
+	return c
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if len(f.children) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+
+	labels := make([]string, 0, len(f.children))
+	for l := range f.children {
+		labels = append(labels, l)
+	}
+	slices.Sort(labels)
+
+	for _, l := range labels {
+		f.children[l].write(w, f.name, l)
+	}
+}
+
+func writeSample(w *bufio.Writer, name, labels, value string) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + value + "\n")
+}
+
+func formatLabels(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i := range names {
+		pairs[i] = names[i] + `="` + escapeLabel(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+var (
+	labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelReplacer.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
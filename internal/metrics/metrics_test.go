@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	registry := NewRegistry()
+
+	commands := registry.Counter("kvdb_commands_total", "Number of commands.", "command")
+	commands.With("set").Add(2)
+	commands.With("get").Inc()
+
+	registry.Gauge("kvdb_connections_active", "Number of open connections.", "listener").With(`a"b`).Set(3)
+	registry.GaugeFunc("kvdb_keys", "Number of keys.", func() float64 { return 42 })
+
+	latency := registry.Histogram("kvdb_command_duration_seconds", "Command latency.", []float64{0.1, 1}, "command")
+	latency.With("get").Observe(0.05)
+	latency.With("get").Observe(0.5)
+	latency.With("get").Observe(2)
+
+	// Families without series are skipped.
+	registry.Counter("kvdb_unused_total", "Unused.")
+
+	var buf bytes.Buffer
+	_, err := registry.WriteTo(&buf)
+	require.NoError(t, err)
+
+	expected := `# HELP kvdb_command_duration_seconds Command latency.
+# TYPE kvdb_command_duration_seconds histogram
+kvdb_command_duration_seconds_bucket{command="get",le="0.1"} 1
+kvdb_command_duration_seconds_bucket{command="get",le="1"} 2
+kvdb_command_duration_seconds_bucket{command="get",le="+Inf"} 3
+kvdb_command_duration_seconds_sum{command="get"} 2.55
+kvdb_command_duration_seconds_count{command="get"} 3
+# HELP kvdb_commands_total Number of commands.
+# TYPE kvdb_commands_total counter
+kvdb_commands_total{command="get"} 1
+kvdb_commands_total{command="set"} 2
+# HELP kvdb_connections_active Number of open connections.
+# TYPE kvdb_connections_active gauge
+kvdb_connections_active{listener="a\"b"} 3
+# HELP kvdb_keys Number of keys.
+# TYPE kvdb_keys gauge
+kvdb_keys 42
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestRegistry_SharedFamilies(t *testing.T) {
+	registry := NewRegistry()
+
+	registry.Counter("requests_total", "Requests.", "listener").With("a").Inc()
+	registry.Counter("requests_total", "Requests.", "listener").With("a").Inc()
+	assert.Equal(t, uint64(2), registry.Counter("requests_total", "Requests.", "listener").With("a").Value())
+
+	assert.Panics(t, func() {
+		registry.Gauge("requests_total", "Requests.", "listener")
+	})
+	assert.Panics(t, func() {
+		registry.Counter("requests_total", "Requests.").With("a", "b")
+	})
+}
+
+func TestRegistry_Concurrent(t *testing.T) {
+	registry := NewRegistry()
+	latency := registry.Histogram("latency", "Latency.", LatencyBuckets, "command")
+
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				latency.With("get").Observe(0.001)
+				_, _ = registry.WriteTo(&bytes.Buffer{})
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, uint64(1000), latency.With("get").Count())
+	assert.InDelta(t, 1.0, latency.With("get").Sum(), 1e-9)
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	registry.Counter("hits_total", "Hits.").With().Inc()
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "hits_total 1\n")
+}
@@ -3,19 +3,131 @@ package model
 type Command int
 
 const (
-	CommandUNK Command = iota // Unknown command
-	CommandGET                // GET key
-	CommandSET                // SET key value
-	CommandDEL                // DEL key
+	CommandUNK     Command = iota // Unknown command
+	CommandGET                    // GET key
+	CommandSET                    // SET key value [NX|XX]
+	CommandDEL                    // DEL key
+	CommandACL                    // ACL subcommand [args...]
+	CommandINFO                   // INFO [section]
+	CommandSLOWLOG                // SLOWLOG GET [count] | LEN | RESET
+	CommandMONITOR                // MONITOR, handled by connection
+	CommandCLIENT                 // CLIENT subcommand [arg]
+	CommandCONFIG                 // CONFIG GET pattern | SET name value | REWRITE
+	CommandINCR                   // INCR key
+	CommandINCRBY                 // INCRBY key delta
+	CommandEXPIRE                 // EXPIRE key seconds
+	CommandTTL                    // TTL key
 )
 
 const (
-	CommandGETArgsLen = 1
-	CommandSETArgsLen = 2
-	CommandDELArgsLen = 1
+	CommandGETArgsLen        = 1
+	CommandSETMinArgsLen     = 2
+	CommandSETMaxArgsLen     = 3
+	CommandDELArgsLen        = 1
+	CommandACLMinArgsLen     = 1
+	CommandINFOMaxArgsLen    = 1
+	CommandSLOWLOGMinArgsLen = 1
+	CommandSLOWLOGMaxArgsLen = 2
+	CommandCLIENTMinArgsLen  = 1
+	CommandCLIENTMaxArgsLen  = 2
+	CommandCONFIGMinArgsLen  = 1
+	CommandCONFIGMaxArgsLen  = 3
+	CommandINCRArgsLen       = 1
+	CommandINCRBYArgsLen     = 2
+	CommandEXPIREArgsLen     = 2
+	CommandTTLArgsLen        = 1
 )
 
+// Category groups commands for access control.
+type Category int
+
+const (
+	CategoryRead Category = 1 << iota
+	CategoryWrite
+	CategoryAdmin
+
+	CategoryNone Category = 0
+	CategoryAll           = CategoryRead | CategoryWrite | CategoryAdmin
+)
+
+var commandNamesMap = map[Command]string{
+	CommandGET:     "get",
+	CommandSET:     "set",
+	CommandDEL:     "del",
+	CommandACL:     "acl",
+	CommandINFO:    "info",
+	CommandSLOWLOG: "slowlog",
+	CommandMONITOR: "monitor",
+	CommandCLIENT:  "client",
+	CommandCONFIG:  "config",
+	CommandINCR:    "incr",
+	CommandINCRBY:  "incrby",
+	CommandEXPIRE:  "expire",
+	CommandTTL:     "ttl",
+}
+
+var categoriesMap = map[Command]Category{
+	CommandGET:     CategoryRead,
+	CommandSET:     CategoryWrite,
+	CommandDEL:     CategoryWrite,
+	CommandACL:     CategoryAdmin,
+	CommandINFO:    CategoryAdmin,
+	CommandSLOWLOG: CategoryAdmin,
+	CommandMONITOR: CategoryAdmin,
+	CommandCLIENT:  CategoryAdmin,
+	CommandCONFIG:  CategoryAdmin,
+	CommandINCR:    CategoryWrite,
+	CommandINCRBY:  CategoryWrite,
+	CommandEXPIRE:  CategoryWrite,
+	CommandTTL:     CategoryRead,
+}
+
+var categoryNamesMap = map[Category]string{
+	CategoryRead:  "read",
+	CategoryWrite: "write",
+	CategoryAdmin: "admin",
+	CategoryAll:   "all",
+}
+
+func (c Command) String() string {
+	if name, ok := commandNamesMap[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+func (c Command) Category() Category {
+	return categoriesMap[c]
+}
+
+func (c Category) String() string {
+	if name, ok := categoryNamesMap[c]; ok {
+		return name
+	}
+	return "none"
+}
+
+func ParseCategory(name string) (Category, bool) {
+	for category, categoryName := range categoryNamesMap {
+		if categoryName == name {
+			return category, true
+		}
+	}
+	return CategoryNone, false
+}
+
 type Query struct {
 	Command Command
 	Args    []string
 }
+
+// Keys returns keys accessed by query.
+func (q Query) Keys() []string {
+	switch q.Command {
+	case CommandGET, CommandSET, CommandDEL, CommandINCR, CommandINCRBY, CommandEXPIRE, CommandTTL:
+		if len(q.Args) > 0 {
+			return q.Args[:1]
+		}
+	}
+	return nil
+}
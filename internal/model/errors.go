@@ -0,0 +1,99 @@
+package model
+
+import (
+	"errors"
+	"strings"
+)
+
+// Codes of errors sent to clients. Codes are stable, messages may change.
+const (
+	CodeErr        ErrorCode = "ERR"        // Failure without more specific code.
+	CodeSyntax     ErrorCode = "SYNTAX"     // Query or message can't be parsed.
+	CodeUnknownCmd ErrorCode = "UNKNOWNCMD" // Command doesn't exist.
+	CodeWrongArgs  ErrorCode = "WRONGARGS"  // Wrong number of arguments or invalid argument.
+	CodeWrongType  ErrorCode = "WRONGTYPE"  // Value has other type, like INCR of value which is not an integer.
+	CodeOOM        ErrorCode = "OOM"        // Memory limit reached. Reserved, memory is not limited now.
+	CodeNoAuth     ErrorCode = "NOAUTH"     // Authentication required.
+	CodeWrongPass  ErrorCode = "WRONGPASS"  // Invalid user name or password.
+	CodeNoPerm     ErrorCode = "NOPERM"     // Command is not allowed for user or listener.
+	CodeReadOnly   ErrorCode = "READONLY"   // Write command on read only listener.
+	CodeBusy       ErrorCode = "BUSY"       // Server can't run command now, retry later.
+	CodeLimit      ErrorCode = "LIMIT"      // Client exceeded limit, like failed auth attempts.
+	CodeTooLarge   ErrorCode = "TOOLARGE"   // Message exceeds max message size.
+	CodeNotFound   ErrorCode = "NOTFOUND"   // Object named in arguments doesn't exist, like user or client.
+	CodeDisabled   ErrorCode = "DISABLED"   // Feature is disabled in config.
+)
+
+// Errors of catalogue. Every error with code matches the error of the same code
+// with errors.Is, e.g. errors.Is(err, ErrWrongArgs).
+var (
+	ErrErr        = &CodedError{Code: CodeErr}
+	ErrSyntax     = &CodedError{Code: CodeSyntax}
+	ErrUnknownCmd = &CodedError{Code: CodeUnknownCmd}
+	ErrWrongArgs  = &CodedError{Code: CodeWrongArgs}
+	ErrWrongType  = &CodedError{Code: CodeWrongType}
+	ErrOOM        = &CodedError{Code: CodeOOM}
+	ErrNoAuth     = &CodedError{Code: CodeNoAuth}
+	ErrWrongPass  = &CodedError{Code: CodeWrongPass}
+	ErrNoPerm     = &CodedError{Code: CodeNoPerm}
+	ErrReadOnly   = &CodedError{Code: CodeReadOnly}
+	ErrBusy       = &CodedError{Code: CodeBusy}
+	ErrLimit      = &CodedError{Code: CodeLimit}
+	ErrTooLarge   = &CodedError{Code: CodeTooLarge}
+	ErrNotFound   = &CodedError{Code: CodeNotFound}
+	ErrDisabled   = &CodedError{Code: CodeDisabled}
+)
+
+// Codes lists codes of catalogue.
+var Codes = []ErrorCode{
+	CodeErr, CodeSyntax, CodeUnknownCmd, CodeWrongArgs, CodeWrongType, CodeOOM, CodeNoAuth, CodeWrongPass,
+	CodeNoPerm, CodeReadOnly, CodeBusy, CodeLimit, CodeTooLarge, CodeNotFound, CodeDisabled,
+}
+
+// CodedError is an error with code. Without wrapped error it is an error of catalogue.
+type CodedError struct {
+	Code ErrorCode
+	Err  error
+}
+
+// WithCode assigns code to err. Message and identity of err are kept, so
+// sentinel errors of packages get codes without changing their users.
+func WithCode(code ErrorCode, err error) error {
+	return &CodedError{Code: code, Err: err}
+}
+
+func (e *CodedError) Error() string {
+	if e.Err == nil {
+		return strings.ToLower(string(e.Code)) + " error"
+	}
+	return e.Err.Error()
+}
+
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches error of catalogue with the same code.
+func (e *CodedError) Is(target error) bool {
+	t, ok := target.(*CodedError)
+	return ok && t.Err == nil && t.Code == e.Code
+}
+
+// CodeOf returns code of the outermost error with code in chain of err.
+func CodeOf(err error) (ErrorCode, bool) {
+	var codedErr *CodedError
+	if errors.As(err, &codedErr) {
+		return codedErr.Code, true
+	}
+	return "", false
+}
+
+// ParseCode returns code of catalogue named by s.
+func ParseCode(s string) (ErrorCode, bool) {
+	for _, code := range Codes {
+		if string(code) == s {
+			return code, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodedError(t *testing.T) {
+	sentinel := WithCode(CodeWrongArgs, errors.New("invalid args"))
+	err := fmt.Errorf("failed run query: %w: want 1 args", sentinel)
+
+	assert.Equal(t, "failed run query: invalid args: want 1 args", err.Error())
+	assert.ErrorIs(t, err, sentinel)
+	assert.ErrorIs(t, err, ErrWrongArgs)
+	assert.NotErrorIs(t, err, ErrSyntax)
+	assert.NotErrorIs(t, ErrWrongArgs, sentinel, "catalogue error doesn't match errors with code")
+
+	code, ok := CodeOf(err)
+	assert.True(t, ok)
+	assert.Equal(t, CodeWrongArgs, code)
+
+	_, ok = CodeOf(errors.New("boom"))
+	assert.False(t, ok)
+
+	assert.Equal(t, "wrongargs error", ErrWrongArgs.Error())
+}
+
+func TestCodeOf_Outermost(t *testing.T) {
+	err := WithCode(CodeReadOnly, fmt.Errorf("%w: write commands are disabled", WithCode(CodeNoPerm, errors.New("not allowed"))))
+
+	code, ok := CodeOf(err)
+	assert.True(t, ok)
+	assert.Equal(t, CodeReadOnly, code)
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, err, ErrNoPerm)
+}
+
+func TestParseCode(t *testing.T) {
+	for _, code := range Codes {
+		parsed, ok := ParseCode(string(code))
+		assert.True(t, ok)
+		assert.Equal(t, code, parsed)
+	}
+
+	_, ok := ParseCode("syntax")
+	assert.False(t, ok)
+}
+
+func TestFailure(t *testing.T) {
+	result := Failure(fmt.Errorf("failed auth: %w", WithCode(CodeNoAuth, errors.New("authentication required"))), CodeErr)
+	assert.Equal(t, CodeNoAuth, result.Code)
+
+	result = Failure(errors.New("boom"), CodeErr)
+	assert.Equal(t, CodeErr, result.Code)
+	assert.Equal(t, "boom", result.String())
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// KeyEvent describes change of a key made by write command, like SET or DEL.
+type KeyEvent struct {
+	Command Command
+	Key     string
+}
+
+// QueryEvent describes query received by database, it is streamed by MONITOR command.
+type QueryEvent struct {
+	Time       time.Time
+	ClientAddr string
+	Query      string
+}
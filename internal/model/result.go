@@ -0,0 +1,129 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	textOK  = "ok"
+	textNil = "nil"
+)
+
+// Status tells whether command succeeded.
+type Status int
+
+const (
+	StatusOK    Status = iota // Command succeeded, Value holds payload.
+	StatusError               // Command failed, Code and Err describe failure.
+)
+
+// Kind is a type of result payload.
+type Kind int
+
+const (
+	KindNone   Kind = iota // No payload, like result of SET.
+	KindNil                // Missing value, like GET of absent key.
+	KindString             // Str holds payload.
+	KindInt                // Int holds payload.
+	KindArray              // Array holds payload.
+)
+
+// ErrorCode is a machine readable class of failure, see Codes.
+type ErrorCode string
+
+// Value is a typed payload of result.
+type Value struct {
+	Kind  Kind
+	Str   string
+	Int   int64
+	Array []Value
+}
+
+func NoneValue() Value {
+	return Value{Kind: KindNone}
+}
+
+func NilValue() Value {
+	return Value{Kind: KindNil}
+}
+
+func StringValue(s string) Value {
+	return Value{Kind: KindString, Str: s}
+}
+
+func IntValue(n int64) Value {
+	return Value{Kind: KindInt, Int: n}
+}
+
+func ArrayValue(values []Value) Value {
+	return Value{Kind: KindArray, Array: values}
+}
+
+// StringsValue returns array of strings.
+func StringsValue(items []string) Value {
+	values := make([]Value, 0, len(items))
+	for _, item := range items {
+		values = append(values, StringValue(item))
+	}
+	return ArrayValue(values)
+}
+
+// String renders value for humans: "ok" without payload, "nil" for missing
+// value and array items on separate lines.
+func (v Value) String() string {
+	switch v.Kind {
+	case KindNil:
+		return textNil
+	case KindString:
+		return v.Str
+	case KindInt:
+		return strconv.FormatInt(v.Int, 10)
+	case KindArray:
+		lines := make([]string, 0, len(v.Array))
+		for _, item := range v.Array {
+			lines = append(lines, item.String())
+		}
+		return strings.Join(lines, "\n")
+	default:
+		return textOK
+	}
+}
+
+// Result is an outcome of command.
+type Result struct {
+	Status Status
+	Value  Value
+	Code   ErrorCode // Empty on success.
+	Err    error     // Nil on success.
+}
+
+// OK returns successful result with value.
+func OK(value Value) Result {
+	return Result{Status: StatusOK, Value: value}
+}
+
+// Error returns failed result.
+func Error(code ErrorCode, err error) Result {
+	return Result{Status: StatusError, Code: code, Err: err}
+}
+
+// Failure returns failed result with code of err, fallback is used when err has no code.
+func Failure(err error, fallback ErrorCode) Result {
+	code, ok := CodeOf(err)
+	if !ok {
+		code = fallback
+	}
+	return Error(code, err)
+}
+
+// String renders result for humans, failed result is rendered as its error message.
+func (r Result) String() string {
+	if r.Status == StatusError {
+		if r.Err == nil {
+			return string(r.Code)
+		}
+		return r.Err.Error()
+	}
+	return r.Value.String()
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResult_String(t *testing.T) {
+	tests := []struct {
+		result   Result
+		expected string
+	}{
+		{result: OK(NoneValue()), expected: "ok"},
+		{result: OK(NilValue()), expected: "nil"},
+		{result: OK(StringValue("value")), expected: "value"},
+		{result: OK(IntValue(-7)), expected: "-7"},
+		{result: OK(StringsValue([]string{"a", "b"})), expected: "a\nb"},
+		{result: OK(StringsValue(nil)), expected: ""},
+		{result: OK(ArrayValue([]Value{IntValue(1), NilValue()})), expected: "1\nnil"},
+		{result: Error(CodeErr, errors.New("failed run query: boom")), expected: "failed run query: boom"},
+		{result: Result{Status: StatusError, Code: CodeSyntax}, expected: "SYNTAX"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tt.result.String())
+	}
+}
@@ -2,28 +2,52 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"kvdb/internal/model"
 	"net"
+	"strings"
+	"sync"
 )
 
 const (
 	defaultBufferSize = 2 * 1024 // 2KB
+
+	prefixError = "(error) "
+	prefixTrace = "trace "
 )
 
+// TCPClient sends raw queries over connection. Send is safe for concurrent use,
+// requests of concurrent callers are sent one after another.
+//
+// Connection fails when server restarts. Client created with WithReconnect
+// closes failed connection and dials new one with backoff. Request sent when
+// connection failed is replayed over new connection only if retry policy allows,
+// otherwise error is returned and the next request uses new connection.
 type TCPClient struct {
-	conn net.Conn
+	mu   sync.Mutex
+	conn net.Conn // Nil when connection failed and reconnect did not succeed yet.
 	opts opts
 }
 
 type opts struct {
 	bufferSize int // Buffer size, default 2KB.
+
+	dial      Dialer // Reconnect is disabled when nil.
+	backoff   Backoff
+	handshake Handshake
+	retry     RetryPolicy // Default RetryIdempotent.
+	onEvent   func(ReconnectEvent)
 }
 
+var ErrNotConnected = errors.New("not connected")
+
 func New(conn net.Conn) *TCPClient {
 	return &TCPClient{
 		conn: conn,
 		opts: opts{
 			bufferSize: defaultBufferSize,
+			retry:      RetryIdempotent,
 		},
 	}
 }
@@ -33,7 +57,33 @@ func (c *TCPClient) WithBufferSize(bufferSize int) *TCPClient {
 	return c
 }
 
-func (c *TCPClient) Send(_ context.Context, request []byte) ([]byte, error) {
+// WithReconnect enables reconnect using dial when connection fails.
+func (c *TCPClient) WithReconnect(dial Dialer, backoff Backoff) *TCPClient {
+	c.opts.dial = dial
+	c.opts.backoff = backoff
+	return c
+}
+
+// WithHandshake sets handshake run over every new connection.
+func (c *TCPClient) WithHandshake(handshake Handshake) *TCPClient {
+	c.opts.handshake = handshake
+	return c
+}
+
+// WithRetryPolicy sets policy of requests replayed after reconnect, default RetryIdempotent.
+func (c *TCPClient) WithRetryPolicy(retry RetryPolicy) *TCPClient {
+	c.opts.retry = retry
+	return c
+}
+
+// WithReconnectHook sets hook called on reconnect events. Hook is called while
+// requests wait for reconnect, so it must not send requests.
+func (c *TCPClient) WithReconnectHook(hook func(ReconnectEvent)) *TCPClient {
+	c.opts.onEvent = hook
+	return c
+}
+
+func (c *TCPClient) Send(ctx context.Context, request []byte) ([]byte, error) {
 	if len(request) == 0 {
 		return []byte{}, nil
 	}
@@ -42,6 +92,47 @@ func (c *TCPClient) Send(_ context.Context, request []byte) ([]byte, error) {
 		request = append(request, '\n')
 	}
 
+	// Response must be read by the caller who wrote request.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if c.opts.dial == nil {
+			return []byte{}, ErrNotConnected
+		}
+		// Request was not sent yet, so any request may be sent over new connection.
+		if err := c.reconnect(ctx); err != nil {
+			return []byte{}, err
+		}
+	}
+
+	response, err := c.roundTrip(ctx, request)
+	if err == nil || c.opts.dial == nil {
+		return response, err
+	}
+
+	c.disconnect(err)
+	if !c.opts.retry(request) {
+		return []byte{}, err
+	}
+	if reconnectErr := c.reconnect(ctx); reconnectErr != nil {
+		return []byte{}, fmt.Errorf("%w: %w", err, reconnectErr)
+	}
+
+	response, err = c.roundTrip(ctx, request)
+	if err != nil {
+		c.disconnect(err)
+		return []byte{}, err
+	}
+	return response, nil
+}
+
+func (c *TCPClient) roundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return []byte{}, fmt.Errorf("failed set deadline: %w", err)
+	}
+
 	if _, err := c.conn.Write(request); err != nil {
 		return []byte{}, fmt.Errorf("failed write conn: %w", err)
 	}
@@ -55,6 +146,87 @@ func (c *TCPClient) Send(_ context.Context, request []byte) ([]byte, error) {
 	return responseBuf[:responseSize], nil
 }
 
+// disconnect closes failed connection, position in stream is unknown after failure.
+func (c *TCPClient) disconnect(err error) {
+	_ = c.conn.Close()
+	c.conn = nil
+	c.notify(ReconnectEvent{Kind: EventDisconnected, Err: err})
+}
+
+// reconnect dials new connection and runs handshake, retrying with backoff.
+func (c *TCPClient) reconnect(ctx context.Context) error {
+	return Reconnect(ctx, c.opts.backoff, c.connect, c.notify)
+}
+
+func (c *TCPClient) connect(ctx context.Context) error {
+	conn, err := c.opts.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("failed dial: %w", err)
+	}
+	c.conn = conn
+
+	if c.opts.handshake == nil {
+		return nil
+	}
+	if err := c.opts.handshake(ctx, c.roundTrip); err != nil {
+		_ = conn.Close()
+		c.conn = nil
+		return fmt.Errorf("failed handshake: %w", err)
+	}
+	return nil
+}
+
+func (c *TCPClient) notify(event ReconnectEvent) {
+	if c.opts.onEvent != nil {
+		c.opts.onEvent(event)
+	}
+}
+
+// Do sends request and returns text of response. Error response is returned as
+// error with code of response, so it can be checked with errors.Is, e.g.
+// errors.Is(err, model.ErrWrongArgs).
+func (c *TCPClient) Do(ctx context.Context, request string) (string, error) {
+	response, err := c.Send(ctx, []byte(request))
+	if err != nil {
+		return "", err
+	}
+	return ParseResponse(response)
+}
+
+// ParseResponse parses response of text protocol. Trace prefix is dropped.
+// Error response "(error) <code> <message>" is returned as error with code,
+// unknown codes are reported as model.CodeErr.
+func ParseResponse(response []byte) (string, error) {
+	text := strings.TrimSuffix(string(response), "\n")
+	if rest, ok := strings.CutPrefix(text, prefixTrace); ok {
+		if _, result, found := strings.Cut(rest, " "); found {
+			text = result
+		}
+	}
+
+	rest, ok := strings.CutPrefix(text, prefixError)
+	if !ok {
+		return text, nil
+	}
+
+	name, message, _ := strings.Cut(rest, " ")
+	code, ok := model.ParseCode(name)
+	if !ok {
+		code, message = model.CodeErr, rest
+	}
+	return "", model.WithCode(code, errors.New(message))
+}
+
 func (c *TCPClient) Close() error {
-	return c.conn.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Closed client must not reconnect.
+	c.opts.dial = nil
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
 }
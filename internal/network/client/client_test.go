@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"kvdb/internal/model"
 	"net"
 	"testing"
 	"time"
@@ -144,3 +145,61 @@ func TestClose(t *testing.T) {
 
 	require.True(t, mockConn.Closed)
 }
+
+// TestParseResponse tests that error responses are returned as errors with code.
+func TestParseResponse(t *testing.T) {
+	tests := []struct {
+		name             string
+		response         string
+		expectedText     string
+		expectedErr      error
+		expectedErrorMsg string
+	}{
+		{name: "ok", response: "ok", expectedText: "ok"},
+		{name: "value", response: "value\n", expectedText: "value"},
+		{name: "traced", response: "trace req-1 value", expectedText: "value"},
+		{
+			name:             "error",
+			response:         "(error) WRONGARGS failed run query: invalid arguments: want 1 args",
+			expectedErr:      model.ErrWrongArgs,
+			expectedErrorMsg: "failed run query: invalid arguments: want 1 args",
+		},
+		{
+			name:             "traced error",
+			response:         "trace req-1 (error) NOAUTH failed auth: authentication required",
+			expectedErr:      model.ErrNoAuth,
+			expectedErrorMsg: "failed auth: authentication required",
+		},
+		{
+			name:             "unknown code",
+			response:         "(error) NEWCODE boom",
+			expectedErr:      model.ErrErr,
+			expectedErrorMsg: "NEWCODE boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := ParseResponse([]byte(tt.response))
+			if tt.expectedErr == nil {
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedText, text)
+				return
+			}
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.EqualError(t, err, tt.expectedErrorMsg)
+		})
+	}
+}
+
+// TestDo_Error tests that Do returns error response as error with code.
+func TestDo_Error(t *testing.T) {
+	mockConn := &MockConn{
+		ReadBuffer:  bytes.NewBufferString("(error) UNKNOWNCMD failed parse query: invalid query: unknown command: FOO"),
+		WriteBuffer: new(bytes.Buffer),
+	}
+
+	_, err := New(mockConn).Do(context.Background(), "FOO")
+	require.ErrorIs(t, err, model.ErrUnknownCmd)
+}
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"kvdb/internal/model"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/shlex"
+)
+
+const (
+	defaultBackoffInitial    = 100 * time.Millisecond
+	defaultBackoffMax        = 10 * time.Second
+	defaultBackoffMultiplier = 2
+	defaultBackoffJitter     = 0.2
+)
+
+// Dialer opens new connection to server.
+type Dialer func(ctx context.Context) (net.Conn, error)
+
+// Handshake prepares new connection before it is used, e.g. sends AUTH. Session
+// state of server is lost with connection, so handshake runs after every reconnect.
+// send writes request over the new connection and returns response.
+type Handshake func(ctx context.Context, send func(ctx context.Context, request []byte) ([]byte, error)) error
+
+// RetryPolicy reports whether request may be sent again over new connection
+// after connection failed while request was sent. Server may have executed
+// request before connection failed, so only idempotent requests are safe to replay.
+type RetryPolicy func(request []byte) bool
+
+// Backoff is a delay between reconnect attempts. Delay starts at Initial and is
+// multiplied by Multiplier after every failed attempt up to Max. Every delay is
+// randomly changed by up to Jitter of it, so clients disconnected by restart of
+// server don't reconnect all at once.
+type Backoff struct {
+	Initial     time.Duration // Delay after the first failed attempt, default 100ms.
+	Max         time.Duration // Max delay, default 10s.
+	Multiplier  float64       // Growth of delay, default 2.
+	Jitter      float64       // Fraction of delay, from 0 to 1, default 0.2.
+	MaxAttempts int           // Attempts of one reconnect, 0 means until context is done.
+}
+
+// withDefaults returns backoff with zero fields set to defaults.
+func (b Backoff) withDefaults() Backoff {
+	if b.Initial <= 0 {
+		b.Initial = defaultBackoffInitial
+	}
+	if b.Max <= 0 {
+		b.Max = defaultBackoffMax
+	}
+	if b.Max < b.Initial {
+		b.Max = b.Initial
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = defaultBackoffMultiplier
+	}
+	if b.Jitter <= 0 || b.Jitter > 1 {
+		b.Jitter = defaultBackoffJitter
+	}
+	return b
+}
+
+// Delay returns delay after failed attempt, attempts are numbered from 1.
+func (b Backoff) Delay(attempt int) time.Duration {
+	b = b.withDefaults()
+
+	delay := float64(b.Initial)
+	for i := 1; i < attempt && delay < float64(b.Max); i++ {
+		delay *= b.Multiplier
+	}
+	delay = min(delay, float64(b.Max))
+
+	// Random value in [delay-jitter, delay+jitter).
+	jitter := delay * b.Jitter
+	return time.Duration(delay - jitter + rand.Float64()*2*jitter)
+}
+
+// EventKind is a kind of reconnect event.
+type EventKind int
+
+const (
+	EventDisconnected    EventKind = iota // Connection failed and was closed.
+	EventReconnectFailed                  // Attempt to reconnect failed.
+	EventReconnected                      // New connection is ready.
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventDisconnected:
+		return "disconnected"
+	case EventReconnectFailed:
+		return "reconnect failed"
+	case EventReconnected:
+		return "reconnected"
+	default:
+		return "unknown"
+	}
+}
+
+// ReconnectEvent describes change of connection state.
+type ReconnectEvent struct {
+	Kind    EventKind
+	Attempt int           // Number of reconnect attempt, 0 for EventDisconnected.
+	Delay   time.Duration // Delay before the next attempt for EventReconnectFailed.
+	Err     error         // Error which broke connection or failed attempt.
+}
+
+// RetryIdempotent replays requests allowed by Idempotent. Requests with TRACE
+// prefix are checked by the traced request.
+func RetryIdempotent(request []byte) bool {
+	args, err := shlex.Split(string(request))
+	if err != nil {
+		return false
+	}
+	if len(args) > 2 && strings.EqualFold(args[0], "trace") {
+		args = args[2:]
+	}
+	return Idempotent(args)
+}
+
+// RetryNever never replays requests, failed request returns error even when
+// connection is restored.
+func RetryNever([]byte) bool {
+	return false
+}
+
+// Idempotent reports whether command with args, including command name, may be
+// executed twice: GET, TTL, DEL and SET without NX or XX. Replayed SET writes the
+// same value again, while SET NX or XX would report whether the first attempt set
+// value, INCR would add delta twice and EXPIRE would count TTL from the replay.
+func Idempotent(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "get", "ttl", "del":
+		return true
+	case "set":
+		return len(args) == 3
+	default:
+		return false
+	}
+}
+
+// Reconnect calls connect until it succeeds, sleeping by backoff between attempts.
+// It gives up when attempts of backoff are exhausted, ctx is done or connect
+// returns error with code: server rejected connection, e.g. password is wrong,
+// and the next attempt fails the same way. notify is called on every attempt.
+func Reconnect(ctx context.Context, backoff Backoff, connect func(ctx context.Context) error,
+	notify func(ReconnectEvent),
+) error {
+	backoff = backoff.withDefaults()
+
+	for attempt := 1; ; attempt++ {
+		err := ctx.Err()
+		if err == nil {
+			err = connect(ctx)
+		}
+		if err == nil {
+			notify(ReconnectEvent{Kind: EventReconnected, Attempt: attempt})
+			return nil
+		}
+
+		_, rejected := model.CodeOf(err)
+		if rejected || ctx.Err() != nil || backoff.MaxAttempts > 0 && attempt >= backoff.MaxAttempts {
+			notify(ReconnectEvent{Kind: EventReconnectFailed, Attempt: attempt, Err: err})
+			return fmt.Errorf("failed reconnect after %d attempts: %w", attempt, err)
+		}
+
+		delay := backoff.Delay(attempt)
+		notify(ReconnectEvent{Kind: EventReconnectFailed, Attempt: attempt, Delay: delay, Err: err})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("failed reconnect: %w: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"kvdb/internal/model"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDialer returns connections one by one, error when they are exhausted.
+type testDialer struct {
+	conns []*MockConn
+	dials int
+}
+
+func (d *testDialer) dial(context.Context) (net.Conn, error) {
+	d.dials++
+	if len(d.conns) == 0 {
+		return nil, errors.New("connection refused")
+	}
+	conn := d.conns[0]
+	d.conns = d.conns[1:]
+	return conn, nil
+}
+
+func newMockConn(response string) *MockConn {
+	return &MockConn{
+		ReadBuffer:  bytes.NewBufferString(response),
+		WriteBuffer: new(bytes.Buffer),
+	}
+}
+
+func brokenConn() *MockConn {
+	conn := newMockConn("")
+	conn.ReadError = io.EOF
+	return conn
+}
+
+var fastBackoff = Backoff{Initial: time.Millisecond, Max: time.Millisecond, MaxAttempts: 3}
+
+// TestSend_Reconnect tests that idempotent request is replayed over new connection.
+func TestSend_Reconnect(t *testing.T) {
+	broken := brokenConn()
+	next := newMockConn("value\n")
+	dialer := &testDialer{conns: []*MockConn{next}}
+
+	var events []EventKind
+	client := New(broken).
+		WithReconnect(dialer.dial, fastBackoff).
+		WithReconnectHook(func(event ReconnectEvent) {
+			events = append(events, event.Kind)
+		})
+
+	response, err := client.Send(context.Background(), []byte("GET key"))
+	require.NoError(t, err)
+	assert.Equal(t, "value\n", string(response))
+	assert.Equal(t, "GET key\n", next.WriteBuffer.String())
+	assert.True(t, broken.Closed)
+	assert.Equal(t, []EventKind{EventDisconnected, EventReconnected}, events)
+}
+
+// TestSend_NotReplayed tests that request which is not idempotent returns error,
+// the next request uses new connection.
+func TestSend_NotReplayed(t *testing.T) {
+	next := newMockConn("2\n")
+	dialer := &testDialer{conns: []*MockConn{next}}
+	client := New(brokenConn()).WithReconnect(dialer.dial, fastBackoff)
+
+	_, err := client.Send(context.Background(), []byte("INCR counter"))
+	require.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 0, dialer.dials)
+
+	response, err := client.Send(context.Background(), []byte("INCR counter"))
+	require.NoError(t, err)
+	assert.Equal(t, "2\n", string(response))
+	assert.Equal(t, 1, dialer.dials)
+}
+
+// TestSend_ReconnectHandshake tests that handshake runs over new connection before request.
+func TestSend_ReconnectHandshake(t *testing.T) {
+	next := newMockConn("ok\n")
+	dialer := &testDialer{conns: []*MockConn{next}}
+
+	client := New(brokenConn()).
+		WithReconnect(dialer.dial, fastBackoff).
+		WithHandshake(func(ctx context.Context, send func(context.Context, []byte) ([]byte, error)) error {
+			response, err := send(ctx, []byte("AUTH secret\n"))
+			if err != nil {
+				return err
+			}
+			// Response of request is read after response of handshake.
+			next.ReadBuffer = bytes.NewBufferString("value\n")
+			_, err = ParseResponse(response)
+			return err
+		})
+
+	response, err := client.Send(context.Background(), []byte("GET key"))
+	require.NoError(t, err)
+	assert.Equal(t, "value\n", string(response))
+	assert.Equal(t, "AUTH secret\nGET key\n", next.WriteBuffer.String())
+}
+
+// TestSend_ReconnectAttempts tests that reconnect gives up after MaxAttempts.
+func TestSend_ReconnectAttempts(t *testing.T) {
+	dialer := &testDialer{}
+
+	var failed []ReconnectEvent
+	client := New(brokenConn()).
+		WithReconnect(dialer.dial, fastBackoff).
+		WithReconnectHook(func(event ReconnectEvent) {
+			if event.Kind == EventReconnectFailed {
+				failed = append(failed, event)
+			}
+		})
+
+	_, err := client.Send(context.Background(), []byte("GET key"))
+	require.ErrorIs(t, err, io.EOF)
+	require.ErrorContains(t, err, "connection refused")
+	assert.Equal(t, 3, dialer.dials)
+
+	require.Len(t, failed, 3)
+	assert.Equal(t, 1, failed[0].Attempt)
+	assert.Positive(t, failed[0].Delay)
+	assert.Equal(t, 3, failed[2].Attempt)
+	assert.Zero(t, failed[2].Delay)
+}
+
+// TestSend_HandshakeRejected tests that rejected handshake is not retried.
+func TestSend_HandshakeRejected(t *testing.T) {
+	dialer := &testDialer{conns: []*MockConn{newMockConn(""), newMockConn("")}}
+
+	client := New(brokenConn()).
+		WithReconnect(dialer.dial, Backoff{Initial: time.Millisecond}).
+		WithHandshake(func(context.Context, func(context.Context, []byte) ([]byte, error)) error {
+			return model.WithCode(model.CodeWrongPass, errors.New("invalid password"))
+		})
+
+	_, err := client.Send(context.Background(), []byte("GET key"))
+	require.ErrorIs(t, err, model.ErrWrongPass)
+	assert.Equal(t, 1, dialer.dials)
+}
+
+// TestSend_ReconnectContextDone tests that reconnect stops when context is done.
+func TestSend_ReconnectContextDone(t *testing.T) {
+	dialer := &testDialer{}
+	client := New(brokenConn()).WithReconnect(dialer.dial, Backoff{Initial: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := client.Send(ctx, []byte("GET key"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, dialer.dials)
+}
+
+// TestSend_ClosedNotReconnected tests that closed client doesn't dial.
+func TestSend_ClosedNotReconnected(t *testing.T) {
+	dialer := &testDialer{conns: []*MockConn{newMockConn("value\n")}}
+	client := New(newMockConn("")).WithReconnect(dialer.dial, fastBackoff)
+	require.NoError(t, client.Close())
+
+	_, err := client.Send(context.Background(), []byte("GET key"))
+	require.ErrorIs(t, err, ErrNotConnected)
+	assert.Equal(t, 0, dialer.dials)
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	tests := []struct {
+		request  string
+		expected bool
+	}{
+		{request: "GET key", expected: true},
+		{request: "del key", expected: true},
+		{request: "TTL key", expected: true},
+		{request: "EXPIRE key 60", expected: false},
+		{request: "SET key 'some value'", expected: true},
+		{request: "TRACE req-1 SET key value", expected: true},
+		{request: "SET key value NX", expected: false},
+		{request: "SET key value XX", expected: false},
+		{request: "INCR key", expected: false},
+		{request: "INCRBY key 5", expected: false},
+		{request: "AUTH secret", expected: false},
+		{request: "CLIENT KILL 1", expected: false},
+		{request: "TRACE req-1 INCR key", expected: false},
+		{request: "GET 'unterminated", expected: false},
+		{request: "", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.request, func(t *testing.T) {
+			assert.Equal(t, tt.expected, RetryIdempotent([]byte(tt.request)))
+		})
+	}
+}
+
+func TestBackoff_Delay(t *testing.T) {
+	backoff := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2, Jitter: 0.1}
+
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{attempt: 1, expected: 100 * time.Millisecond},
+		{attempt: 2, expected: 200 * time.Millisecond},
+		{attempt: 4, expected: 800 * time.Millisecond},
+		{attempt: 5, expected: time.Second},
+		{attempt: 100, expected: time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 100 {
+			delay := backoff.Delay(tt.attempt)
+			assert.InDelta(t, tt.expected, delay, float64(tt.expected)*0.1, "attempt %d", tt.attempt)
+		}
+	}
+
+	// Zero backoff takes defaults.
+	assert.InDelta(t, defaultBackoffInitial, Backoff{}.Delay(1), float64(defaultBackoffInitial)*defaultBackoffJitter)
+}
@@ -0,0 +1,149 @@
+package endpoint
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	NetworkTCP  = "tcp"
+	NetworkUnix = "unix"
+
+	unixScheme = "unix://"
+	tcpScheme  = "tcp://"
+)
+
+var ErrAddressInUse = errors.New("address already in use")
+
+// Parse splits address into network and address. Addresses started with
+// unix:// are unix socket paths, everything else is tcp host:port.
+func Parse(addr string) (string, string) {
+	switch {
+	case strings.HasPrefix(addr, unixScheme):
+		return NetworkUnix, strings.TrimPrefix(addr, unixScheme)
+	case strings.HasPrefix(addr, tcpScheme):
+		return NetworkTCP, strings.TrimPrefix(addr, tcpScheme)
+	default:
+		return NetworkTCP, addr
+	}
+}
+
+// Listen listens on tcp or unix socket address. Stale socket file left after
+// crash is removed, socket file permissions are set to perm if not zero.
+func Listen(addr string, perm os.FileMode) (net.Listener, error) {
+	network, address := Parse(addr)
+	if network != NetworkUnix {
+		return net.Listen(network, address)
+	}
+
+	if err := removeStaleSocket(address); err != nil {
+		return nil, err
+	}
+
+	if perm == 0 {
+		return net.Listen(network, address)
+	}
+	return listenUnixPerm(address, perm)
+}
+
+// listenUnixPerm listens on unix socket which is never accessible with other
+// permissions than perm. Socket is created in private directory, which only the
+// process can enter, gets perm there and is linked to path after that. Unlike
+// rename, link fails when path exists, so socket of another process is not replaced.
+func listenUnixPerm(path string, perm os.FileMode) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".kvdb-socket-")
+	if err != nil {
+		return nil, fmt.Errorf("failed create socket directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, "socket")
+	listener, err := net.Listen(NetworkUnix, tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	unixListener := listener.(*net.UnixListener)
+	// Socket file is moved, unixSocketListener removes it at the new path.
+	unixListener.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed set socket permissions: %w", err)
+	}
+
+	if err := os.Link(tmpPath, path); err != nil {
+		listener.Close()
+		if errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("%w: %s", ErrAddressInUse, path)
+		}
+		return nil, fmt.Errorf("failed link socket: %w", err)
+	}
+
+	return &unixSocketListener{UnixListener: unixListener, path: path}, nil
+}
+
+// unixSocketListener removes socket file at path when it is closed.
+type unixSocketListener struct {
+	*net.UnixListener
+	path string
+	once sync.Once
+}
+
+func (l *unixSocketListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: NetworkUnix}
+}
+
+func (l *unixSocketListener) Close() error {
+	err := l.UnixListener.Close()
+	l.once.Do(func() {
+		_ = os.Remove(l.path)
+	})
+	return err
+}
+
+// Dial connects to tcp or unix socket address, using tls if config is set.
+func Dial(ctx context.Context, addr string, tlsConfig *tls.Config) (net.Conn, error) {
+	network, address := Parse(addr)
+
+	if tlsConfig != nil {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	dialer := &net.Dialer{}
+	return dialer.DialContext(ctx, network, address)
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%w: %s is not a socket", ErrAddressInUse, path)
+	}
+
+	conn, err := net.Dial(NetworkUnix, path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%w: %s", ErrAddressInUse, path)
+	}
+
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,123 @@
+package endpoint
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		addr            string
+		expectedNetwork string
+		expectedAddress string
+	}{
+		{addr: "127.0.0.1:8080", expectedNetwork: NetworkTCP, expectedAddress: "127.0.0.1:8080"},
+		{addr: "tcp://127.0.0.1:8080", expectedNetwork: NetworkTCP, expectedAddress: "127.0.0.1:8080"},
+		{addr: "unix:///var/run/kvdb.sock", expectedNetwork: NetworkUnix, expectedAddress: "/var/run/kvdb.sock"},
+		{addr: "unix://kvdb.sock", expectedNetwork: NetworkUnix, expectedAddress: "kvdb.sock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			network, address := Parse(tt.addr)
+			assert.Equal(t, tt.expectedNetwork, network)
+			assert.Equal(t, tt.expectedAddress, address)
+		})
+	}
+}
+
+// TestListen_Unix tests listening and dialing unix socket.
+func TestListen_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kvdb.sock")
+	addr := "unix://" + path
+
+	listener, err := Listen(addr, 0600)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Socket is busy while listener is alive.
+	_, err = Listen(addr, 0)
+	require.ErrorIs(t, err, ErrAddressInUse)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("ok"))
+			conn.Close()
+		}
+	}()
+
+	conn, err := Dial(context.Background(), addr, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	buf := make([]byte, 2)
+	_, err = conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(buf))
+}
+
+// TestListen_UnixPerm tests that socket with permissions is created in place of
+// address without leftovers and removed on close.
+func TestListen_UnixPerm(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kvdb.sock")
+
+	listener, err := Listen("unix://"+path, 0660)
+	require.NoError(t, err)
+	assert.Equal(t, path, listener.Addr().String())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "private directory is removed")
+	assert.Equal(t, "kvdb.sock", entries[0].Name())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+
+	conn, err := Dial(context.Background(), "unix://"+path, nil)
+	require.NoError(t, err)
+	conn.Close()
+
+	require.NoError(t, listener.Close())
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// TestListen_StaleSocket tests removing of socket file left by dead process.
+func TestListen_StaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kvdb.sock")
+
+	stale, err := net.Listen(NetworkUnix, path)
+	require.NoError(t, err)
+	// Keep file on close to simulate crash.
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	listener, err := Listen("unix://"+path, 0)
+	require.NoError(t, err)
+	require.NoError(t, listener.Close())
+}
+
+// TestListen_NotSocket tests refusing to remove regular file.
+func TestListen_NotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kvdb.sock")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0600))
+
+	_, err := Listen("unix://"+path, 0)
+	require.ErrorIs(t, err, ErrAddressInUse)
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"cmp"
+	"kvdb/internal/session"
+	"slices"
+	"sync"
+)
+
+// Clients is a registry of connected clients. It may be shared by servers
+// to manage clients of every listener together.
+type Clients struct {
+	mu       sync.RWMutex
+	sessions map[uint64]*session.Session
+}
+
+func NewClients() *Clients {
+	return &Clients{
+		sessions: make(map[uint64]*session.Session),
+	}
+}
+
+// List returns sessions of connected clients ordered by ID.
+func (c *Clients) List() []*session.Session {
+	c.mu.RLock()
+	sessions := make([]*session.Session, 0, len(c.sessions))
+	for _, sess := range c.sessions {
+		sessions = append(sessions, sess)
+	}
+	c.mu.RUnlock()
+
+	slices.SortFunc(sessions, func(a, b *session.Session) int {
+		return cmp.Compare(a.ID(), b.ID())
+	})
+
+	return sessions
+}
+
+// Kill disconnects client with ID. It returns false when there is no such client.
+func (c *Clients) Kill(id uint64) bool {
+	c.mu.RLock()
+	sess, ok := c.sessions[id]
+	c.mu.RUnlock()
+
+	return ok && sess.Kill()
+}
+
+// KillAddr disconnects clients with remote address. It returns false when
+// there is no such client.
+func (c *Clients) KillAddr(addr string) bool {
+	killed := false
+	for _, sess := range c.List() {
+		if sess.RemoteAddr() == addr && sess.Kill() {
+			killed = true
+		}
+	}
+
+	return killed
+}
+
+func (c *Clients) add(sess *session.Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sessions[sess.ID()] = sess
+}
+
+func (c *Clients) remove(sess *session.Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.sessions, sess.ID())
+}
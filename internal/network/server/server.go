@@ -2,8 +2,14 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"kvdb/internal/metrics"
+	"kvdb/internal/session"
+	"kvdb/internal/trace"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -15,11 +21,41 @@ const (
 	defaultIdleTimeout         = time.Minute
 )
 
+var ErrTLSDisabled = errors.New("tls is disabled")
+
 type TCPServer struct {
-	logger   *zap.Logger
-	listener net.Listener
-	handler  handleFunc
-	opts     opts
+	logger      *zap.Logger
+	listener    net.Listener
+	handler     handleFunc
+	connLimiter *connectionLimiter
+	clients     *Clients
+	tlsConfig   atomic.Pointer[tls.Config]
+	stats       serverStats
+	metrics     *serverMetrics
+	optsMu      sync.RWMutex // Guards options changed at runtime.
+	opts        opts
+}
+
+// Stats describes connections of server.
+type Stats struct {
+	Connections    int    // Number of open connections.
+	MaxConnections int    // Max number of connections, zero means unlimited.
+	Accepted       uint64 // Number of accepted connections.
+	Rejected       uint64 // Number of connections closed before serving.
+}
+
+type serverStats struct {
+	active   atomic.Int64
+	accepted atomic.Uint64
+	rejected atomic.Uint64
+}
+
+type serverMetrics struct {
+	active   *metrics.Gauge
+	accepted *metrics.Counter
+	rejected *metrics.Counter
+	bytesIn  *metrics.Counter
+	bytesOut *metrics.Counter
 }
 
 type opts struct {
@@ -43,12 +79,15 @@ func New(logger *zap.Logger, listener net.Listener) *TCPServer {
 			maxMessageSizeBytes: defaultMaxMessageSizeBytes,
 			idleTimeout:         defaultIdleTimeout,
 		},
-		handler: dummyHandleFunc,
+		handler:     dummyHandleFunc,
+		connLimiter: newConnectionLimiter(defaultMaxConn),
+		clients:     NewClients(),
 	}
 }
 
 func (s *TCPServer) WithMaxConn(maxConn int) *TCPServer {
 	s.opts.maxConn = maxConn
+	s.connLimiter = newConnectionLimiter(maxConn)
 	return s
 }
 
@@ -62,11 +101,108 @@ func (s *TCPServer) WithIdleTimeout(idleTimeout time.Duration) *TCPServer {
 	return s
 }
 
+// WithTLSConfig serves connections over TLS. Config may be replaced with SetTLSConfig.
+func (s *TCPServer) WithTLSConfig(config *tls.Config) *TCPServer {
+	s.tlsConfig.Store(config)
+	s.listener = tls.NewListener(s.listener, &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return s.tlsConfig.Load(), nil
+		},
+	})
+	return s
+}
+
+// SetTLSConfig replaces TLS config for new connections, e.g. with renewed
+// certificates. It fails when server was created without TLS.
+func (s *TCPServer) SetTLSConfig(config *tls.Config) error {
+	if s.tlsConfig.Load() == nil {
+		return ErrTLSDisabled
+	}
+
+	s.tlsConfig.Store(config)
+	return nil
+}
+
+// SetMaxConn changes max number of connections at runtime. Open connections
+// aren't closed when limit is lowered.
+func (s *TCPServer) SetMaxConn(maxConn int) {
+	s.optsMu.Lock()
+	defer s.optsMu.Unlock()
+
+	s.opts.maxConn = maxConn
+	s.connLimiter.SetMaxConn(maxConn)
+}
+
+// SetIdleTimeout changes idle timeout of new connections at runtime.
+func (s *TCPServer) SetIdleTimeout(idleTimeout time.Duration) {
+	s.optsMu.Lock()
+	defer s.optsMu.Unlock()
+
+	s.opts.idleTimeout = idleTimeout
+}
+
+func (s *TCPServer) idleTimeout() time.Duration {
+	s.optsMu.RLock()
+	defer s.optsMu.RUnlock()
+
+	return s.opts.idleTimeout
+}
+
 func (s *TCPServer) WithQueryHandleFunc(handler handleFunc) *TCPServer {
 	s.handler = handler
 	return s
 }
 
+// WithClients registers connected clients in shared registry.
+func (s *TCPServer) WithClients(clients *Clients) *TCPServer {
+	s.clients = clients
+	return s
+}
+
+// WithMetrics collects connection and traffic metrics labeled with listener name.
+func (s *TCPServer) WithMetrics(registry *metrics.Registry, listener string) *TCPServer {
+	s.metrics = &serverMetrics{
+		active: registry.Gauge(
+			"kvdb_connections_active", "Number of open client connections.", "listener",
+		).With(listener),
+		accepted: registry.Counter(
+			"kvdb_connections_accepted_total", "Number of accepted client connections.", "listener",
+		).With(listener),
+		rejected: registry.Counter(
+			"kvdb_connections_rejected_total", "Number of connections closed before serving, e.g. on failed TLS handshake.", "listener",
+		).With(listener),
+		bytesIn: registry.Counter(
+			"kvdb_network_received_bytes_total", "Number of bytes received from clients.", "listener",
+		).With(listener),
+		bytesOut: registry.Counter(
+			"kvdb_network_sent_bytes_total", "Number of bytes sent to clients.", "listener",
+		).With(listener),
+	}
+	return s
+}
+
+func (s *TCPServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Clients returns registry of connected clients.
+func (s *TCPServer) Clients() *Clients {
+	return s.clients
+}
+
+func (s *TCPServer) Stats() Stats {
+	s.optsMu.RLock()
+	maxConn := s.opts.maxConn
+	s.optsMu.RUnlock()
+
+	return Stats{
+		Connections:    int(s.stats.active.Load()),
+		MaxConnections: maxConn,
+		Accepted:       s.stats.accepted.Load(),
+		Rejected:       s.stats.rejected.Load(),
+	}
+}
+
 func (s *TCPServer) Listen(ctx context.Context) {
 	wg := sync.WaitGroup{}
 	wg.Add(1)
@@ -91,14 +227,15 @@ func (s *TCPServer) Listen(ctx context.Context) {
 func (s *TCPServer) listenLoop(ctx context.Context) {
 	s.logger.Info(
 		"start serve",
+		zap.String("network", s.listener.Addr().Network()),
 		zap.String("addr", s.listener.Addr().String()),
 		zap.Int("max_conn", s.opts.maxConn),
 		zap.Uint64("max_message_size_bytes", s.opts.maxMessageSizeBytes),
-		zap.String("idle_timeout", s.opts.idleTimeout.String()),
+		zap.String("idle_timeout", s.idleTimeout().String()),
 	)
 
 	// Limit max concurrent connections.
-	connLimiter := newConnectionLimiter(s.opts.maxConn)
+	connLimiter := s.connLimiter
 
 	wg := sync.WaitGroup{}
 
@@ -121,11 +258,16 @@ func (s *TCPServer) listenLoop(ctx context.Context) {
 			)
 			continue
 		}
+		s.stats.accepted.Add(1)
+		if s.metrics != nil {
+			s.metrics.accepted.Inc()
+		}
 
 		if err := conn.SetReadDeadline(
-			time.Now().Add(s.opts.idleTimeout),
+			time.Now().Add(s.idleTimeout()),
 		); err != nil {
 			connLimiter.Release()
+			s.reject(conn)
 			s.logger.Error(
 				"failed set idle timeout",
 				zap.String("addr", s.listener.Addr().String()),
@@ -157,34 +299,115 @@ func (s *TCPServer) wrapConn(ctx context.Context, conn net.Conn, connLimiter *co
 		connLimiter.Release()
 	}()
 
-	s.handler(ctx, conn)
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		// Handshake explicitly to reject bad clients before handler and to expose peer identity.
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			s.logger.Warn(
+				"failed tls handshake",
+				zap.String("remote_addr", conn.RemoteAddr().String()),
+				zap.Error(err),
+			)
+			s.reject(conn)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sess := session.New(conn)
+	sess.SetKillFunc(func() {
+		cancel()
+		conn.Close()
+	})
+	ctx = session.NewContext(ctx, sess)
+	// Session ID is also connection ID in logs, so CLIENT LIST output matches them.
+	ctx = trace.NewContext(ctx, trace.IDs{ConnID: sess.ID()})
+
+	s.clients.add(sess)
+	defer s.clients.remove(sess)
+
+	s.stats.active.Add(1)
+	defer s.stats.active.Add(-1)
+
+	if s.metrics != nil {
+		s.metrics.active.Inc()
+		defer s.metrics.active.Dec()
+	}
+
+	s.handler(ctx, &countingConn{Conn: conn, session: sess, metrics: s.metrics})
 }
 
-type connectionLimiter struct {
-	maxConn   int
-	maxConnCh chan struct{}
+func (s *TCPServer) reject(conn net.Conn) {
+	conn.Close()
+	s.stats.rejected.Add(1)
+	if s.metrics != nil {
+		s.metrics.rejected.Inc()
+	}
 }
 
-func newConnectionLimiter(maxConn int) *connectionLimiter {
-	var maxConnCh chan struct{}
-	if maxConn > 0 {
-		maxConnCh = make(chan struct{}, maxConn)
+// countingConn counts traffic of connection.
+type countingConn struct {
+	net.Conn
+	session *session.Session
+	metrics *serverMetrics
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.session.AddBytesIn(uint64(n))
+	if c.metrics != nil {
+		c.metrics.bytesIn.Add(uint64(n))
 	}
+	return n, err
+}
 
-	return &connectionLimiter{
-		maxConn:   maxConn,
-		maxConnCh: maxConnCh,
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.session.AddBytesOut(uint64(n))
+	if c.metrics != nil {
+		c.metrics.bytesOut.Add(uint64(n))
 	}
+	return n, err
+}
+
+// connectionLimiter limits number of concurrent connections, limit may be
+// changed at runtime.
+type connectionLimiter struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	maxConn int // Non-positive means unlimited.
+	active  int
+}
+
+func newConnectionLimiter(maxConn int) *connectionLimiter {
+	cl := &connectionLimiter{maxConn: maxConn}
+	cl.cond = sync.NewCond(&cl.mu)
+	return cl
 }
 
 func (cl *connectionLimiter) Release() {
-	if cl.maxConnCh != nil {
-		<-cl.maxConnCh
-	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.active--
+	cl.cond.Broadcast()
 }
 
 func (cl *connectionLimiter) Acquire() {
-	if cl.maxConnCh != nil {
-		cl.maxConnCh <- struct{}{}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	for cl.maxConn > 0 && cl.active >= cl.maxConn {
+		cl.cond.Wait()
 	}
+	cl.active++
+}
+
+func (cl *connectionLimiter) SetMaxConn(maxConn int) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.maxConn = maxConn
+	cl.cond.Broadcast()
 }
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"kvdb/internal/metrics"
 	"net"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -269,3 +272,216 @@ func TestListenLoop_WithPanic(t *testing.T) {
 		t.Error("Expected listener to be closed")
 	}
 }
+
+// TestListen_UnixSocket tests serving connections from unix socket listener.
+func TestListen_UnixSocket(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	path := filepath.Join(t.TempDir(), "kvdb.sock")
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to listen unix socket: %v", err)
+	}
+
+	server := New(logger, listener).WithQueryHandleFunc(
+		func(_ context.Context, conn net.Conn) {
+			defer conn.Close()
+
+			_, _ = conn.Write([]byte("ok"))
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		server.Listen(ctx)
+	}()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to dial unix socket: %v", err)
+	}
+	defer conn.Close()
+
+	response := make([]byte, 2)
+	if _, err := conn.Read(response); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if string(response) != "ok" {
+		t.Errorf("Expected response ok, got %s", response)
+	}
+
+	cancel()
+	wg.Wait()
+}
+
+func TestListen_Metrics(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	registry := metrics.NewRegistry()
+	handled := make(chan struct{})
+	server := New(logger, listener).
+		WithMetrics(registry, "test").
+		WithQueryHandleFunc(func(_ context.Context, conn net.Conn) {
+			defer close(handled)
+			defer conn.Close()
+
+			request := make([]byte, 4)
+			_, _ = conn.Read(request)
+			_, _ = conn.Write([]byte("ok"))
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		server.Listen(ctx)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+	<-handled
+
+	cancel()
+	wg.Wait()
+
+	var buf bytes.Buffer
+	if _, err := registry.WriteTo(&buf); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+
+	for _, want := range []string{
+		`kvdb_connections_accepted_total{listener="test"} 1`,
+		`kvdb_connections_active{listener="test"} 0`,
+		`kvdb_network_received_bytes_total{listener="test"} 4`,
+		`kvdb_network_sent_bytes_total{listener="test"} 2`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+
+	stats := server.Stats()
+	if stats.Accepted != 1 || stats.Connections != 0 || stats.MaxConnections != defaultMaxConn {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestListen_Clients(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	clients := NewClients()
+	handled := make(chan struct{})
+	server := New(logger, listener).
+		WithClients(clients).
+		WithQueryHandleFunc(func(ctx context.Context, conn net.Conn) {
+			defer close(handled)
+			defer conn.Close()
+
+			_, _ = conn.Read(make([]byte, 4))
+			_, _ = conn.Write([]byte("ok"))
+
+			// Blocks until client is killed.
+			_, _ = conn.Read(make([]byte, 1))
+			if ctx.Err() == nil {
+				t.Errorf("Expected context of killed client to be canceled")
+			}
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		server.Listen(ctx)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+	if _, err := conn.Read(make([]byte, 2)); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	sessions := clients.List()
+	if len(sessions) != 1 || sessions[0].RemoteAddr() != conn.LocalAddr().String() {
+		t.Fatalf("Unexpected clients %v", sessions)
+	}
+	if in, out := sessions[0].Bytes(); in != 4 || out != 2 {
+		t.Errorf("Unexpected traffic of client: in %d, out %d", in, out)
+	}
+
+	if clients.Kill(sessions[0].ID() + 1) {
+		t.Errorf("Expected unknown client not to be killed")
+	}
+	if !clients.KillAddr(conn.LocalAddr().String()) {
+		t.Errorf("Expected client to be killed")
+	}
+	<-handled
+
+	cancel()
+	wg.Wait()
+
+	if len(clients.List()) != 0 {
+		t.Errorf("Expected no clients after disconnect, got %d", len(clients.List()))
+	}
+}
+
+// TestConnectionLimiter_SetMaxConn tests changing limit while connections are waiting.
+func TestConnectionLimiter_SetMaxConn(t *testing.T) {
+	limiter := newConnectionLimiter(1)
+	limiter.Acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		limiter.Acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected connection to wait for limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	limiter.SetMaxConn(2)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected connection to be acquired after limit is raised")
+	}
+
+	// Zero means unlimited.
+	limiter.SetMaxConn(0)
+	limiter.Acquire()
+	limiter.Release()
+}
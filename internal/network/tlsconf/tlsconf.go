@@ -0,0 +1,137 @@
+package tlsconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported tls version")
+	ErrInvalidCA          = errors.New("invalid ca certificate")
+	ErrMissingKeyPair     = errors.New("certificate and key must be set together")
+)
+
+var versionsMap = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+type Options struct {
+	CertFile          string // PEM encoded certificate.
+	KeyFile           string // PEM encoded private key.
+	CAFile            string // PEM encoded CA bundle used to verify the peer.
+	MinVersion        string // Minimal protocol version: 1.0, 1.1, 1.2 or 1.3. Default 1.2.
+	RequireClientCert bool   // Server only. Reject clients without a verified certificate.
+	ServerName        string // Client only. Expected server name, default is taken from address.
+}
+
+// ServerConfig builds tls config for listener. When CA is set client certificates
+// are verified against it, and required if RequireClientCert is set.
+func ServerConfig(opts Options) (*tls.Config, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, ErrMissingKeyPair
+	}
+
+	minVersion, err := ParseVersion(opts.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed load key pair: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+		ClientAuth:   tls.NoClientCert,
+	}
+
+	if opts.CAFile != "" {
+		pool, err := loadCertPool(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	if opts.RequireClientCert {
+		if config.ClientCAs == nil {
+			return nil, fmt.Errorf("%w: client certificate verification requires ca file", ErrInvalidCA)
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// ClientConfig builds tls config for dialer. Certificate and key are optional
+// and only needed when server requires client certificates.
+func ClientConfig(opts Options) (*tls.Config, error) {
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, ErrMissingKeyPair
+	}
+
+	minVersion, err := ParseVersion(opts.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion: minVersion,
+		ServerName: opts.ServerName,
+	}
+
+	if opts.CAFile != "" {
+		pool, err := loadCertPool(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	if opts.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed load key pair: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+func ParseVersion(version string) (uint16, error) {
+	if version == "" {
+		return tls.VersionTLS12, nil
+	}
+
+	v, ok := versionsMap[version]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+	}
+
+	return v, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed read ca file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCA, caFile)
+	}
+
+	return pool, nil
+}
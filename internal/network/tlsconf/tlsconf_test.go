@@ -0,0 +1,313 @@
+package tlsconf
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"kvdb/internal/network/server"
+	"kvdb/internal/session"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// testPKI holds paths of generated CA, server and client certificates.
+type testPKI struct {
+	caFile         string
+	serverCertFile string
+	serverKeyFile  string
+	clientCertFile string
+	clientKeyFile  string
+}
+
+// newTestPKI generates in-memory CA, server and client certificates and stores them in temp dir.
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kvdb test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	pki := testPKI{
+		caFile: writePEM(t, dir, "ca.pem", "CERTIFICATE", caDER),
+	}
+
+	issue := func(name string, serial int64, usage x509.ExtKeyUsage) (string, string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		require.NoError(t, err)
+
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+
+		return writePEM(t, dir, name+".pem", "CERTIFICATE", der),
+			writePEM(t, dir, name+".key", "EC PRIVATE KEY", keyDER)
+	}
+
+	pki.serverCertFile, pki.serverKeyFile = issue("server", 2, x509.ExtKeyUsageServerAuth)
+	pki.clientCertFile, pki.clientKeyFile = issue("service-a", 3, x509.ExtKeyUsageClientAuth)
+
+	return pki
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	return path
+}
+
+// TestParseVersion tests mapping of config versions.
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), v)
+
+	v, err = ParseVersion("1.3")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), v)
+
+	_, err = ParseVersion("2.0")
+	require.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+// TestServerConfig tests building server config from files.
+func TestServerConfig(t *testing.T) {
+	pki := newTestPKI(t)
+
+	config, err := ServerConfig(Options{
+		CertFile:   pki.serverCertFile,
+		KeyFile:    pki.serverKeyFile,
+		MinVersion: "1.3",
+	})
+	require.NoError(t, err)
+	assert.Len(t, config.Certificates, 1)
+	assert.Equal(t, tls.NoClientCert, config.ClientAuth)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+
+	config, err = ServerConfig(Options{
+		CertFile:          pki.serverCertFile,
+		KeyFile:           pki.serverKeyFile,
+		CAFile:            pki.caFile,
+		RequireClientCert: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+	assert.NotNil(t, config.ClientCAs)
+}
+
+// TestServerConfig_Errors tests handling of invalid options.
+func TestServerConfig_Errors(t *testing.T) {
+	pki := newTestPKI(t)
+
+	_, err := ServerConfig(Options{CertFile: pki.serverCertFile})
+	require.ErrorIs(t, err, ErrMissingKeyPair)
+
+	_, err = ServerConfig(Options{
+		CertFile:          pki.serverCertFile,
+		KeyFile:           pki.serverKeyFile,
+		RequireClientCert: true,
+	})
+	require.ErrorIs(t, err, ErrInvalidCA)
+
+	_, err = ServerConfig(Options{
+		CertFile: pki.serverCertFile,
+		KeyFile:  pki.serverKeyFile,
+		CAFile:   pki.serverKeyFile,
+	})
+	require.ErrorIs(t, err, ErrInvalidCA)
+
+	_, err = ServerConfig(Options{
+		CertFile: pki.serverCertFile,
+		KeyFile:  filepath.Join(t.TempDir(), "missing.key"),
+	})
+	require.Error(t, err)
+}
+
+// TestClientConfig_Errors tests handling of invalid client options.
+func TestClientConfig_Errors(t *testing.T) {
+	_, err := ClientConfig(Options{CertFile: "client.pem"})
+	require.ErrorIs(t, err, ErrMissingKeyPair)
+
+	_, err = ClientConfig(Options{MinVersion: "0.9"})
+	require.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+// TestMutualTLS tests that server verifies client certificate and exposes identity to the handler.
+func TestMutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+
+	serverConfig, err := ServerConfig(Options{
+		CertFile:          pki.serverCertFile,
+		KeyFile:           pki.serverKeyFile,
+		CAFile:            pki.caFile,
+		RequireClientCert: true,
+	})
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	identities := make(chan string, 1)
+	tcpServer := server.New(zaptest.NewLogger(t), tls.NewListener(listener, serverConfig)).
+		WithQueryHandleFunc(func(ctx context.Context, conn net.Conn) {
+			defer conn.Close()
+
+			sess, ok := session.FromContext(ctx)
+			if ok {
+				identities <- sess.Identity()
+			}
+			_, _ = conn.Write([]byte("ok\n"))
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tcpServer.Listen(ctx)
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	// Client without certificate is rejected.
+	anonymousConfig, err := ClientConfig(Options{CAFile: pki.caFile})
+	require.NoError(t, err)
+	anonymousConn, err := tls.Dial("tcp", listener.Addr().String(), anonymousConfig)
+	if err == nil {
+		_, err = bufio.NewReader(anonymousConn).ReadString('\n')
+		anonymousConn.Close()
+	}
+	require.Error(t, err)
+
+	// Client with certificate signed by CA is accepted.
+	clientConfig, err := ClientConfig(Options{
+		CertFile: pki.clientCertFile,
+		KeyFile:  pki.clientKeyFile,
+		CAFile:   pki.caFile,
+	})
+	require.NoError(t, err)
+	conn, err := tls.Dial("tcp", listener.Addr().String(), clientConfig)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	response, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "ok\n", response)
+	assert.Equal(t, "service-a", <-identities)
+}
+
+func TestSetTLSConfig(t *testing.T) {
+	pki := newTestPKI(t)
+
+	serverConfig, err := ServerConfig(Options{
+		CertFile: pki.serverCertFile,
+		KeyFile:  pki.serverKeyFile,
+		CAFile:   pki.caFile,
+	})
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	tcpServer := server.New(zaptest.NewLogger(t), listener).
+		WithTLSConfig(serverConfig).
+		WithQueryHandleFunc(func(_ context.Context, conn net.Conn) {
+			defer conn.Close()
+			_, _ = conn.Write([]byte("ok\n"))
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tcpServer.Listen(ctx)
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	anonymousConfig, err := ClientConfig(Options{CAFile: pki.caFile})
+	require.NoError(t, err)
+	dial := func() error {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), anonymousConfig)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		_, err = bufio.NewReader(conn).ReadString('\n')
+		return err
+	}
+
+	// Client without certificate is accepted until config requires it.
+	require.NoError(t, dial())
+
+	reloadedConfig, err := ServerConfig(Options{
+		CertFile:          pki.serverCertFile,
+		KeyFile:           pki.serverKeyFile,
+		CAFile:            pki.caFile,
+		RequireClientCert: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, tcpServer.SetTLSConfig(reloadedConfig))
+
+	require.Error(t, dial())
+}
+
+func TestSetTLSConfig_Disabled(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	tcpServer := server.New(zaptest.NewLogger(t), listener)
+	assert.ErrorIs(t, tcpServer.SetTLSConfig(&tls.Config{}), server.ErrTLSDisabled)
+}
@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Listener is a net.Listener of websocket connections upgraded by its
+// ServeHTTP, so they can be served by the same server as TCP connections.
+type Listener struct {
+	addr           net.Addr
+	maxMessageSize uint64
+	allowedOrigins []string
+	conns          chan net.Conn
+	done           chan struct{}
+	closeOnce      sync.Once
+}
+
+// NewListener creates listener reporting addr as its address. Zero
+// maxMessageSize means unlimited.
+func NewListener(addr net.Addr, maxMessageSize uint64) *Listener {
+	return &Listener{
+		addr:           addr,
+		maxMessageSize: maxMessageSize,
+		conns:          make(chan net.Conn),
+		done:           make(chan struct{}),
+	}
+}
+
+// WithAllowedOrigins sets origins of pages of other hosts allowed to connect, see CheckOrigin.
+func (l *Listener) WithAllowedOrigins(origins []string) *Listener {
+	l.allowedOrigins = origins
+	return l
+}
+
+// ServeHTTP upgrades request and waits until connection is accepted.
+func (l *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-l.done:
+		http.Error(w, "websocket listener is closed", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+
+	if err := CheckOrigin(r, l.allowedOrigins); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	conn, err := Upgrade(w, r, l.maxMessageSize)
+	if err != nil {
+		return
+	}
+
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	case <-r.Context().Done():
+		conn.Close()
+	}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.addr
+}
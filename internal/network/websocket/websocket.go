@@ -0,0 +1,367 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// magicGUID is appended to client key to compute accept key, see RFC 6455.
+const magicGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+const (
+	closeNormal          = 1000
+	closeProtocolError   = 1002
+	closeMessageTooLarge = 1009
+)
+
+const maxControlPayload = 125
+
+// maxFramePayload caps payload of a data frame even when message size is
+// unlimited, payload is allocated before it is read.
+const maxFramePayload = 64 * 1024 * 1024
+
+var (
+	ErrBadHandshake     = errors.New("bad websocket handshake")
+	ErrProtocol         = errors.New("websocket protocol error")
+	ErrMessageTooLarge  = errors.New("websocket message too large")
+	ErrOriginNotAllowed = errors.New("websocket origin not allowed")
+)
+
+// Conn is a websocket connection which looks like a stream to the query
+// handler. Every received message is terminated with newline, every write
+// is sent as a single message.
+type Conn struct {
+	conn           net.Conn
+	reader         *bufio.Reader
+	maxMessageSize uint64
+
+	pending []byte // Rest of the current message not yet read.
+
+	writeMu sync.Mutex
+	closed  bool // Guarded by writeMu.
+}
+
+// Upgrade performs server side handshake and takes over connection of the request.
+// Zero maxMessageSize means unlimited, but every frame is still limited to 64MiB.
+func Upgrade(w http.ResponseWriter, r *http.Request, maxMessageSize uint64) (*Conn, error) {
+	key, err := checkHandshake(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "websocket is not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("failed hijack connection: %w", err)
+	}
+
+	// Deadlines set by HTTP server must not apply to long lived connection.
+	if err := netConn.SetDeadline(time.Time{}); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := netConn.Write([]byte(response)); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return &Conn{
+		conn:           netConn,
+		reader:         rw.Reader,
+		maxMessageSize: maxMessageSize,
+	}, nil
+}
+
+func checkHandshake(r *http.Request) (string, error) {
+	if r.Method != http.MethodGet {
+		return "", fmt.Errorf("%w: want GET method", ErrBadHandshake)
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return "", fmt.Errorf("%w: want upgrade to websocket", ErrBadHandshake)
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return "", fmt.Errorf("%w: want version 13", ErrBadHandshake)
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return "", fmt.Errorf("%w: invalid key", ErrBadHandshake)
+	}
+
+	return key, nil
+}
+
+// CheckOrigin allows request of page of the same host or of allowed origin, "*"
+// allows any origin. Browser sends Origin with every websocket request and lets
+// any page connect, so connections of other sites must be rejected by server.
+// Request without Origin is sent by client which is not a browser and is allowed.
+func CheckOrigin(r *http.Request, allowedOrigins []string) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrOriginNotAllowed, origin)
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + magicGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// Read returns data of received messages. Control frames are handled internally.
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		message, err := c.readMessage()
+		if err != nil {
+			return 0, err
+		}
+
+		if len(message) == 0 || message[len(message)-1] != '\n' {
+			message = append(message, '\n')
+		}
+		c.pending = message
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// readMessage reads data frames until the final one.
+func (c *Conn) readMessage() ([]byte, error) {
+	var message []byte
+	started := false
+
+	for {
+		fin, opcode, payload, err := c.readFrame(uint64(len(message)))
+		if err != nil {
+			return nil, c.fail(err)
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			_ = c.writeClose(closeNormal)
+			return nil, io.EOF
+		case opText, opBinary:
+			if started {
+				return nil, c.fail(fmt.Errorf("%w: unexpected data frame", ErrProtocol))
+			}
+			started = true
+		case opContinuation:
+			if !started {
+				return nil, c.fail(fmt.Errorf("%w: unexpected continuation frame", ErrProtocol))
+			}
+		default:
+			return nil, c.fail(fmt.Errorf("%w: unknown opcode %d", ErrProtocol, opcode))
+		}
+
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// readFrame reads single frame, read is size of message data received before.
+func (c *Conn) readFrame(read uint64) (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("%w: reserved bits are set", ErrProtocol)
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, fmt.Errorf("%w: client frames must be masked", ErrProtocol)
+	}
+
+	size := uint64(header[1] & 0x7F)
+	switch size {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, ext); err != nil {
+			return false, 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, ext); err != nil {
+			return false, 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext)
+		if size&(1<<63) != 0 {
+			return false, 0, nil, fmt.Errorf("%w: most significant bit of length is set", ErrProtocol)
+		}
+	}
+
+	isControl := opcode&0x8 != 0
+	if isControl && (!fin || size > maxControlPayload) {
+		return false, 0, nil, fmt.Errorf("%w: invalid control frame", ErrProtocol)
+	}
+	if !isControl && size > maxFramePayload {
+		return false, 0, nil, fmt.Errorf("%w: frame of %d bytes is larger than %d", ErrMessageTooLarge, size, maxFramePayload)
+	}
+	// read never exceeds the limit, so subtraction doesn't wrap unlike read+size.
+	if !isControl && c.maxMessageSize > 0 && size > c.maxMessageSize-read {
+		return false, 0, nil, fmt.Errorf("%w: more than %d bytes", ErrMessageTooLarge, c.maxMessageSize)
+	}
+
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(c.reader, mask); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// fail sends close frame matching protocol error.
+func (c *Conn) fail(err error) error {
+	switch {
+	case errors.Is(err, ErrMessageTooLarge):
+		_ = c.writeClose(closeMessageTooLarge)
+	case errors.Is(err, ErrProtocol):
+		_ = c.writeClose(closeProtocolError)
+	}
+	return err
+}
+
+// Write sends p as a single text message, or binary if p is not valid UTF-8.
+func (c *Conn) Write(p []byte) (int, error) {
+	opcode := byte(opText)
+	if !utf8.Valid(p) {
+		opcode = opBinary
+	}
+
+	if err := c.writeFrame(opcode, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *Conn) writeClose(code uint16) error {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, code)
+	return c.writeFrame(opClose, payload)
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed {
+		return net.ErrClosed
+	}
+	if opcode == opClose {
+		c.closed = true
+	}
+
+	header := make([]byte, 0, 10)
+	header = append(header, 0x80|opcode)
+
+	size := len(payload)
+	switch {
+	case size <= 125:
+		header = append(header, byte(size))
+	case size <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(size))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(size))
+	}
+
+	_, err := (&net.Buffers{header, payload}).WriteTo(c.conn)
+	return err
+}
+
+// Close sends close frame and closes underlying connection.
+func (c *Conn) Close() error {
+	_ = c.writeClose(closeNormal)
+	return c.conn.Close()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
@@ -0,0 +1,315 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+// testClient is a minimal websocket client.
+type testClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *testClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+
+	request := "GET /ws HTTP/1.1\r\n" +
+		"Host: " + addr + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + testKey + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	_, err = conn.Write([]byte(request))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	// Accept key from RFC 6455 example.
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+
+	return &testClient{conn: conn, reader: reader}
+}
+
+func (c *testClient) writeFrame(t *testing.T, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+
+	frame := []byte{first}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, 0x80|byte(len(payload)))
+	default:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := c.conn.Write(frame)
+	require.NoError(t, err)
+}
+
+func (c *testClient) readFrame(t *testing.T) (byte, string) {
+	t.Helper()
+
+	header := make([]byte, 2)
+	_, err := io.ReadFull(c.reader, header)
+	require.NoError(t, err)
+
+	size := int(header[1] & 0x7F)
+	if size == 126 {
+		ext := make([]byte, 2)
+		_, err := io.ReadFull(c.reader, ext)
+		require.NoError(t, err)
+		size = int(binary.BigEndian.Uint16(ext))
+	}
+
+	payload := make([]byte, size)
+	_, err = io.ReadFull(c.reader, payload)
+	require.NoError(t, err)
+
+	return header[0] & 0x0F, string(payload)
+}
+
+func startServer(t *testing.T, maxMessageSize uint64) (*Listener, string) {
+	t.Helper()
+
+	listener := NewListener(&net.TCPAddr{}, maxMessageSize)
+	server := httptest.NewServer(listener)
+	t.Cleanup(func() {
+		listener.Close()
+		server.Close()
+	})
+
+	return listener, strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestListener_Echo(t *testing.T) {
+	listener, addr := startServer(t, 0)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("echo " + strings.TrimSpace(line)))
+		}
+	}()
+
+	client := dial(t, addr)
+	defer client.conn.Close()
+
+	client.writeFrame(t, true, opText, []byte("GET key"))
+	opcode, payload := client.readFrame(t)
+	assert.Equal(t, byte(opText), opcode)
+	assert.Equal(t, "echo GET key", payload)
+
+	// Fragmented message with ping in the middle.
+	client.writeFrame(t, false, opText, []byte("SET key "))
+	client.writeFrame(t, true, opPing, []byte("hi"))
+	client.writeFrame(t, true, opContinuation, []byte(strings.Repeat("v", 200)))
+
+	opcode, payload = client.readFrame(t)
+	assert.Equal(t, byte(opPong), opcode)
+	assert.Equal(t, "hi", payload)
+
+	_, payload = client.readFrame(t)
+	assert.Equal(t, "echo SET key "+strings.Repeat("v", 200), payload)
+
+	client.writeFrame(t, true, opClose, nil)
+	opcode, _ = client.readFrame(t)
+	assert.Equal(t, byte(opClose), opcode)
+}
+
+func TestListener_MessageTooLarge(t *testing.T) {
+	listener, addr := startServer(t, 16)
+
+	errCh := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer conn.Close()
+
+		_, err = conn.Read(make([]byte, 64))
+		errCh <- err
+	}()
+
+	client := dial(t, addr)
+	defer client.conn.Close()
+
+	client.writeFrame(t, true, opText, []byte(strings.Repeat("k", 32)))
+
+	opcode, payload := client.readFrame(t)
+	assert.Equal(t, byte(opClose), opcode)
+	assert.Equal(t, uint16(closeMessageTooLarge), binary.BigEndian.Uint16([]byte(payload)))
+	assert.ErrorIs(t, <-errCh, ErrMessageTooLarge)
+}
+
+// TestListener_HostileLength tests that frame lengths chosen by client can't
+// wrap the size check or make the server allocate them.
+func TestListener_HostileLength(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxMessageSize uint64
+		size           uint64
+		expectedCode   uint16
+		expectedErr    error
+	}{
+		{name: "top bit set", maxMessageSize: 16, size: math.MaxUint64, expectedCode: closeProtocolError, expectedErr: ErrProtocol},
+		{name: "wraps limit", maxMessageSize: 16, size: math.MaxInt64, expectedCode: closeMessageTooLarge, expectedErr: ErrMessageTooLarge},
+		{name: "unlimited message", maxMessageSize: 0, size: math.MaxInt64, expectedCode: closeMessageTooLarge, expectedErr: ErrMessageTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, addr := startServer(t, tt.maxMessageSize)
+
+			errCh := make(chan error, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					errCh <- err
+					return
+				}
+				defer conn.Close()
+
+				_, err = conn.Read(make([]byte, 64))
+				errCh <- err
+			}()
+
+			client := dial(t, addr)
+			defer client.conn.Close()
+
+			// Non-final frame, then continuation with 64-bit length and no payload.
+			client.writeFrame(t, false, opText, []byte("k"))
+			frame := []byte{0x80 | opContinuation, 0x80 | 127}
+			frame = binary.BigEndian.AppendUint64(frame, tt.size)
+			frame = append(frame, 1, 2, 3, 4)
+			_, err := client.conn.Write(frame)
+			require.NoError(t, err)
+
+			opcode, payload := client.readFrame(t)
+			assert.Equal(t, byte(opClose), opcode)
+			assert.Equal(t, tt.expectedCode, binary.BigEndian.Uint16([]byte(payload)))
+			assert.ErrorIs(t, <-errCh, tt.expectedErr)
+		})
+	}
+}
+
+func TestUpgrade_BadHandshake(t *testing.T) {
+	_, addr := startServer(t, 0)
+
+	resp, err := http.Get("http://" + addr + "/ws")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestListener_Close(t *testing.T) {
+	listener := NewListener(&net.TCPAddr{}, 0)
+	require.NoError(t, listener.Close())
+	require.NoError(t, listener.Close())
+
+	_, err := listener.Accept()
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
+
+// handshakeStatus sends handshake with Origin header and returns status of response.
+func handshakeStatus(t *testing.T, addr, origin string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/ws", nil)
+	require.NoError(t, err)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", testKey)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestListener_Origin(t *testing.T) {
+	listener, addr := startServer(t, 0)
+	listener.WithAllowedOrigins([]string{"https://app.example.com"})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name     string
+		origin   string
+		expected int
+	}{
+		{name: "no origin", origin: "", expected: http.StatusSwitchingProtocols},
+		{name: "same host", origin: "http://" + addr, expected: http.StatusSwitchingProtocols},
+		{name: "allowed", origin: "https://APP.example.com", expected: http.StatusSwitchingProtocols},
+		{name: "other site", origin: "https://evil.example.com", expected: http.StatusForbidden},
+		{name: "null", origin: "null", expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, handshakeStatus(t, addr, tt.origin))
+		})
+	}
+}
+
+func TestCheckOrigin_Any(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://db.local/ws", nil)
+	r.Header.Set("Origin", "https://evil.example.com")
+
+	require.ErrorIs(t, CheckOrigin(r, nil), ErrOriginNotAllowed)
+	require.NoError(t, CheckOrigin(r, []string{"*"}))
+}
@@ -0,0 +1,100 @@
+package pubsub
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const defaultBufferSize = 128
+
+// Hub fans out messages to subscribers without blocking publisher. Messages
+// for subscribers which don't keep up are dropped.
+type Hub[T any] struct {
+	mu   sync.RWMutex
+	subs map[*Subscription[T]]struct{}
+}
+
+// Subscription receives messages accepted by its filter.
+type Subscription[T any] struct {
+	hub     *Hub[T]
+	filter  func(T) bool
+	ch      chan T
+	dropped atomic.Uint64
+	closed  bool // Guarded by hub mutex.
+}
+
+func New[T any]() *Hub[T] {
+	return &Hub[T]{
+		subs: make(map[*Subscription[T]]struct{}),
+	}
+}
+
+// Subscribe creates subscription with buffer of bufferSize messages,
+// non-positive size means default. Nil filter accepts every message.
+func (h *Hub[T]) Subscribe(filter func(T) bool, bufferSize int) *Subscription[T] {
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+
+	sub := &Subscription[T]{
+		hub:    h,
+		filter: filter,
+		ch:     make(chan T, bufferSize),
+	}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub
+}
+
+// Publish sends message to every matching subscriber.
+func (h *Hub[T]) Publish(msg T) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subs {
+		if sub.filter != nil && !sub.filter(msg) {
+			continue
+		}
+
+		select {
+		case sub.ch <- msg:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Len returns number of active subscriptions.
+func (h *Hub[T]) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.subs)
+}
+
+// C returns channel of messages. It is closed after Close.
+func (s *Subscription[T]) C() <-chan T {
+	return s.ch
+}
+
+// Dropped returns number of messages dropped because buffer was full.
+func (s *Subscription[T]) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close removes subscription from hub. It is safe to call Close many times.
+func (s *Subscription[T]) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	delete(s.hub.subs, s)
+	close(s.ch)
+}
@@ -0,0 +1,72 @@
+package pubsub
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_Publish(t *testing.T) {
+	hub := New[int]()
+
+	all := hub.Subscribe(nil, 10)
+	even := hub.Subscribe(func(n int) bool { return n%2 == 0 }, 10)
+	require.Equal(t, 2, hub.Len())
+
+	for n := range 4 {
+		hub.Publish(n)
+	}
+
+	all.Close()
+	even.Close()
+	require.Equal(t, 0, hub.Len())
+
+	var gotAll, gotEven []int
+	for n := range all.C() {
+		gotAll = append(gotAll, n)
+	}
+	for n := range even.C() {
+		gotEven = append(gotEven, n)
+	}
+
+	assert.Equal(t, []int{0, 1, 2, 3}, gotAll)
+	assert.Equal(t, []int{0, 2}, gotEven)
+}
+
+func TestHub_PublishDropsForSlowSubscriber(t *testing.T) {
+	hub := New[int]()
+
+	sub := hub.Subscribe(nil, 2)
+	defer sub.Close()
+
+	for n := range 5 {
+		hub.Publish(n)
+	}
+
+	assert.Equal(t, uint64(3), sub.Dropped())
+	assert.Equal(t, 0, <-sub.C())
+	assert.Equal(t, 1, <-sub.C())
+}
+
+func TestSubscription_CloseConcurrently(t *testing.T) {
+	hub := New[int]()
+	sub := hub.Subscribe(nil, 1)
+
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			hub.Publish(1)
+		}()
+		go func() {
+			defer wg.Done()
+			sub.Close()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 0, hub.Len())
+}
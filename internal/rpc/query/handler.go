@@ -4,31 +4,162 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"fmt"
+	"kvdb/internal/compute"
+	"kvdb/internal/model"
+	"kvdb/internal/pubsub"
+	"kvdb/internal/security/auth"
+	"kvdb/internal/session"
+	"kvdb/internal/trace"
 	"net"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/shlex"
 	"go.uber.org/zap"
 )
 
+const (
+	commandAUTH        = "auth"
+	commandSUBSCRIBE   = "subscribe"
+	commandUNSUBSCRIBE = "unsubscribe"
+	commandMONITOR     = "monitor"
+	commandPING        = "ping"
+	commandTRACE       = "trace"
+	messageEvent       = "event"
+	messageMonitor     = "monitor"
+	messageTrace       = "trace"
+	messageError       = "(error)"
+)
+
+var (
+	ErrAuthRequired      = model.WithCode(model.CodeNoAuth, errors.New("authentication required"))
+	ErrSubscribeDisabled = model.WithCode(model.CodeDisabled, errors.New("subscriptions are disabled on this listener"))
+	ErrMonitorDisabled   = model.WithCode(model.CodeDisabled, errors.New("monitor is disabled on this listener"))
+)
+
 type Database interface {
-	RunCommand(ctx context.Context, rawQuery string) string
+	RunCommand(ctx context.Context, rawQuery string) model.Result
+}
+
+type Authenticator interface {
+	Required() bool
+	Authenticate(remoteAddr, username, password string) error
+}
+
+// Subscriber streams key change events for SUBSCRIBE command.
+type Subscriber interface {
+	Subscribe(ctx context.Context, patterns []string) (*pubsub.Subscription[model.KeyEvent], error)
+}
+
+// Monitor streams every query received by database for MONITOR command.
+type Monitor interface {
+	Monitor(ctx context.Context) (*pubsub.Subscription[model.QueryEvent], error)
 }
 
 type Handler struct {
-	database Database
-	logger   *zap.Logger
+	database      Database
+	authenticator Authenticator
+	subscriber    Subscriber
+	monitor       Monitor
+	logger        *zap.Logger
+	opts          opts
+}
+
+type opts struct {
+	protocol          string         // Wire protocol. Default text.
+	maxMessageSize    uint64         // Max query size in bytes, zero means unlimited.
+	allowedCategories model.Category // Categories of commands clients may run. Default all.
+}
+
+// connState is a state of a single client connection.
+type connState struct {
+	session       *session.Session
+	authenticated bool
+
+	conn          net.Conn
+	codec         codec
+	writeMu       sync.Mutex // Serializes responses and subscription events.
+	subscriptions []*pubsub.Subscription[model.KeyEvent]
+	monitor       *pubsub.Subscription[model.QueryEvent]
 }
 
 func New(database Database, logger *zap.Logger) *Handler {
 	return &Handler{
 		database: database,
 		logger:   logger,
+		opts: opts{
+			protocol:          ProtocolText,
+			allowedCategories: model.CategoryAll,
+		},
 	}
 }
 
+func (h *Handler) WithProtocol(protocol string) *Handler {
+	h.opts.protocol = protocol
+	return h
+}
+
+func (h *Handler) WithMaxMessageSize(maxMessageSize uint64) *Handler {
+	h.opts.maxMessageSize = maxMessageSize
+	return h
+}
+
+// WithAllowedCategories restricts commands clients of this handler may run.
+func (h *Handler) WithAllowedCategories(categories model.Category) *Handler {
+	h.opts.allowedCategories = categories
+	return h
+}
+
+// WithSubscriber enables SUBSCRIBE and UNSUBSCRIBE commands.
+func (h *Handler) WithSubscriber(subscriber Subscriber) *Handler {
+	h.subscriber = subscriber
+	return h
+}
+
+// WithMonitor enables MONITOR command.
+func (h *Handler) WithMonitor(monitor Monitor) *Handler {
+	h.monitor = monitor
+	return h
+}
+
+// WithAuthenticator requires clients to run AUTH before any other command.
+func (h *Handler) WithAuthenticator(authenticator Authenticator) *Handler {
+	h.authenticator = authenticator
+	return h
+}
+
 func (h *Handler) Handle(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 
+	codec, err := newCodec(h.opts.protocol, h.opts.maxMessageSize)
+	if err != nil {
+		h.logger.Error("failed init codec", zap.Error(err))
+		return
+	}
+
+	sess, ok := session.FromContext(ctx)
+	if !ok {
+		sess = session.New(conn)
+		ctx = session.NewContext(ctx, sess)
+	}
+	sess.SetAllowedCategories(h.opts.allowedCategories)
+	if _, ok := trace.FromContext(ctx); !ok {
+		ctx = trace.NewContext(ctx, trace.IDs{ConnID: sess.ID()})
+	}
+	logger := trace.Logger(ctx, h.logger)
+
+	logger.Debug(
+		"client connected",
+		zap.String("remote_addr", sess.RemoteAddr()),
+		zap.String("identity", sess.Identity()),
+	)
+
+	state := &connState{session: sess, conn: conn, codec: codec}
+	defer state.unsubscribe()
+	defer state.stopMonitor()
+
 	reader := bufio.NewReader(conn)
 	for {
 		select {
@@ -37,25 +168,257 @@ func (h *Handler) Handle(ctx context.Context, conn net.Conn) {
 		default:
 		}
 
-		query, err := reader.ReadString('\n')
+		query, err := codec.ReadQuery(reader)
 		if err != nil {
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
-				h.logger.Warn("read timeout", zap.Error(err))
+				logger.Warn("read timeout", zap.Error(err))
 				return
 			}
 
-			h.logger.Error("failed read conn", zap.Error(err))
+			if errors.Is(err, ErrMessageTooLarge) || errors.Is(err, ErrInvalidMessage) {
+				// Stream can't be resynchronized, so report and drop connection.
+				logger.Warn("invalid query", zap.Error(err))
+				_ = codec.WriteResponse(conn, response{result: failed(model.CodeErr, "failed read query", err)})
+				return
+			}
+
+			logger.Error("failed read conn", zap.Error(err))
 			return
 		}
 
-		query = strings.TrimSpace(query)
-		result := h.database.RunCommand(ctx, query)
-
-		_, err = conn.Write([]byte(result))
+		err = state.write(h.processTraced(ctx, state, query))
 		if err != nil {
-			h.logger.Error("failed write conn", zap.Error(err))
+			logger.Error("failed write conn", zap.Error(err))
 			return
 		}
 	}
 }
+
+// processTraced assigns command ID and handles optional TRACE <id> prefix of
+// query. Trace ID is echoed in response.
+func (h *Handler) processTraced(ctx context.Context, state *connState, query string) response {
+	query, traceID, err := splitTrace(query)
+	if err != nil {
+		return response{result: failed(model.CodeSyntax, "failed parse query", err)}
+	}
+
+	ctx = trace.NewContext(ctx, trace.IDs{CommandID: trace.NextCommandID(), TraceID: traceID})
+	return response{result: h.process(ctx, state, query), traceID: traceID}
+}
+
+func (h *Handler) process(ctx context.Context, state *connState, query string) model.Result {
+	state.session.SetLastCommand(commandName(query))
+
+	if args, ok := parseCommand(query, commandAUTH); ok {
+		return h.auth(ctx, state, args)
+	}
+
+	if h.authRequired() && !state.authenticated {
+		return failed(model.CodeNoAuth, "failed auth", ErrAuthRequired)
+	}
+
+	if args, ok := parseCommand(query, commandPING); ok {
+		if len(args) != 0 {
+			return failed(model.CodeWrongArgs, "failed parse query", errors.New("invalid args: want 0 args"))
+		}
+		return model.OK(model.StringValue("PONG"))
+	}
+	if args, ok := parseCommand(query, commandSUBSCRIBE); ok {
+		return h.subscribe(ctx, state, args)
+	}
+	if _, ok := parseCommand(query, commandUNSUBSCRIBE); ok {
+		state.unsubscribe()
+		return model.OK(model.NoneValue())
+	}
+	if args, ok := parseCommand(query, commandMONITOR); ok {
+		return h.startMonitor(ctx, state, args)
+	}
+
+	return h.database.RunCommand(ctx, query)
+}
+
+// subscribe handles SUBSCRIBE pattern [pattern...]. Events are written to
+// connection as "event <command> <key>" messages between responses.
+func (h *Handler) subscribe(ctx context.Context, state *connState, patterns []string) model.Result {
+	if h.subscriber == nil {
+		return failed(model.CodeDisabled, "failed subscribe", ErrSubscribeDisabled)
+	}
+	if len(patterns) == 0 {
+		return failed(model.CodeWrongArgs, "failed parse query", errors.New("invalid args: want at least 1 args"))
+	}
+
+	sub, err := h.subscriber.Subscribe(ctx, patterns)
+	if err != nil {
+		return failed(model.CodeErr, "failed subscribe", err)
+	}
+	state.subscriptions = append(state.subscriptions, sub)
+
+	go func() {
+		for event := range sub.C() {
+			message := strings.Join([]string{messageEvent, event.Command.String(), compute.Quote(event.Key)}, " ")
+			if err := state.writeEvent(message); err != nil {
+				trace.Logger(ctx, h.logger).Warn("failed write event", zap.Error(err))
+				return
+			}
+		}
+	}()
+
+	return model.OK(model.NoneValue())
+}
+
+// startMonitor handles MONITOR. Every query received by database is written to
+// connection as "monitor <time> <client address> <query>" message between
+// responses. Queries are dropped when connection can't keep up with them.
+func (h *Handler) startMonitor(ctx context.Context, state *connState, args []string) model.Result {
+	if h.monitor == nil {
+		return failed(model.CodeDisabled, "failed monitor", ErrMonitorDisabled)
+	}
+	if len(args) != 0 {
+		return failed(model.CodeWrongArgs, "failed parse query", errors.New("invalid args: want 0 args"))
+	}
+	if state.monitor != nil {
+		return model.OK(model.NoneValue())
+	}
+
+	sub, err := h.monitor.Monitor(ctx)
+	if err != nil {
+		return failed(model.CodeNoPerm, "failed monitor", err)
+	}
+	state.monitor = sub
+
+	go func() {
+		for event := range sub.C() {
+			clientAddr := event.ClientAddr
+			if clientAddr == "" {
+				clientAddr = "-"
+			}
+
+			message := strings.Join([]string{
+				messageMonitor,
+				event.Time.UTC().Format(time.RFC3339Nano),
+				clientAddr,
+				event.Query,
+			}, " ")
+			if err := state.writeEvent(message); err != nil {
+				trace.Logger(ctx, h.logger).Warn("failed write monitor event", zap.Error(err))
+				return
+			}
+		}
+	}()
+
+	return model.OK(model.NoneValue())
+}
+
+// auth handles AUTH [username] password.
+func (h *Handler) auth(ctx context.Context, state *connState, args []string) model.Result {
+	var username, password string
+	switch len(args) {
+	case 1:
+		password = args[0]
+	case 2:
+		username, password = args[0], args[1]
+	default:
+		return failed(model.CodeWrongArgs, "failed parse query", errors.New("invalid args: want 1 to 2 args"))
+	}
+
+	if h.authenticator == nil {
+		return failed(model.CodeDisabled, "failed auth", auth.ErrPasswordNotSet)
+	}
+
+	remoteAddr := state.session.RemoteAddr()
+	if err := h.authenticator.Authenticate(remoteAddr, username, password); err != nil {
+		trace.Logger(ctx, h.logger).Warn(
+			"failed auth",
+			zap.String("remote_addr", remoteAddr),
+			zap.String("user", username),
+			zap.Error(err),
+		)
+		return failed(model.CodeWrongPass, "failed auth", err)
+	}
+
+	state.authenticated = true
+	state.session.SetUser(username)
+	return model.OK(model.NoneValue())
+}
+
+func (h *Handler) authRequired() bool {
+	return h.authenticator != nil && h.authenticator.Required()
+}
+
+func (s *connState) write(resp response) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return s.codec.WriteResponse(s.conn, resp)
+}
+
+func (s *connState) unsubscribe() {
+	for _, sub := range s.subscriptions {
+		sub.Close()
+	}
+	s.subscriptions = nil
+}
+
+// writeEvent writes message sent without request. Text protocol responses
+// have no delimiter, so there events are terminated with newline.
+func (s *connState) writeEvent(message string) error {
+	if _, ok := s.codec.(*textCodec); ok {
+		message += "\n"
+	}
+	return s.write(eventResponse(message))
+}
+
+func (s *connState) stopMonitor() {
+	if s.monitor != nil {
+		s.monitor.Close()
+		s.monitor = nil
+	}
+}
+
+// failed returns result of failed command, rendered as "<action>: <error>".
+// Code of err takes precedence over fallback.
+func failed(fallback model.ErrorCode, action string, err error) model.Result {
+	return model.Failure(fmt.Errorf("%s: %w", action, err), fallback)
+}
+
+// eventResponse returns message sent without request, like subscription event.
+func eventResponse(message string) response {
+	return response{result: model.OK(model.StringValue(message))}
+}
+
+// commandName returns lower case name of command in query.
+func commandName(query string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	return strings.ToLower(name)
+}
+
+// splitTrace returns query without TRACE <id> prefix and trace ID, empty when
+// query has no prefix.
+func splitTrace(query string) (string, string, error) {
+	name, rest, ok := strings.Cut(query, " ")
+	if !ok || !strings.EqualFold(name, commandTRACE) {
+		return query, "", nil
+	}
+
+	traceID, query, _ := strings.Cut(strings.TrimLeft(rest, " "), " ")
+	if err := trace.ValidateTraceID(traceID); err != nil {
+		return "", "", err
+	}
+
+	return strings.TrimSpace(query), traceID, nil
+}
+
+// parseCommand returns arguments if query is the connection level command.
+func parseCommand(query, command string) ([]string, bool) {
+	if len(query) < len(command) || !strings.EqualFold(query[:len(command)], command) {
+		return nil, false
+	}
+
+	parts, err := shlex.Split(query)
+	if err != nil || len(parts) == 0 || !strings.EqualFold(parts[0], command) {
+		return nil, false
+	}
+
+	return parts[1:], true
+}
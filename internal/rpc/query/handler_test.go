@@ -3,10 +3,18 @@ package query
 import (
 	"bufio"
 	"context"
+	"errors"
+	"io"
 	"net"
 	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"kvdb/internal/model"
+	"kvdb/internal/pubsub"
+	"kvdb/internal/session"
+	"kvdb/internal/trace"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
@@ -17,8 +25,8 @@ type MockDatabase struct {
 	response string
 }
 
-func (m *MockDatabase) RunCommand(_ context.Context, _ string) string {
-	return m.response
+func (m *MockDatabase) RunCommand(_ context.Context, _ string) model.Result {
+	return model.OK(model.StringValue(m.response))
 }
 
 func TestHandler_Handle(t *testing.T) {
@@ -118,3 +126,356 @@ func TestHandler_Handle_WriteError(t *testing.T) {
 
 	wg.Wait()
 }
+
+// MockAuthenticator is a mock implementation of the Authenticator interface for testing.
+type MockAuthenticator struct {
+	username string
+	password string
+}
+
+func (m *MockAuthenticator) Required() bool {
+	return true
+}
+
+func (m *MockAuthenticator) Authenticate(_, username, password string) error {
+	if username != m.username || password != m.password {
+		return errors.New("invalid password")
+	}
+	return nil
+}
+
+func TestHandler_Handle_Auth(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	mockDB := &MockDatabase{response: "mock response"}
+	handler := New(mockDB, logger).WithAuthenticator(&MockAuthenticator{password: "secret pass"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.Handle(ctx, serverConn)
+	}()
+
+	send := func(query string) string {
+		_, err := clientConn.Write([]byte(query + "\n"))
+		require.NoError(t, err)
+
+		buf := make([]byte, 1024)
+		n, err := clientConn.Read(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	require.Equal(t, "(error) NOAUTH failed auth: authentication required", send("get key"))
+	require.Equal(t, "(error) WRONGPASS failed auth: invalid password", send("AUTH wrong"))
+	require.Equal(t, "(error) WRONGARGS failed parse query: invalid args: want 1 to 2 args", send("auth"))
+	require.Equal(t, "ok", send(`auth "secret pass"`))
+	require.Equal(t, "mock response", send("get key"))
+	require.Equal(t, "(error) WRONGPASS failed auth: invalid password", send(`auth alice "secret pass"`))
+	require.Equal(t, "mock response", send("get key"))
+
+	clientConn.Close()
+	wg.Wait()
+}
+
+func TestHandler_Process_Ping(t *testing.T) {
+	handler := New(&MockDatabase{response: "mock response"}, zaptest.NewLogger(t))
+
+	conn, _ := net.Pipe()
+	defer conn.Close()
+
+	state := &connState{session: session.New(conn)}
+	require.Equal(t, "PONG", handler.process(context.Background(), state, "PING").String())
+	require.Equal(t, "failed parse query: invalid args: want 0 args",
+		handler.process(context.Background(), state, "ping me").String())
+}
+
+func TestHandler_Handle_AuthNotConfigured(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	mockDB := &MockDatabase{response: "mock response"}
+	handler := New(mockDB, logger)
+
+	conn, _ := net.Pipe()
+	defer conn.Close()
+
+	state := &connState{session: session.New(conn)}
+	result := handler.process(context.Background(), state, "AUTH secret")
+	require.Equal(t, "failed auth: no password is set", result.String())
+	require.Equal(t, model.CodeDisabled, result.Code)
+	require.Equal(t, "mock response", handler.process(context.Background(), state, "get key").String())
+	require.Equal(t, "mock response", handler.process(context.Background(), state, "authors").String())
+}
+
+func TestHandler_Handle_AuthUser(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	mockDB := &MockDatabase{response: "mock response"}
+	handler := New(mockDB, logger).WithAuthenticator(&MockAuthenticator{username: "alice", password: "secret"})
+
+	conn, _ := net.Pipe()
+	defer conn.Close()
+
+	state := &connState{session: session.New(conn)}
+	require.Equal(t, "failed auth: invalid password", handler.process(context.Background(), state, "AUTH secret").String())
+	require.Equal(t, "ok", handler.process(context.Background(), state, "AUTH alice secret").String())
+	require.True(t, state.authenticated)
+	require.Equal(t, "alice", state.session.User())
+}
+
+func TestHandler_Handle_ProtocolOptions(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	mockDB := &MockDatabase{response: "value"}
+	handler := New(mockDB, logger).
+		WithProtocol(ProtocolRESP).
+		WithMaxMessageSize(32).
+		WithAllowedCategories(model.CategoryRead)
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	sess := session.New(serverConn)
+	ctx := session.NewContext(context.Background(), sess)
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.Handle(ctx, serverConn)
+	}()
+
+	_, err := clientConn.Write([]byte("*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n"))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(clientConn)
+	header, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "$5\r\n", header)
+	payload, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "value\r\n", payload)
+
+	require.Equal(t, model.CategoryRead, sess.AllowedCategories())
+
+	// Too large message is reported and connection is closed.
+	_, err = clientConn.Write([]byte("GET " + strings.Repeat("k", 64) + "\r\n"))
+	require.NoError(t, err)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(line, "-TOOLARGE failed read query: message too large"))
+
+	wg.Wait()
+}
+
+// MockSubscriber subscribes to hub without access checks.
+type MockSubscriber struct {
+	hub *pubsub.Hub[model.KeyEvent]
+}
+
+func (m *MockSubscriber) Subscribe(_ context.Context, patterns []string) (*pubsub.Subscription[model.KeyEvent], error) {
+	return m.hub.Subscribe(func(e model.KeyEvent) bool {
+		return e.Key == patterns[0]
+	}, 0), nil
+}
+
+func TestHandler_Handle_Subscribe(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	hub := pubsub.New[model.KeyEvent]()
+	handler := New(&MockDatabase{response: "ok"}, logger).
+		WithProtocol(ProtocolFramed).
+		WithSubscriber(&MockSubscriber{hub: hub})
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.Handle(context.Background(), serverConn)
+	}()
+
+	clientCodec := &framedCodec{}
+	reader := bufio.NewReader(clientConn)
+	send := func(query string) string {
+		require.NoError(t, clientCodec.WriteResponse(clientConn, stringResponse(query)))
+		response, err := clientCodec.ReadQuery(reader)
+		require.NoError(t, err)
+		return response
+	}
+
+	require.Equal(t, "(error) WRONGARGS failed parse query: invalid args: want at least 1 args", send("SUBSCRIBE"))
+	require.Equal(t, "ok", send("SUBSCRIBE 'user 1'"))
+	require.Equal(t, 1, hub.Len())
+
+	hub.Publish(model.KeyEvent{Command: model.CommandSET, Key: "user 2"})
+	hub.Publish(model.KeyEvent{Command: model.CommandSET, Key: "user 1"})
+	event, err := clientCodec.ReadQuery(reader)
+	require.NoError(t, err)
+	require.Equal(t, "event set 'user 1'", event)
+
+	require.Equal(t, "ok", send("unsubscribe"))
+	require.Equal(t, 0, hub.Len())
+
+	clientConn.Close()
+	wg.Wait()
+}
+
+// TestHandler_Handle_SubscribeText tests that events are terminated with newline
+// in text protocol, which responses have no delimiter.
+func TestHandler_Handle_SubscribeText(t *testing.T) {
+	hub := pubsub.New[model.KeyEvent]()
+	handler := New(&MockDatabase{response: "ok"}, zaptest.NewLogger(t)).
+		WithSubscriber(&MockSubscriber{hub: hub})
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.Handle(context.Background(), serverConn)
+	}()
+
+	_, err := clientConn.Write([]byte("SUBSCRIBE key\n"))
+	require.NoError(t, err)
+	reader := bufio.NewReader(clientConn)
+	response := make([]byte, len("ok"))
+	_, err = io.ReadFull(reader, response)
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(response))
+
+	hub.Publish(model.KeyEvent{Command: model.CommandSET, Key: "key"})
+	event, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "event set key\n", event)
+
+	clientConn.Close()
+	wg.Wait()
+}
+
+func TestHandler_Handle_SubscribeDisabled(t *testing.T) {
+	handler := New(&MockDatabase{response: "mock response"}, zaptest.NewLogger(t))
+
+	conn, _ := net.Pipe()
+	defer conn.Close()
+
+	state := &connState{session: session.New(conn)}
+	require.Equal(t, "failed subscribe: subscriptions are disabled on this listener",
+		handler.process(context.Background(), state, "SUBSCRIBE *").String())
+}
+
+// MockMonitor subscribes to hub without access checks.
+type MockMonitor struct {
+	hub *pubsub.Hub[model.QueryEvent]
+}
+
+func (m *MockMonitor) Monitor(_ context.Context) (*pubsub.Subscription[model.QueryEvent], error) {
+	return m.hub.Subscribe(nil, 0), nil
+}
+
+func TestHandler_Handle_Monitor(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	hub := pubsub.New[model.QueryEvent]()
+	handler := New(&MockDatabase{response: "ok"}, logger).
+		WithProtocol(ProtocolFramed).
+		WithMonitor(&MockMonitor{hub: hub})
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.Handle(context.Background(), serverConn)
+	}()
+
+	clientCodec := &framedCodec{}
+	reader := bufio.NewReader(clientConn)
+	send := func(query string) string {
+		require.NoError(t, clientCodec.WriteResponse(clientConn, stringResponse(query)))
+		response, err := clientCodec.ReadQuery(reader)
+		require.NoError(t, err)
+		return response
+	}
+
+	require.Equal(t, "(error) WRONGARGS failed parse query: invalid args: want 0 args", send("MONITOR all"))
+	require.Equal(t, "ok", send("monitor"))
+	require.Equal(t, "ok", send("monitor"))
+	require.Equal(t, 1, hub.Len())
+
+	hub.Publish(model.QueryEvent{
+		Time:       time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		ClientAddr: "127.0.0.1:4242",
+		Query:      "set key 'some value'",
+	})
+	event, err := clientCodec.ReadQuery(reader)
+	require.NoError(t, err)
+	require.Equal(t, "monitor 2024-05-01T10:00:00Z 127.0.0.1:4242 set key 'some value'", event)
+
+	clientConn.Close()
+	wg.Wait()
+	require.Equal(t, 0, hub.Len())
+}
+
+func TestHandler_Handle_MonitorDisabled(t *testing.T) {
+	handler := New(&MockDatabase{response: "mock response"}, zaptest.NewLogger(t))
+
+	conn, _ := net.Pipe()
+	defer conn.Close()
+
+	state := &connState{session: session.New(conn)}
+	require.Equal(t, "failed monitor: monitor is disabled on this listener",
+		handler.process(context.Background(), state, "MONITOR").String())
+}
+
+// TracingDatabase records trace ids and queries it receives.
+type TracingDatabase struct {
+	ids     []trace.IDs
+	queries []string
+}
+
+func (m *TracingDatabase) RunCommand(ctx context.Context, rawQuery string) model.Result {
+	ids, _ := trace.FromContext(ctx)
+	m.ids = append(m.ids, ids)
+	m.queries = append(m.queries, rawQuery)
+	return model.OK(model.NoneValue())
+}
+
+func TestHandler_Handle_Trace(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	mockDB := &TracingDatabase{}
+	handler := New(mockDB, logger)
+
+	conn, _ := net.Pipe()
+	defer conn.Close()
+
+	sess := session.New(conn)
+	ctx := trace.NewContext(context.Background(), trace.IDs{ConnID: sess.ID()})
+	state := &connState{session: sess}
+
+	require.Equal(t, "ok", handler.processTraced(ctx, state, "get key").text())
+	require.Equal(t, "trace req-1 ok", handler.processTraced(ctx, state, "TRACE req-1 set key value").text())
+	require.Equal(t, "trace req-2 ok", handler.processTraced(ctx, state, "trace  req-2  get key").text())
+	require.Equal(t, "(error) SYNTAX failed parse query: invalid trace id: unexpected character '/'", handler.processTraced(ctx, state, "trace a/b get key").text())
+	require.Equal(t, "ok", handler.processTraced(ctx, state, "tracer").text())
+
+	require.Equal(t, []string{"get key", "set key value", "get key", "tracer"}, mockDB.queries)
+	require.Len(t, mockDB.ids, 4)
+	require.Equal(t, "", mockDB.ids[0].TraceID)
+	require.Equal(t, "req-1", mockDB.ids[1].TraceID)
+	require.Equal(t, "req-2", mockDB.ids[2].TraceID)
+
+	for i, ids := range mockDB.ids {
+		require.Equal(t, sess.ID(), ids.ConnID)
+		if i > 0 {
+			require.Greater(t, ids.CommandID, mockDB.ids[i-1].CommandID, "command ids are unique")
+		}
+	}
+}
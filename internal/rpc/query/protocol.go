@@ -0,0 +1,271 @@
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"kvdb/internal/compute"
+	"kvdb/internal/model"
+	"strconv"
+	"strings"
+)
+
+const (
+	ProtocolText   = "text"   // Newline terminated queries, responses rendered as text.
+	ProtocolFramed = "framed" // Queries and text responses prefixed with 4 bytes big endian length.
+	ProtocolRESP   = "resp"   // Redis serialization protocol, responses keep types of results.
+)
+
+const frameHeaderSize = 4
+
+var (
+	ErrUnknownProtocol = errors.New("unknown protocol")
+	ErrMessageTooLarge = model.WithCode(model.CodeTooLarge, errors.New("message too large"))
+	ErrInvalidMessage  = model.WithCode(model.CodeSyntax, errors.New("invalid message"))
+)
+
+// codec reads queries from connection and writes responses back.
+type codec interface {
+	ReadQuery(r *bufio.Reader) (string, error)
+	WriteResponse(w io.Writer, resp response) error
+}
+
+// response is a result written to client with trace ID provided in query.
+type response struct {
+	result  model.Result
+	traceID string // Empty when query had no TRACE prefix.
+}
+
+// text renders response for text based protocols as "[trace <id> ]<result>",
+// failed result is rendered as "(error) <code> <message>".
+func (r response) text() string {
+	result := r.result.String()
+	if r.result.Status == model.StatusError {
+		result = strings.Join([]string{messageError, string(r.result.Code), result}, " ")
+	}
+	if r.traceID == "" {
+		return result
+	}
+	return strings.Join([]string{messageTrace, r.traceID, result}, " ")
+}
+
+func newCodec(protocol string, maxMessageSize uint64) (codec, error) {
+	switch protocol {
+	case ProtocolText, "":
+		return &textCodec{maxMessageSize: maxMessageSize}, nil
+	case ProtocolFramed:
+		return &framedCodec{maxMessageSize: maxMessageSize}, nil
+	case ProtocolRESP:
+		return &respCodec{maxMessageSize: maxMessageSize}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProtocol, protocol)
+	}
+}
+
+// ValidateProtocol returns ErrUnknownProtocol for unsupported protocol names.
+func ValidateProtocol(protocol string) error {
+	_, err := newCodec(protocol, 0)
+	return err
+}
+
+type textCodec struct {
+	maxMessageSize uint64
+}
+
+func (c *textCodec) ReadQuery(r *bufio.Reader) (string, error) {
+	line, err := readLine(r, c.maxMessageSize)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+func (c *textCodec) WriteResponse(w io.Writer, resp response) error {
+	_, err := w.Write([]byte(resp.text()))
+	return err
+}
+
+type framedCodec struct {
+	maxMessageSize uint64
+}
+
+func (c *framedCodec) ReadQuery(r *bufio.Reader) (string, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", err
+	}
+
+	size := binary.BigEndian.Uint32(header)
+	if c.maxMessageSize > 0 && uint64(size) > c.maxMessageSize {
+		return "", fmt.Errorf("%w: %d bytes", ErrMessageTooLarge, size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(payload)), nil
+}
+
+func (c *framedCodec) WriteResponse(w io.Writer, resp response) error {
+	response := resp.text()
+	frame := make([]byte, frameHeaderSize+len(response))
+	binary.BigEndian.PutUint32(frame, uint32(len(response)))
+	copy(frame[frameHeaderSize:], response)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+const (
+	maxRESPArrayLen   = 1024 * 1024 // Max elements of query array, also when message size is unlimited.
+	minBulkStringSize = 6           // Bytes of empty bulk string "$0\r\n\r\n".
+)
+
+// respCodec accepts arrays of bulk strings and inline commands.
+type respCodec struct {
+	maxMessageSize uint64
+}
+
+func (c *respCodec) ReadQuery(r *bufio.Reader) (string, error) {
+	line, err := readLine(r, c.maxMessageSize)
+	if err != nil {
+		return "", err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "*") {
+		// Inline command.
+		return strings.TrimSpace(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 {
+		return "", fmt.Errorf("%w: invalid array header %q", ErrInvalidMessage, line)
+	}
+	// Count is checked before reading args, so header alone can't make server
+	// wait for or allocate more than message size.
+	if count > maxRESPArrayLen || c.maxMessageSize > 0 && uint64(count) > c.maxMessageSize/minBulkStringSize {
+		return "", fmt.Errorf("%w: array of %d elements", ErrMessageTooLarge, count)
+	}
+
+	var (
+		total uint64
+		args  []string
+	)
+	for range count {
+		arg, err := c.readBulkString(r)
+		if err != nil {
+			return "", err
+		}
+
+		total += uint64(len(arg))
+		if c.maxMessageSize > 0 && total > c.maxMessageSize {
+			return "", fmt.Errorf("%w: %d bytes", ErrMessageTooLarge, total)
+		}
+
+		args = append(args, compute.Quote(arg))
+	}
+
+	return strings.Join(args, " "), nil
+}
+
+func (c *respCodec) readBulkString(r *bufio.Reader) (string, error) {
+	line, err := readLine(r, c.maxMessageSize)
+	if err != nil {
+		return "", err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "$") {
+		return "", fmt.Errorf("%w: want bulk string, got %q", ErrInvalidMessage, line)
+	}
+
+	size, err := strconv.Atoi(line[1:])
+	if err != nil || size < 0 {
+		return "", fmt.Errorf("%w: invalid bulk string header %q", ErrInvalidMessage, line)
+	}
+	if c.maxMessageSize > 0 && uint64(size) > c.maxMessageSize {
+		return "", fmt.Errorf("%w: %d bytes", ErrMessageTooLarge, size)
+	}
+
+	// Payload is followed by \r\n.
+	payload := make([]byte, size+2)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return "", err
+	}
+
+	return string(payload[:size]), nil
+}
+
+// WriteResponse writes result as RESP type: simple string OK without payload,
+// null bulk string for nil, bulk string, integer, array or error "-<code> <message>".
+// Traced response is an array of "trace", trace ID and result.
+func (c *respCodec) WriteResponse(w io.Writer, resp response) error {
+	var buf bytes.Buffer
+	if resp.traceID != "" {
+		buf.WriteString("*3\r\n")
+		writeBulkString(&buf, messageTrace)
+		writeBulkString(&buf, resp.traceID)
+	}
+
+	if resp.result.Status == model.StatusError {
+		// Simple errors can't contain line breaks.
+		message := strings.NewReplacer("\r", " ", "\n", " ").Replace(resp.result.String())
+		fmt.Fprintf(&buf, "-%s %s\r\n", resp.result.Code, message)
+	} else {
+		writeRESPValue(&buf, resp.result.Value)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeRESPValue(buf *bytes.Buffer, value model.Value) {
+	switch value.Kind {
+	case model.KindNil:
+		buf.WriteString("$-1\r\n")
+	case model.KindString:
+		writeBulkString(buf, value.Str)
+	case model.KindInt:
+		fmt.Fprintf(buf, ":%d\r\n", value.Int)
+	case model.KindArray:
+		fmt.Fprintf(buf, "*%d\r\n", len(value.Array))
+		for _, item := range value.Array {
+			writeRESPValue(buf, item)
+		}
+	default:
+		buf.WriteString("+OK\r\n")
+	}
+}
+
+func writeBulkString(buf *bytes.Buffer, s string) {
+	fmt.Fprintf(buf, "$%d\r\n%s\r\n", len(s), s)
+}
+
+// readLine reads line up to maxSize bytes, zero means unlimited.
+func readLine(r *bufio.Reader, maxSize uint64) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+
+		if maxSize > 0 && uint64(len(line)) > maxSize {
+			return "", fmt.Errorf("%w: more than %d bytes", ErrMessageTooLarge, maxSize)
+		}
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		return string(line), nil
+	}
+}
@@ -0,0 +1,172 @@
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"kvdb/internal/model"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func stringResponse(s string) response {
+	return response{result: model.OK(model.StringValue(s))}
+}
+
+func TestNewCodec_UnknownProtocol(t *testing.T) {
+	_, err := newCodec("http", 0)
+	require.ErrorIs(t, err, ErrUnknownProtocol)
+	require.ErrorIs(t, ValidateProtocol("http"), ErrUnknownProtocol)
+	require.NoError(t, ValidateProtocol(ProtocolRESP))
+}
+
+func TestTextCodec(t *testing.T) {
+	c := &textCodec{maxMessageSize: 16}
+
+	reader := bufio.NewReader(strings.NewReader("get key\n"))
+	query, err := c.ReadQuery(reader)
+	require.NoError(t, err)
+	require.Equal(t, "get key", query)
+
+	reader = bufio.NewReader(strings.NewReader("set key very_long_value\n"))
+	_, err = c.ReadQuery(reader)
+	require.ErrorIs(t, err, ErrMessageTooLarge)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, c.WriteResponse(buf, stringResponse("value")))
+	require.Equal(t, "value", buf.String())
+
+	buf.Reset()
+	require.NoError(t, c.WriteResponse(buf, response{result: model.OK(model.NilValue()), traceID: "req-1"}))
+	require.Equal(t, "trace req-1 nil", buf.String())
+}
+
+func TestFramedCodec(t *testing.T) {
+	c := &framedCodec{maxMessageSize: 16}
+
+	frame := func(payload string) []byte {
+		data := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+		binary.BigEndian.PutUint32(data, uint32(len(payload)))
+		return append(data, payload...)
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(append(frame("get key"), frame("del key")...)))
+	query, err := c.ReadQuery(reader)
+	require.NoError(t, err)
+	require.Equal(t, "get key", query)
+	query, err = c.ReadQuery(reader)
+	require.NoError(t, err)
+	require.Equal(t, "del key", query)
+
+	reader = bufio.NewReader(bytes.NewReader(frame("set key very_long_value")))
+	_, err = c.ReadQuery(reader)
+	require.ErrorIs(t, err, ErrMessageTooLarge)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, c.WriteResponse(buf, stringResponse("value")))
+	require.Equal(t, frame("value"), buf.Bytes())
+}
+
+func TestRESPCodec(t *testing.T) {
+	c := &respCodec{maxMessageSize: 64}
+
+	reader := bufio.NewReader(strings.NewReader(
+		"*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$11\r\nhello world\r\n" +
+			"GET key\r\n",
+	))
+
+	query, err := c.ReadQuery(reader)
+	require.NoError(t, err)
+	require.Equal(t, "SET key 'hello world'", query)
+
+	query, err = c.ReadQuery(reader)
+	require.NoError(t, err)
+	require.Equal(t, "GET key", query)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, c.WriteResponse(buf, stringResponse("hello world")))
+	require.Equal(t, "$11\r\nhello world\r\n", buf.String())
+}
+
+// TestRESPCodec_TotalTooLarge tests that args are limited by total size.
+func TestRESPCodec_TotalTooLarge(t *testing.T) {
+	c := &respCodec{maxMessageSize: 12}
+
+	_, err := c.ReadQuery(bufio.NewReader(strings.NewReader("*2\r\n$7\r\nabcdefg\r\n$7\r\nabcdefg\r\n")))
+	require.ErrorIs(t, err, ErrMessageTooLarge)
+	require.ErrorContains(t, err, "14 bytes")
+}
+
+// TestRESPCodec_HugeArrayUnlimited tests that array header is limited when
+// message size is unlimited.
+func TestRESPCodec_HugeArrayUnlimited(t *testing.T) {
+	c := &respCodec{}
+
+	_, err := c.ReadQuery(bufio.NewReader(strings.NewReader("*100000000\r\n")))
+	require.ErrorIs(t, err, ErrMessageTooLarge)
+}
+
+func TestRESPCodec_WriteResponse(t *testing.T) {
+	c := &respCodec{}
+
+	tests := []struct {
+		name     string
+		resp     response
+		expected string
+	}{
+		{name: "ok", resp: response{result: model.OK(model.NoneValue())}, expected: "+OK\r\n"},
+		{name: "nil", resp: response{result: model.OK(model.NilValue())}, expected: "$-1\r\n"},
+		{name: "integer", resp: response{result: model.OK(model.IntValue(42))}, expected: ":42\r\n"},
+		{
+			name:     "array",
+			resp:     response{result: model.OK(model.ArrayValue([]model.Value{model.StringValue("a"), model.NilValue()}))},
+			expected: "*2\r\n$1\r\na\r\n$-1\r\n",
+		},
+		{
+			name:     "error",
+			resp:     response{result: model.Error(model.CodeSyntax, errors.New("failed parse query:\nbad"))},
+			expected: "-SYNTAX failed parse query: bad\r\n",
+		},
+		{
+			name:     "traced",
+			resp:     response{result: model.OK(model.NoneValue()), traceID: "req-1"},
+			expected: "*3\r\n$5\r\ntrace\r\n$5\r\nreq-1\r\n+OK\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			require.NoError(t, c.WriteResponse(buf, tt.resp))
+			require.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestRESPCodec_Errors(t *testing.T) {
+	c := &respCodec{maxMessageSize: 8}
+
+	tests := []struct {
+		name        string
+		input       string
+		expectedErr error
+	}{
+		{name: "invalid array header", input: "*x\r\n", expectedErr: ErrInvalidMessage},
+		{name: "not bulk string", input: "*1\r\n:1\r\n", expectedErr: ErrInvalidMessage},
+		{name: "invalid bulk header", input: "*1\r\n$-5\r\n", expectedErr: ErrInvalidMessage},
+		{name: "bulk string too large", input: "*1\r\n$100\r\n", expectedErr: ErrMessageTooLarge},
+		{name: "total too large", input: "*2\r\n$5\r\nhello\r\n$5\r\nworld\r\n", expectedErr: ErrMessageTooLarge},
+		{name: "array longer than message", input: "*2\r\n$0\r\n\r\n$0\r\n\r\n", expectedErr: ErrMessageTooLarge},
+		{name: "huge array", input: "*100000000\r\n", expectedErr: ErrMessageTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.ReadQuery(bufio.NewReader(strings.NewReader(tt.input)))
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,428 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kvdb/internal/compute"
+	"kvdb/internal/database"
+	"kvdb/internal/model"
+	"kvdb/internal/rpc/query"
+	"kvdb/internal/security/acl"
+	"kvdb/internal/security/auth"
+	"kvdb/internal/session"
+	"kvdb/internal/trace"
+	"mime"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const defaultMaxBodySize = 1 << 20 // 1MB.
+
+// HeaderTraceID carries trace ID provided by client, it is echoed in response.
+const HeaderTraceID = "X-Trace-Id"
+
+// Error codes of JSON error bodies.
+const (
+	CodeBadRequest      = "bad_request"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeTooManyRequests = "too_many_requests"
+	CodeBodyTooLarge    = "body_too_large"
+	CodeUnsupportedType = "unsupported_media_type"
+	CodeInternal        = "internal"
+)
+
+var (
+	errBadRequest      = errors.New("bad request")
+	errBodyTooLarge    = errors.New("request body too large")
+	errUnsupportedType = errors.New("unsupported media type")
+	errKeyNotFound     = errors.New("key not found")
+	errEmptyKey        = errors.New("empty key")
+)
+
+type Database interface {
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key, value string) error
+	Del(ctx context.Context, key string) error
+}
+
+type Authenticator interface {
+	Required() bool
+	Authenticate(remoteAddr, username, password string) error
+}
+
+// Handler serves JSON API on top of database.
+type Handler struct {
+	database      Database
+	authenticator Authenticator
+	logger        *zap.Logger
+	mux           *http.ServeMux
+	opts          opts
+}
+
+type opts struct {
+	maxBodySize       int64          // Max request body size in bytes. Default 1MB.
+	allowedCategories model.Category // Commands requests may run. Default all.
+}
+
+type keyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type valueRequest struct {
+	Value *string `json:"value"`
+}
+
+type keysRequest struct {
+	Keys []string `json:"keys"`
+}
+
+type itemsRequest struct {
+	Items []keyValue `json:"items"`
+}
+
+type itemResult struct {
+	Key   string     `json:"key"`
+	Value *string    `json:"value,omitempty"`
+	Found *bool      `json:"found,omitempty"`
+	Error *errorBody `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []itemResult `json:"results"`
+}
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+func New(database Database, logger *zap.Logger) *Handler {
+	h := &Handler{
+		database: database,
+		logger:   logger,
+		mux:      http.NewServeMux(),
+		opts: opts{
+			maxBodySize:       defaultMaxBodySize,
+			allowedCategories: model.CategoryAll,
+		},
+	}
+
+	h.mux.HandleFunc("GET /v1/keys/{key}", h.getKey)
+	h.mux.HandleFunc("PUT /v1/keys/{key}", h.putKey)
+	h.mux.HandleFunc("DELETE /v1/keys/{key}", h.deleteKey)
+	h.mux.HandleFunc("POST /v1/batch/get", h.batchGet)
+	h.mux.HandleFunc("POST /v1/batch/set", h.batchSet)
+	h.mux.HandleFunc("POST /v1/batch/delete", h.batchDelete)
+
+	return h
+}
+
+// WithAuthenticator requires clients to send HTTP basic credentials when password is set.
+func (h *Handler) WithAuthenticator(authenticator Authenticator) *Handler {
+	h.authenticator = authenticator
+	return h
+}
+
+func (h *Handler) WithMaxBodySize(maxBodySize int64) *Handler {
+	h.opts.maxBodySize = maxBodySize
+	return h
+}
+
+// WithAllowedCategories restricts commands requests may run, like allowed
+// categories of listeners.
+func (h *Handler) WithAllowedCategories(categories model.Category) *Handler {
+	h.opts.allowedCategories = categories
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sess := session.NewWithAddr(r.RemoteAddr)
+	sess.SetAllowedCategories(h.opts.allowedCategories)
+
+	ids := trace.IDs{ConnID: sess.ID(), CommandID: trace.NextCommandID(), TraceID: r.Header.Get(HeaderTraceID)}
+	if ids.TraceID != "" {
+		if err := trace.ValidateTraceID(ids.TraceID); err != nil {
+			h.writeError(w, fmt.Errorf("%w: %w", errBadRequest, err))
+			return
+		}
+		w.Header().Set(HeaderTraceID, ids.TraceID)
+	}
+	ctx := trace.NewContext(r.Context(), ids)
+
+	if err := h.authenticate(r, sess); err != nil {
+		trace.Logger(ctx, h.logger).Warn(
+			"failed auth",
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.Error(err),
+		)
+		if !errors.Is(err, auth.ErrTooManyAttempts) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="kvdb"`)
+		}
+		h.writeError(w, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.opts.maxBodySize)
+	h.mux.ServeHTTP(w, r.WithContext(session.NewContext(ctx, sess)))
+}
+
+// authenticate checks basic credentials. Requests without credentials run
+// as default user unless password is required.
+func (h *Handler) authenticate(r *http.Request, sess *session.Session) error {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		if h.authenticator != nil && h.authenticator.Required() {
+			return query.ErrAuthRequired
+		}
+		return nil
+	}
+
+	if h.authenticator == nil {
+		return auth.ErrPasswordNotSet
+	}
+
+	if err := h.authenticator.Authenticate(r.RemoteAddr, username, password); err != nil {
+		return err
+	}
+
+	sess.SetUser(username)
+	return nil
+}
+
+func (h *Handler) getKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	value, ok, err := h.database.Get(r.Context(), key)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if !ok {
+		h.writeError(w, fmt.Errorf("%w: %s", errKeyNotFound, key))
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, keyValue{Key: key, Value: value})
+}
+
+func (h *Handler) putKey(w http.ResponseWriter, r *http.Request) {
+	var req valueRequest
+	if err := h.decode(r, &req); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if req.Value == nil {
+		h.writeError(w, fmt.Errorf("%w: want value", errBadRequest))
+		return
+	}
+
+	if err := h.database.Set(r.Context(), r.PathValue("key"), *req.Value); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) deleteKey(w http.ResponseWriter, r *http.Request) {
+	if err := h.database.Del(r.Context(), r.PathValue("key")); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) batchGet(w http.ResponseWriter, r *http.Request) {
+	var req keysRequest
+	if err := h.decode(r, &req); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	results := make([]itemResult, 0, len(req.Keys))
+	for _, key := range req.Keys {
+		result := itemResult{Key: key}
+
+		value, ok, err := h.database.Get(r.Context(), key)
+		if err != nil {
+			result.Error = h.errorBody(err)
+		} else {
+			result.Found = &ok
+			if ok {
+				result.Value = &value
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	h.writeJSON(w, http.StatusOK, batchResponse{Results: results})
+}
+
+func (h *Handler) batchSet(w http.ResponseWriter, r *http.Request) {
+	var req itemsRequest
+	if err := h.decode(r, &req); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	results := make([]itemResult, 0, len(req.Items))
+	for _, item := range req.Items {
+		result := itemResult{Key: item.Key}
+		if err := h.checkKey(item.Key); err != nil {
+			result.Error = h.errorBody(err)
+		} else if err := h.database.Set(r.Context(), item.Key, item.Value); err != nil {
+			result.Error = h.errorBody(err)
+		}
+
+		results = append(results, result)
+	}
+
+	h.writeJSON(w, http.StatusOK, batchResponse{Results: results})
+}
+
+func (h *Handler) batchDelete(w http.ResponseWriter, r *http.Request) {
+	var req keysRequest
+	if err := h.decode(r, &req); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	results := make([]itemResult, 0, len(req.Keys))
+	for _, key := range req.Keys {
+		result := itemResult{Key: key}
+		if err := h.checkKey(key); err != nil {
+			result.Error = h.errorBody(err)
+		} else if err := h.database.Del(r.Context(), key); err != nil {
+			result.Error = h.errorBody(err)
+		}
+
+		results = append(results, result)
+	}
+
+	h.writeJSON(w, http.StatusOK, batchResponse{Results: results})
+}
+
+// checkKey rejects keys which can't be set over text protocol either.
+func (h *Handler) checkKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: %w", errBadRequest, errEmptyKey)
+	}
+	return nil
+}
+
+// decode reads JSON body. Body of other content type is rejected: browser sends
+// form of any site as text/plain without preflight request, so such request must
+// not change data.
+func (h *Handler) decode(r *http.Request, v any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return fmt.Errorf("%w: want Content-Type application/json", errUnsupportedType)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return fmt.Errorf("%w: more than %d bytes", errBodyTooLarge, maxBytesErr.Limit)
+		}
+		return fmt.Errorf("%w: invalid json: %w", errBadRequest, err)
+	}
+
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: want single json object", errBadRequest)
+	}
+
+	return nil
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Error("failed write response", zap.Error(err))
+	}
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, err error) {
+	status, body := statusOf(err), h.errorBody(err)
+	if status == http.StatusInternalServerError {
+		h.logger.Error("failed handle request", zap.Error(err))
+	}
+
+	h.writeJSON(w, status, errorResponse{Error: *body})
+}
+
+func (h *Handler) errorBody(err error) *errorBody {
+	return &errorBody{
+		Code:    codeOf(statusOf(err)),
+		Message: err.Error(),
+	}
+}
+
+// statusOf maps compute, database and security errors to HTTP status.
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, errKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errBodyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errUnsupportedType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, query.ErrAuthRequired),
+		errors.Is(err, auth.ErrInvalidPassword),
+		errors.Is(err, auth.ErrPasswordNotSet):
+		return http.StatusUnauthorized
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		return http.StatusTooManyRequests
+	case errors.Is(err, database.ErrNotAllowed),
+		errors.Is(err, database.ErrACLDisabled),
+		errors.Is(err, acl.ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, errBadRequest),
+		errors.Is(err, database.ErrInvalidArgs),
+		errors.Is(err, database.ErrUnknownCommand),
+		errors.Is(err, compute.ErrInvalidQuery),
+		errors.Is(err, compute.ErrInvalidArgs),
+		errors.Is(err, compute.ErrUnknownCommand):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func codeOf(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusTooManyRequests:
+		return CodeTooManyRequests
+	case http.StatusRequestEntityTooLarge:
+		return CodeBodyTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedType
+	default:
+		return CodeInternal
+	}
+}
@@ -0,0 +1,332 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kvdb/internal/compute"
+	"kvdb/internal/database"
+	"kvdb/internal/model"
+	"kvdb/internal/security/auth"
+	"kvdb/internal/session"
+	"kvdb/internal/storage/inmemory"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// MockDatabase keeps values in map and denies access to keys prefixed with "secret".
+type MockDatabase struct {
+	values map[string]string
+	users  []string
+}
+
+func newMockDatabase() *MockDatabase {
+	return &MockDatabase{values: map[string]string{}}
+}
+
+func (m *MockDatabase) check(ctx context.Context, key string) error {
+	if sess, ok := session.FromContext(ctx); ok {
+		m.users = append(m.users, sess.User())
+	}
+	if strings.HasPrefix(key, "secret") {
+		return fmt.Errorf("%w: key %s", database.ErrNotAllowed, key)
+	}
+	return nil
+}
+
+func (m *MockDatabase) Get(ctx context.Context, key string) (string, bool, error) {
+	if err := m.check(ctx, key); err != nil {
+		return "", false, err
+	}
+	value, ok := m.values[key]
+	return value, ok, nil
+}
+
+func (m *MockDatabase) Set(ctx context.Context, key, value string) error {
+	if err := m.check(ctx, key); err != nil {
+		return err
+	}
+	m.values[key] = value
+	return nil
+}
+
+func (m *MockDatabase) Del(ctx context.Context, key string) error {
+	if err := m.check(ctx, key); err != nil {
+		return err
+	}
+	delete(m.values, key)
+	return nil
+}
+
+// MockAuthenticator accepts single username and password.
+type MockAuthenticator struct {
+	username, password string
+}
+
+func (m *MockAuthenticator) Required() bool {
+	return true
+}
+
+func (m *MockAuthenticator) Authenticate(_, username, password string) error {
+	if username != m.username || password != m.password {
+		return auth.ErrInvalidPassword
+	}
+	return nil
+}
+
+func doRequest(t *testing.T, handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) errorBody {
+	t.Helper()
+
+	var resp errorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp.Error
+}
+
+func TestHandler_Keys(t *testing.T) {
+	db := newMockDatabase()
+	handler := New(db, zaptest.NewLogger(t))
+
+	rec := doRequest(t, handler, http.MethodGet, "/v1/keys/foo", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeNotFound, decodeError(t, rec).Code)
+
+	rec = doRequest(t, handler, http.MethodPut, "/v1/keys/foo", `{"value": "bar baz"}`)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "bar baz", db.values["foo"])
+
+	rec = doRequest(t, handler, http.MethodGet, "/v1/keys/foo", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"key": "foo", "value": "bar baz"}`, rec.Body.String())
+
+	rec = doRequest(t, handler, http.MethodDelete, "/v1/keys/foo", "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.NotContains(t, db.values, "foo")
+}
+
+func TestHandler_Errors(t *testing.T) {
+	handler := New(newMockDatabase(), zaptest.NewLogger(t)).WithMaxBodySize(32)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "forbidden key",
+			method:     http.MethodGet,
+			path:       "/v1/keys/secret1",
+			wantStatus: http.StatusForbidden,
+			wantCode:   CodeForbidden,
+		},
+		{
+			name:       "invalid json",
+			method:     http.MethodPut,
+			path:       "/v1/keys/foo",
+			body:       `{"value":`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeBadRequest,
+		},
+		{
+			name:       "missing value",
+			method:     http.MethodPut,
+			path:       "/v1/keys/foo",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeBadRequest,
+		},
+		{
+			name:       "unknown field",
+			method:     http.MethodPut,
+			path:       "/v1/keys/foo",
+			body:       `{"val": "bar"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeBadRequest,
+		},
+		{
+			name:       "body too large",
+			method:     http.MethodPut,
+			path:       "/v1/keys/foo",
+			body:       `{"value": "` + strings.Repeat("a", 64) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   CodeBodyTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, handler, tt.method, tt.path, tt.body)
+			require.Equal(t, tt.wantStatus, rec.Code)
+
+			body := decodeError(t, rec)
+			assert.Equal(t, tt.wantCode, body.Code)
+			assert.NotEmpty(t, body.Message)
+		})
+	}
+}
+
+// TestHandler_ContentType tests that bodies which are not JSON, like forms of other
+// sites, are rejected before they change data.
+func TestHandler_ContentType(t *testing.T) {
+	db := newMockDatabase()
+	handler := New(db, zaptest.NewLogger(t))
+
+	for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded"} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/batch/set", strings.NewReader(`{"items": [{"key": "a", "value": "1"}]}`))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusUnsupportedMediaType, rec.Code, contentType)
+		assert.Equal(t, CodeUnsupportedType, decodeError(t, rec).Code)
+	}
+	assert.Empty(t, db.values)
+
+	req := httptest.NewRequest(http.MethodPut, "/v1/keys/a", strings.NewReader(`{"value": "1"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestHandler_AllowedCategories(t *testing.T) {
+	db := database.New(zap.NewNop(), compute.New(), inmemory.New())
+	handler := New(db, zaptest.NewLogger(t)).WithAllowedCategories(model.CategoryRead)
+
+	rec := doRequest(t, handler, http.MethodPut, "/v1/keys/a", `{"value": "1"}`)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, CodeForbidden, decodeError(t, rec).Code)
+
+	rec = doRequest(t, handler, http.MethodGet, "/v1/keys/a", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_Batch(t *testing.T) {
+	db := newMockDatabase()
+	handler := New(db, zaptest.NewLogger(t))
+
+	rec := doRequest(t, handler, http.MethodPost, "/v1/batch/set",
+		`{"items": [{"key": "a", "value": "1"}, {"key": "b", "value": "2"}, {"key": "secret", "value": "3"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"results": [
+		{"key": "a"},
+		{"key": "b"},
+		{"key": "secret", "error": {"code": "forbidden", "message": "command not allowed: key secret"}}
+	]}`, rec.Body.String())
+
+	rec = doRequest(t, handler, http.MethodPost, "/v1/batch/get", `{"keys": ["a", "c"]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"results": [
+		{"key": "a", "value": "1", "found": true},
+		{"key": "c", "found": false}
+	]}`, rec.Body.String())
+
+	rec = doRequest(t, handler, http.MethodPost, "/v1/batch/delete", `{"keys": ["a", ""]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"results": [
+		{"key": "a"},
+		{"key": "", "error": {"code": "bad_request", "message": "bad request: empty key"}}
+	]}`, rec.Body.String())
+	assert.Equal(t, map[string]string{"b": "2"}, db.values)
+}
+
+func TestHandler_Auth(t *testing.T) {
+	db := newMockDatabase()
+	handler := New(db, zaptest.NewLogger(t)).
+		WithAuthenticator(&MockAuthenticator{username: "alice", password: "secret"})
+
+	rec := doRequest(t, handler, http.MethodGet, "/v1/keys/foo", "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, CodeUnauthorized, decodeError(t, rec).Code)
+	assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/keys/foo", nil)
+	req.SetBasicAuth("alice", "wrong")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/keys/foo", nil)
+	req.SetBasicAuth("alice", "secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, []string{"alice"}, db.users)
+}
+
+func TestHandler_TraceID(t *testing.T) {
+	handler := New(newMockDatabase(), zaptest.NewLogger(t))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/keys/foo", nil)
+	req.Header.Set(HeaderTraceID, "req-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "req-1", rec.Header().Get(HeaderTraceID))
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/keys/foo", nil)
+	req.Header.Set(HeaderTraceID, "req 1")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, CodeBadRequest, decodeError(t, rec).Code)
+
+	rec = doRequest(t, handler, http.MethodGet, "/v1/keys/foo", "")
+	assert.Empty(t, rec.Header().Get(HeaderTraceID))
+}
+
+func TestServer_Listen(t *testing.T) {
+	db := newMockDatabase()
+	db.values["foo"] = "bar"
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	logger := zaptest.NewLogger(t)
+	server := NewServer(logger, listener, New(db, logger))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Listen(ctx)
+	}()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/v1/keys/foo")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var kv keyValue
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&kv))
+	assert.Equal(t, keyValue{Key: "foo", Value: "bar"}, kv)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultReadTimeout     = 10 * time.Second
+	defaultWriteTimeout    = 10 * time.Second
+	defaultShutdownTimeout = 5 * time.Second
+)
+
+// Server serves HTTP handler on listener until context is canceled.
+type Server struct {
+	logger   *zap.Logger
+	listener net.Listener
+	server   *http.Server
+}
+
+func NewServer(logger *zap.Logger, listener net.Listener, handler http.Handler) *Server {
+	return &Server{
+		logger:   logger,
+		listener: listener,
+		server: &http.Server{
+			Handler:      handler,
+			ReadTimeout:  defaultReadTimeout,
+			WriteTimeout: defaultWriteTimeout,
+			ErrorLog:     zap.NewStdLog(logger),
+		},
+	}
+}
+
+func (s *Server) WithReadTimeout(readTimeout time.Duration) *Server {
+	s.server.ReadTimeout = readTimeout
+	return s
+}
+
+func (s *Server) WithWriteTimeout(writeTimeout time.Duration) *Server {
+	s.server.WriteTimeout = writeTimeout
+	return s
+}
+
+func (s *Server) Listen(ctx context.Context) {
+	s.logger.Info(
+		"start serve http",
+		zap.String("addr", s.listener.Addr().String()),
+		zap.String("read_timeout", s.server.ReadTimeout.String()),
+		zap.String("write_timeout", s.server.WriteTimeout.String()),
+	)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		if err := s.server.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("failed serve http", zap.Error(err))
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+	case <-done:
+		return
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		s.logger.Error("failed shutdown http server", zap.Error(err))
+	}
+
+	<-done
+}
@@ -0,0 +1,501 @@
+package acl
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"kvdb/internal/glob"
+	"kvdb/internal/model"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const DefaultUser = "default"
+
+const subcommandWHOAMI = "whoami"
+
+var (
+	ErrUserNotFound     = model.WithCode(model.CodeNotFound, errors.New("user not found"))
+	ErrInvalidRule      = model.WithCode(model.CodeWrongArgs, errors.New("invalid rule"))
+	ErrInvalidUserName  = model.WithCode(model.CodeWrongArgs, errors.New("invalid user name"))
+	ErrDeleteDefault    = errors.New("default user can't be deleted")
+	ErrPermissionDenied = model.WithCode(model.CodeNoPerm, errors.New("permission denied"))
+)
+
+// ACL keeps users with their passwords and permissions. When file is set
+// every change is persisted to it.
+type ACL struct {
+	mu       sync.RWMutex
+	users    map[string]*user
+	file     string
+	verified *verifiedCache
+}
+
+func New() *ACL {
+	return &ACL{
+		users: map[string]*user{
+			DefaultUser: newDefaultUser(),
+		},
+		verified: newVerifiedCache(),
+	}
+}
+
+// WithFile persists rules to file on every change.
+func (a *ACL) WithFile(file string) *ACL {
+	a.file = file
+	return a
+}
+
+// SetUser creates user if needed and applies rules to it.
+func (a *ACL) SetUser(name string, rules []string) error {
+	if name == "" || !isFileToken(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidUserName, name)
+	}
+
+	for {
+		a.mu.RLock()
+		base := a.users[name]
+		a.mu.RUnlock()
+
+		// Rules are applied without lock, password rules derive slow hashes.
+		u := newUser(name)
+		if base != nil {
+			u = base.clone()
+		}
+		for _, rule := range rules {
+			if err := u.apply(rule); err != nil {
+				return err
+			}
+		}
+
+		a.mu.Lock()
+		if a.users[name] != base {
+			// User was changed meanwhile, apply rules to the new version.
+			a.mu.Unlock()
+			continue
+		}
+
+		users := maps.Clone(a.users)
+		users[name] = u
+		err := a.commit(users)
+		a.mu.Unlock()
+		return err
+	}
+}
+
+func (a *ACL) DelUser(name string) error {
+	if name == DefaultUser {
+		return ErrDeleteDefault
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.users[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, name)
+	}
+
+	users := maps.Clone(a.users)
+	delete(users, name)
+
+	return a.commit(users)
+}
+
+// GetUser returns description of user in "field: value" lines.
+func (a *ACL) GetUser(name string) (string, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUserNotFound, name)
+	}
+
+	return u.describe(), nil
+}
+
+// List returns users in the ACL file format sorted by name.
+func (a *ACL) List() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return listUsers(a.users)
+}
+
+// Required reports whether default user needs password.
+func (a *ACL) Required() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u := a.users[DefaultUser]
+	return !u.enabled || !u.nopass
+}
+
+// Verify checks user password. Empty name means default user.
+func (a *ACL) Verify(name, password string) bool {
+	if name == "" {
+		name = DefaultUser
+	}
+
+	a.mu.RLock()
+	u, ok := a.users[name]
+	a.mu.RUnlock()
+
+	if !ok || !u.enabled {
+		return false
+	}
+
+	// Users are never changed after they are committed, so hashes are checked
+	// without lock.
+	return u.nopass || u.matchPassword(password, a.verified.verify)
+}
+
+// Check returns ErrPermissionDenied if user is not allowed to run query.
+// Empty name means default user.
+func (a *ACL) Check(name string, query model.Query) error {
+	if name == "" {
+		name = DefaultUser
+	}
+
+	if query.Command == model.CommandACL && len(query.Args) > 0 && strings.EqualFold(query.Args[0], subcommandWHOAMI) {
+		return nil
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[name]
+	if !ok || !u.enabled {
+		return fmt.Errorf("%w: user %s is disabled", ErrPermissionDenied, name)
+	}
+
+	category := query.Command.Category()
+	if u.categories&category != category {
+		return fmt.Errorf("%w: user %s can't run %s commands", ErrPermissionDenied, name, category)
+	}
+
+	for _, key := range query.Keys() {
+		if !u.matchKey(key) {
+			return fmt.Errorf("%w: user %s can't access key %s", ErrPermissionDenied, name, key)
+		}
+	}
+
+	return nil
+}
+
+// Load replaces users with rules from file. Missing file is not an error.
+func (a *ACL) Load() error {
+	if a.file == "" {
+		return nil
+	}
+
+	f, err := os.Open(a.file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed open acl file: %w", err)
+	}
+	defer f.Close()
+
+	users, err := parseUsers(f)
+	if err != nil {
+		return fmt.Errorf("failed parse acl file: %s: %w", a.file, err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := users[DefaultUser]; !ok {
+		users[DefaultUser] = a.users[DefaultUser]
+	}
+	a.users = users
+
+	return nil
+}
+
+// commit saves users to file and makes them active.
+func (a *ACL) commit(users map[string]*user) error {
+	if a.file != "" {
+		if err := writeFile(a.file, listUsers(users)); err != nil {
+			return fmt.Errorf("failed save acl file: %w", err)
+		}
+	}
+
+	a.users = users
+	return nil
+}
+
+// user is never changed after it is committed, rules are applied to its clone.
+type user struct {
+	name       string
+	enabled    bool
+	nopass     bool
+	passwords  []string // Hashes with scheme prefix, pbkdf2: or sha256:.
+	categories model.Category
+	keys       []string // Glob patterns.
+}
+
+func newUser(name string) *user {
+	return &user{name: name}
+}
+
+func newDefaultUser() *user {
+	return &user{
+		name:       DefaultUser,
+		enabled:    true,
+		nopass:     true,
+		categories: model.CategoryAll,
+		keys:       []string{"*"},
+	}
+}
+
+func (u *user) clone() *user {
+	c := *u
+	c.passwords = slices.Clone(u.passwords)
+	c.keys = slices.Clone(u.keys)
+	return &c
+}
+
+func (u *user) apply(rule string) error {
+	switch {
+	case rule == "on":
+		u.enabled = true
+	case rule == "off":
+		u.enabled = false
+	case rule == "nopass":
+		u.nopass = true
+		u.passwords = nil
+	case rule == "resetpass":
+		u.nopass = false
+		u.passwords = nil
+	case rule == "allkeys":
+		u.keys = []string{"*"}
+	case rule == "resetkeys":
+		u.keys = nil
+	case rule == "reset":
+		*u = *newUser(u.name)
+	case strings.HasPrefix(rule, ">"):
+		// Hashes are salted, so the same password is found by verifying it.
+		u.nopass = false
+		if !u.matchPassword(rule[1:], verifyHash) {
+			u.passwords = append(u.passwords, HashPassword(rule[1:]))
+		}
+	case strings.HasPrefix(rule, "<"):
+		u.passwords = slices.DeleteFunc(u.passwords, func(p string) bool { return verifyHash(p, rule[1:]) })
+	case strings.HasPrefix(rule, schemeSHA256), strings.HasPrefix(rule, schemePBKDF2):
+		hash, ok := normalizeHash(rule)
+		if !ok {
+			return ValidateHash(rule)
+		}
+		u.addPassword(hash)
+	case strings.HasPrefix(rule, "+@"), strings.HasPrefix(rule, "-@"):
+		category, ok := model.ParseCategory(rule[2:])
+		if !ok {
+			return fmt.Errorf("%w: %s: unknown category", ErrInvalidRule, rule)
+		}
+		if rule[0] == '+' {
+			u.categories |= category
+		} else {
+			u.categories &^= category
+		}
+	case strings.HasPrefix(rule, "~") && len(rule) > 1:
+		if !isFileToken(rule) {
+			return fmt.Errorf("%w: %q: pattern must not contain spaces or control characters", ErrInvalidRule, rule)
+		}
+		if !slices.Contains(u.keys, rule[1:]) {
+			u.keys = append(u.keys, rule[1:])
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidRule, rule)
+	}
+
+	return nil
+}
+
+func (u *user) addPassword(hash string) {
+	u.nopass = false
+	if !slices.Contains(u.passwords, hash) {
+		u.passwords = append(u.passwords, hash)
+	}
+}
+
+// matchPassword reports whether password matches any hash checked by verify.
+func (u *user) matchPassword(password string, verify func(hash, password string) bool) bool {
+	matched := false
+	for _, p := range u.passwords {
+		// Check every hash, so time doesn't depend on which one matched.
+		if verify(p, password) {
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+func (u *user) matchKey(key string) bool {
+	for _, pattern := range u.keys {
+		if glob.Match(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// rules returns rules which create the same user from scratch.
+func (u *user) rules() []string {
+	rules := make([]string, 0, len(u.passwords)+len(u.keys)+4)
+
+	if u.enabled {
+		rules = append(rules, "on")
+	} else {
+		rules = append(rules, "off")
+	}
+
+	if u.nopass {
+		rules = append(rules, "nopass")
+	}
+	for _, p := range u.passwords {
+		rules = append(rules, p)
+	}
+
+	rules = append(rules, u.categoryRules()...)
+
+	for _, k := range u.keys {
+		rules = append(rules, "~"+k)
+	}
+
+	return rules
+}
+
+func (u *user) categoryRules() []string {
+	if u.categories == model.CategoryAll {
+		return []string{"+@all"}
+	}
+
+	rules := make([]string, 0, 3)
+	for _, category := range []model.Category{model.CategoryRead, model.CategoryWrite, model.CategoryAdmin} {
+		if u.categories&category != 0 {
+			rules = append(rules, "+@"+category.String())
+		}
+	}
+
+	return rules
+}
+
+func (u *user) describe() string {
+	flags := "off"
+	if u.enabled {
+		flags = "on"
+	}
+	if u.nopass {
+		flags += " nopass"
+	}
+
+	lines := []string{
+		"flags: " + flags,
+		"passwords: " + strings.Join(u.passwords, " "),
+		"categories: " + strings.Join(u.categoryRules(), " "),
+		"keys: " + strings.Join(u.keys, " "),
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// isFileToken reports whether s is kept as one field of ACL file line, which
+// is split by whitespace.
+func isFileToken(s string) bool {
+	return !strings.ContainsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	})
+}
+
+func listUsers(users map[string]*user) []string {
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		u := users[name]
+		lines = append(lines, strings.Join(append([]string{"user", u.name}, u.rules()...), " "))
+	}
+
+	return lines
+}
+
+// parseUsers reads ACL file. Every line is "user <name> <rules...>",
+// empty lines and lines started with # are skipped.
+func parseUsers(r io.Reader) (map[string]*user, error) {
+	users := make(map[string]*user)
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "user" {
+			return nil, fmt.Errorf("line %d: %w: want user <name> <rules...>", lineNum, ErrInvalidRule)
+		}
+
+		u := newUser(fields[1])
+		for _, rule := range fields[2:] {
+			if err := u.apply(rule); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+		}
+		users[u.name] = u
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// writeFile replaces file atomically so readers never see partial rules.
+func writeFile(path string, lines []string) error {
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
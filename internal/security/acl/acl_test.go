@@ -0,0 +1,266 @@
+package acl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+
+	"kvdb/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests hash passwords with the least work factor to run fast.
+func init() {
+	pbkdf2Iterations = pbkdf2MinIterations
+}
+
+// TestACL_Default tests permissions of default user.
+func TestACL_Default(t *testing.T) {
+	a := New()
+
+	assert.False(t, a.Required())
+	assert.True(t, a.Verify("", "anything"))
+	require.NoError(t, a.Check("", model.Query{Command: model.CommandSET, Args: []string{"key", "value"}}))
+	require.NoError(t, a.Check(DefaultUser, model.Query{Command: model.CommandACL, Args: []string{"list"}}))
+
+	require.NoError(t, a.SetUser(DefaultUser, []string{">secret"}))
+	assert.True(t, a.Required())
+	assert.True(t, a.Verify("", "secret"))
+	assert.False(t, a.Verify("", "wrong"))
+
+	require.ErrorIs(t, a.DelUser(DefaultUser), ErrDeleteDefault)
+}
+
+// TestACL_Check tests category and key pattern permissions.
+func TestACL_Check(t *testing.T) {
+	a := New()
+	require.NoError(t, a.SetUser("reader", []string{"on", ">pass", "+@read", "~cache:*", "~/etc/*"}))
+	require.NoError(t, a.SetUser("disabled", []string{"off", ">pass", "+@all", "allkeys"}))
+
+	tests := []struct {
+		name    string
+		user    string
+		query   model.Query
+		allowed bool
+	}{
+		{
+			name:    "read matching key",
+			user:    "reader",
+			query:   model.Query{Command: model.CommandGET, Args: []string{"cache:user:1"}},
+			allowed: true,
+		},
+		{
+			name:    "read key with slashes",
+			user:    "reader",
+			query:   model.Query{Command: model.CommandGET, Args: []string{"/etc/nginx/config"}},
+			allowed: true,
+		},
+		{
+			name:    "read other key",
+			user:    "reader",
+			query:   model.Query{Command: model.CommandGET, Args: []string{"session:1"}},
+			allowed: false,
+		},
+		{
+			name:    "write matching key",
+			user:    "reader",
+			query:   model.Query{Command: model.CommandSET, Args: []string{"cache:user:1", "value"}},
+			allowed: false,
+		},
+		{
+			name:    "admin command",
+			user:    "reader",
+			query:   model.Query{Command: model.CommandACL, Args: []string{"list"}},
+			allowed: false,
+		},
+		{
+			name:    "whoami is always allowed",
+			user:    "reader",
+			query:   model.Query{Command: model.CommandACL, Args: []string{"WHOAMI"}},
+			allowed: true,
+		},
+		{
+			name:    "disabled user",
+			user:    "disabled",
+			query:   model.Query{Command: model.CommandGET, Args: []string{"key"}},
+			allowed: false,
+		},
+		{
+			name:    "unknown user",
+			user:    "unknown",
+			query:   model.Query{Command: model.CommandGET, Args: []string{"key"}},
+			allowed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.Check(tt.user, tt.query)
+			if tt.allowed {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrPermissionDenied)
+			}
+		})
+	}
+}
+
+// TestACL_SetUser tests rules parsing.
+func TestACL_SetUser(t *testing.T) {
+	a := New()
+
+	require.ErrorIs(t, a.SetUser("bob", []string{"+@unknown"}), ErrInvalidRule)
+	require.ErrorIs(t, a.SetUser("bob", []string{"sha256:abc"}), ErrInvalidRule)
+	require.ErrorIs(t, a.SetUser("bob", []string{"garbage"}), ErrInvalidRule)
+	require.ErrorIs(t, a.SetUser("bo b", []string{"on"}), ErrInvalidUserName)
+
+	// Failed rules don't create user.
+	_, err := a.GetUser("bob")
+	require.ErrorIs(t, err, ErrUserNotFound)
+
+	require.NoError(t, a.SetUser("bob", []string{"on", ">one", ">two", "+@all", "-@admin", "~a:*"}))
+	assert.True(t, a.Verify("bob", "one"))
+	assert.True(t, a.Verify("bob", "two"))
+
+	require.NoError(t, a.SetUser("bob", []string{"<one", "~b:*"}))
+	assert.False(t, a.Verify("bob", "one"))
+	assert.True(t, a.Verify("bob", "two"))
+
+	description, err := a.GetUser("bob")
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile("^flags: on\n"+
+		"passwords: pbkdf2:1000:[0-9a-f]{32}:[0-9a-f]{64}\n"+
+		"categories: \\+@read \\+@write\n"+
+		"keys: a:\\* b:\\*$"), description)
+
+	require.NoError(t, a.SetUser("bob", []string{"reset"}))
+	assert.False(t, a.Verify("bob", "two"))
+
+	require.NoError(t, a.DelUser("bob"))
+	require.ErrorIs(t, a.DelUser("bob"), ErrUserNotFound)
+}
+
+// TestACL_Persistence tests saving rules to file and loading them back.
+func TestACL_Persistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "users.acl")
+
+	a := New().WithFile(file)
+	require.NoError(t, a.SetUser("alice", []string{"on", ">secret", "+@read", "~cache:*"}))
+	require.NoError(t, a.SetUser("bob", []string{"off", "nopass"}))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile("^user alice on pbkdf2:1000:[0-9a-f]{32}:[0-9a-f]{64} \\+@read ~cache:\\*\n"+
+		"user bob off nopass\n"+
+		"user default on nopass \\+@all ~\\*\n$"), string(data))
+
+	loaded := New().WithFile(file)
+	require.NoError(t, loaded.Load())
+	assert.Equal(t, a.List(), loaded.List())
+	assert.True(t, loaded.Verify("alice", "secret"))
+}
+
+// TestACL_PersistenceRoundTrip tests that every accepted rule survives saving to
+// file and loading it back.
+func TestACL_PersistenceRoundTrip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "users.acl")
+	a := New().WithFile(file)
+
+	for _, rule := range []string{"~a b", "~a\tb", "~a\u00a0b", "~a\x00b"} {
+		require.ErrorIs(t, a.SetUser("bob", []string{"on", rule}), ErrInvalidRule, "%q", rule)
+	}
+	for _, name := range []string{"bo\vb", "bo\u2028b", "bo\x7fb"} {
+		require.ErrorIs(t, a.SetUser(name, []string{"on"}), ErrInvalidUserName, "%q", name)
+	}
+
+	// Passwords are saved hashed, so spaces in them are fine.
+	require.NoError(t, a.SetUser("bob", []string{"on", ">pass with spaces", "+@read", "~ключ:*", "~a#b"}))
+	require.NoError(t, a.SetUser("дима", []string{"off", "nopass"}))
+
+	loaded := New().WithFile(file)
+	require.NoError(t, loaded.Load())
+	assert.Equal(t, a.List(), loaded.List())
+	assert.True(t, loaded.Verify("bob", "pass with spaces"))
+}
+
+// TestACL_Load tests loading of hand written file.
+func TestACL_Load(t *testing.T) {
+	dir := t.TempDir()
+
+	// Missing file is not an error.
+	require.NoError(t, New().WithFile(filepath.Join(dir, "missing.acl")).Load())
+
+	file := filepath.Join(dir, "users.acl")
+	require.NoError(t, os.WriteFile(file, []byte("# services\n\nuser svc on >pass +@write ~svc:*\n"), 0600))
+
+	a := New().WithFile(file)
+	require.NoError(t, a.Load())
+	assert.True(t, a.Verify("svc", "pass"))
+	assert.False(t, a.Required(), "default user is kept when missing in file")
+
+	require.NoError(t, os.WriteFile(file, []byte("admin on\n"), 0600))
+	require.ErrorIs(t, New().WithFile(file).Load(), ErrInvalidRule)
+}
+
+// TestACL_PasswordHashes tests salted hashes and legacy sha256 hashes.
+func TestACL_PasswordHashes(t *testing.T) {
+	hash := HashPassword("secret")
+	assert.NotEqual(t, hash, HashPassword("secret"), "hashes are salted")
+	require.NoError(t, ValidateHash(hash))
+
+	sum := sha256.Sum256([]byte("legacy"))
+	legacy := "sha256:" + hex.EncodeToString(sum[:])
+
+	a := New()
+	require.NoError(t, a.SetUser("bob", []string{"on", hash, legacy, ">secret"}))
+	assert.True(t, a.Verify("bob", "secret"))
+	assert.True(t, a.Verify("bob", "legacy"))
+	assert.True(t, a.Verify("bob", "secret"), "cached match")
+	assert.False(t, a.Verify("bob", "wrong"))
+
+	// Added password which is already set doesn't add hash.
+	assert.Equal(t, []string{"on", hash, legacy}, a.users["bob"].rules())
+
+	require.NoError(t, a.SetUser("bob", []string{"<legacy", "<secret"}))
+	assert.False(t, a.Verify("bob", "secret"))
+	assert.False(t, a.Verify("bob", "legacy"))
+
+	for _, rule := range []string{
+		"pbkdf2:",
+		"pbkdf2:10:" + hex.EncodeToString(make([]byte, 16)) + ":" + hex.EncodeToString(make([]byte, 32)),
+		"pbkdf2:1000:abcd:" + hex.EncodeToString(make([]byte, 32)),
+		"pbkdf2:1000:" + hex.EncodeToString(make([]byte, 16)) + ":abcd",
+		"sha256:xyz",
+	} {
+		require.ErrorIs(t, a.SetUser("bob", []string{rule}), ErrInvalidRule, rule)
+		require.ErrorIs(t, ValidateHash(rule), ErrInvalidRule, rule)
+	}
+}
+
+// TestACL_SetUserConcurrent tests that concurrent changes of the same user are
+// all applied, rules are applied outside of lock.
+func TestACL_SetUserConcurrent(t *testing.T) {
+	a := New()
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, a.SetUser("bob", []string{"on", ">pass" + strconv.Itoa(i)}))
+			assert.True(t, a.Verify("bob", "pass"+strconv.Itoa(i)))
+		}()
+	}
+	wg.Wait()
+
+	for i := range 8 {
+		assert.True(t, a.Verify("bob", "pass"+strconv.Itoa(i)), i)
+	}
+}
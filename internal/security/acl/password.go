@@ -0,0 +1,180 @@
+package acl
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	schemeSHA256 = "sha256:"
+	schemePBKDF2 = "pbkdf2:"
+
+	pbkdf2SaltSize      = 16
+	pbkdf2MinIterations = 1000
+
+	maxVerified = 1024
+)
+
+// pbkdf2Iterations is a work factor of new hashes, tests lower it.
+var pbkdf2Iterations = 600_000
+
+// HashPassword returns salted PBKDF2-HMAC-SHA256 hash of password as used in
+// rules: pbkdf2:<iterations>:<hex salt>:<hex key>.
+func HashPassword(password string) string {
+	salt := make([]byte, pbkdf2SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		panic(fmt.Sprintf("failed read random salt: %v", err))
+	}
+
+	key := pbkdf2([]byte(password), salt, pbkdf2Iterations)
+	return fmt.Sprintf("%s%d:%x:%x", schemePBKDF2, pbkdf2Iterations, salt, key)
+}
+
+// ValidateHash returns ErrInvalidRule if hash is neither pbkdf2 hash returned by
+// HashPassword nor hex encoded sha256 with sha256: prefix. Unsalted sha256 is only
+// accepted for hashes saved by older versions.
+func ValidateHash(hash string) error {
+	if _, ok := normalizeHash(hash); !ok {
+		return fmt.Errorf("%w: %s: want pbkdf2:<iterations>:<hex salt>:<hex key> or sha256:<hex>", ErrInvalidRule, hash)
+	}
+	return nil
+}
+
+// normalizeHash returns hash with lower case hex digits, false if hash is invalid.
+func normalizeHash(hash string) (string, bool) {
+	switch {
+	case strings.HasPrefix(hash, schemeSHA256):
+		sum := strings.ToLower(strings.TrimPrefix(hash, schemeSHA256))
+		if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != sha256.Size {
+			return "", false
+		}
+		return schemeSHA256 + sum, true
+	case strings.HasPrefix(hash, schemePBKDF2):
+		if _, _, _, ok := parsePBKDF2(hash); !ok {
+			return "", false
+		}
+		return strings.ToLower(hash), true
+	default:
+		return "", false
+	}
+}
+
+// parsePBKDF2 returns iterations, salt and key of pbkdf2 hash.
+func parsePBKDF2(hash string) (int, []byte, []byte, bool) {
+	parts := strings.Split(strings.TrimPrefix(hash, schemePBKDF2), ":")
+	if len(parts) != 3 {
+		return 0, nil, nil, false
+	}
+
+	iterations, err := strconv.Atoi(parts[0])
+	if err != nil || iterations < pbkdf2MinIterations {
+		return 0, nil, nil, false
+	}
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil || len(salt) < pbkdf2SaltSize {
+		return 0, nil, nil, false
+	}
+	key, err := hex.DecodeString(parts[2])
+	if err != nil || len(key) != sha256.Size {
+		return 0, nil, nil, false
+	}
+
+	return iterations, salt, key, true
+}
+
+// verifyHash reports whether password matches hash in constant time.
+func verifyHash(hash, password string) bool {
+	switch {
+	case strings.HasPrefix(hash, schemeSHA256):
+		sum := sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(schemeSHA256+hex.EncodeToString(sum[:]))) == 1
+	case strings.HasPrefix(hash, schemePBKDF2):
+		iterations, salt, key, ok := parsePBKDF2(hash)
+		if !ok {
+			return false
+		}
+		return subtle.ConstantTimeCompare(key, pbkdf2([]byte(password), salt, iterations)) == 1
+	default:
+		return false
+	}
+}
+
+// pbkdf2 derives sha256.Size bytes key from password as defined by RFC 8018.
+func pbkdf2(password, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, password)
+
+	// The first and only block, key is as long as output of prf.
+	prf.Write(salt)
+	prf.Write(binary.BigEndian.AppendUint32(nil, 1))
+	u := prf.Sum(nil)
+
+	key := make([]byte, len(u))
+	copy(key, u)
+	for range iterations - 1 {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		subtle.XORBytes(key, key, u)
+	}
+
+	return key
+}
+
+// verifiedCache remembers pairs of hash and password which matched, so clients
+// which authenticate every request, e.g. over REST, don't run the slow KDF again.
+// Pairs are keyed by HMAC with random key of the process, plain passwords are
+// not kept. The cache is cleared when it is full.
+type verifiedCache struct {
+	mu      sync.Mutex
+	secret  []byte
+	entries map[string]struct{}
+}
+
+func newVerifiedCache() *verifiedCache {
+	secret := make([]byte, sha256.Size)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("failed read random key: %v", err))
+	}
+
+	return &verifiedCache{
+		secret:  secret,
+		entries: make(map[string]struct{}),
+	}
+}
+
+// verify reports whether password matches hash, matches are cached.
+func (c *verifiedCache) verify(hash, password string) bool {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(hash))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	entry := string(mac.Sum(nil))
+
+	c.mu.Lock()
+	_, ok := c.entries[entry]
+	c.mu.Unlock()
+	if ok {
+		return true
+	}
+
+	if !verifyHash(hash, password) {
+		return false
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= maxVerified {
+		clear(c.entries)
+	}
+	c.entries[entry] = struct{}{}
+	c.mu.Unlock()
+
+	return true
+}
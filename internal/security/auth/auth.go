@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"errors"
+	"kvdb/internal/model"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxFailures   = 5
+	defaultBlockDuration = time.Minute
+)
+
+var (
+	ErrInvalidPassword = model.WithCode(model.CodeWrongPass, errors.New("invalid username or password"))
+	ErrTooManyAttempts = model.WithCode(model.CodeLimit, errors.New("too many failed attempts"))
+	ErrPasswordNotSet  = model.WithCode(model.CodeDisabled, errors.New("no password is set"))
+)
+
+// Verifier checks user credentials, empty username means default user.
+type Verifier interface {
+	Required() bool
+	Verify(username, password string) bool
+}
+
+// Authenticator checks credentials with verifier and temporary blocks hosts
+// after repeated failures. Failures of host are forgotten when it is idle for
+// block duration, at least a minute, so hosts which failed once don't stay in
+// memory forever.
+type Authenticator struct {
+	verifier Verifier
+	opts     opts
+	now      func() time.Time
+
+	mu        sync.Mutex
+	failures  map[string]*failure
+	nextSweep time.Time // Time of the next removal of forgotten failures.
+}
+
+type opts struct {
+	maxFailures   int           // Failed attempts before host is blocked. Default 5.
+	blockDuration time.Duration // How long host is blocked. Default 1m.
+}
+
+type failure struct {
+	count        int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+func New(verifier Verifier) *Authenticator {
+	return &Authenticator{
+		verifier: verifier,
+		opts: opts{
+			maxFailures:   defaultMaxFailures,
+			blockDuration: defaultBlockDuration,
+		},
+		now:      time.Now,
+		failures: make(map[string]*failure),
+	}
+}
+
+func (a *Authenticator) WithMaxFailures(maxFailures int) *Authenticator {
+	a.opts.maxFailures = maxFailures
+	return a
+}
+
+func (a *Authenticator) WithBlockDuration(blockDuration time.Duration) *Authenticator {
+	a.opts.blockDuration = blockDuration
+	return a
+}
+
+// Required reports whether clients have to authenticate before running commands.
+func (a *Authenticator) Required() bool {
+	return a.verifier.Required()
+}
+
+// Authenticate checks credentials of client connected from remoteAddr.
+// Empty username means default user.
+func (a *Authenticator) Authenticate(remoteAddr, username, password string) error {
+	if username == "" && !a.verifier.Required() {
+		return ErrPasswordNotSet
+	}
+
+	host := hostOf(remoteAddr)
+	if err := a.checkBlocked(host); err != nil {
+		return err
+	}
+
+	// Verify may be slow, password hashes are derived with a costly function,
+	// so authentications of other hosts don't wait for it.
+	ok := a.verifier.Verify(username, password)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if ok {
+		delete(a.failures, host)
+		return nil
+	}
+
+	now := a.now()
+	f := a.failures[host]
+	if f == nil {
+		f = &failure{}
+		a.failures[host] = f
+	}
+	if now.Before(f.blockedUntil) {
+		// Concurrent attempt blocked host already.
+		return ErrInvalidPassword
+	}
+
+	f.count++
+	f.lastFailure = now
+	if a.opts.maxFailures > 0 && f.count >= a.opts.maxFailures {
+		f.count = 0
+		f.blockedUntil = now.Add(a.opts.blockDuration)
+	}
+
+	return ErrInvalidPassword
+}
+
+// checkBlocked returns ErrTooManyAttempts if host is blocked.
+func (a *Authenticator) checkBlocked(host string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	a.sweep(now)
+
+	f := a.failures[host]
+	if f != nil && now.Before(f.blockedUntil) {
+		return ErrTooManyAttempts
+	}
+	if f != nil && !f.blockedUntil.IsZero() {
+		// Block expired, start counting from scratch.
+		f.blockedUntil = time.Time{}
+	}
+	return nil
+}
+
+// sweep removes failures of hosts which are not blocked and failed longer than
+// forget duration ago. Map is scanned at most once per forget duration.
+func (a *Authenticator) sweep(now time.Time) {
+	if now.Before(a.nextSweep) {
+		return
+	}
+
+	forget := a.forgetDuration()
+	a.nextSweep = now.Add(forget)
+	for host, f := range a.failures {
+		if !now.Before(f.blockedUntil) && now.Sub(f.lastFailure) >= forget {
+			delete(a.failures, host)
+		}
+	}
+}
+
+func (a *Authenticator) forgetDuration() time.Duration {
+	return max(a.opts.blockDuration, defaultBlockDuration)
+}
+
+func hostOf(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockVerifier accepts users with matching passwords.
+type mockVerifier struct {
+	passwords map[string]string
+}
+
+func (m *mockVerifier) Required() bool {
+	_, ok := m.passwords[""]
+	return ok
+}
+
+func (m *mockVerifier) Verify(username, password string) bool {
+	p, ok := m.passwords[username]
+	return ok && p == password
+}
+
+// TestAuthenticate tests credentials checking.
+func TestAuthenticate(t *testing.T) {
+	a := New(&mockVerifier{passwords: map[string]string{"": "secret", "alice": "alice secret"}})
+	assert.True(t, a.Required())
+
+	require.NoError(t, a.Authenticate("127.0.0.1:1000", "", "secret"))
+	require.NoError(t, a.Authenticate("127.0.0.1:1000", "alice", "alice secret"))
+	require.ErrorIs(t, a.Authenticate("127.0.0.1:1000", "", "wrong"), ErrInvalidPassword)
+	require.ErrorIs(t, a.Authenticate("127.0.0.1:1000", "bob", "secret"), ErrInvalidPassword)
+}
+
+// TestAuthenticate_NoPasswords tests authenticator when default user has no password.
+func TestAuthenticate_NoPasswords(t *testing.T) {
+	a := New(&mockVerifier{passwords: map[string]string{"alice": "alice secret"}})
+	assert.False(t, a.Required())
+
+	require.ErrorIs(t, a.Authenticate("127.0.0.1:1000", "", "secret"), ErrPasswordNotSet)
+	require.NoError(t, a.Authenticate("127.0.0.1:1000", "alice", "alice secret"))
+}
+
+// TestAuthenticate_RateLimit tests blocking of host after repeated failures.
+func TestAuthenticate_RateLimit(t *testing.T) {
+	a := New(&mockVerifier{passwords: map[string]string{"": "secret"}})
+
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	a.WithMaxFailures(3).WithBlockDuration(time.Minute)
+
+	for range 3 {
+		require.ErrorIs(t, a.Authenticate("10.0.0.1:1000", "", "wrong"), ErrInvalidPassword)
+	}
+
+	// Host is blocked even with valid password and from another port.
+	require.ErrorIs(t, a.Authenticate("10.0.0.1:2000", "", "secret"), ErrTooManyAttempts)
+
+	// Other hosts are not affected.
+	require.NoError(t, a.Authenticate("10.0.0.2:1000", "", "secret"))
+
+	now = now.Add(time.Minute)
+	require.NoError(t, a.Authenticate("10.0.0.1:2000", "", "secret"))
+
+	// Success resets failures counter.
+	for range 2 {
+		require.ErrorIs(t, a.Authenticate("10.0.0.1:1000", "", "wrong"), ErrInvalidPassword)
+	}
+	require.NoError(t, a.Authenticate("10.0.0.1:1000", "", "secret"))
+	for range 2 {
+		require.ErrorIs(t, a.Authenticate("10.0.0.1:1000", "", "wrong"), ErrInvalidPassword)
+	}
+	require.NoError(t, a.Authenticate("10.0.0.1:1000", "", "secret"))
+}
+
+// TestAuthenticate_ForgetFailures tests that failures of idle hosts are removed.
+func TestAuthenticate_ForgetFailures(t *testing.T) {
+	a := New(&mockVerifier{passwords: map[string]string{"": "secret"}})
+
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	a.WithMaxFailures(3).WithBlockDuration(time.Minute)
+
+	// Client rotating addresses.
+	for i := range 100 {
+		require.ErrorIs(t, a.Authenticate(fmt.Sprintf("10.0.%d.%d:1000", i/256, i%256), "", "wrong"), ErrInvalidPassword)
+	}
+	for range 3 {
+		require.ErrorIs(t, a.Authenticate("10.1.0.1:1000", "", "wrong"), ErrInvalidPassword)
+	}
+	assert.Len(t, a.failures, 101)
+
+	now = now.Add(30 * time.Second)
+	require.ErrorIs(t, a.Authenticate("10.2.0.1:1000", "", "wrong"), ErrInvalidPassword)
+	assert.Len(t, a.failures, 102, "failures are kept until host is idle for block duration")
+
+	now = now.Add(time.Minute)
+	require.ErrorIs(t, a.Authenticate("10.3.0.1:1000", "", "wrong"), ErrInvalidPassword)
+	assert.Len(t, a.failures, 1, "idle hosts and expired blocks are removed")
+
+	// Failure counter of forgotten host starts from scratch.
+	for range 2 {
+		require.ErrorIs(t, a.Authenticate("10.0.0.1:1000", "", "wrong"), ErrInvalidPassword)
+	}
+	require.NoError(t, a.Authenticate("10.0.0.1:1000", "", "secret"))
+}
+
+// slowVerifier blocks verification of password "slow" until release is closed.
+type slowVerifier struct {
+	mockVerifier
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *slowVerifier) Verify(username, password string) bool {
+	if password == "slow" {
+		close(s.started)
+		<-s.release
+	}
+	return s.mockVerifier.Verify(username, password)
+}
+
+// TestAuthenticate_Concurrent tests that slow verification doesn't hold other hosts.
+func TestAuthenticate_Concurrent(t *testing.T) {
+	verifier := &slowVerifier{
+		mockVerifier: mockVerifier{passwords: map[string]string{"": "secret"}},
+		started:      make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+	a := New(verifier)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Authenticate("10.0.0.1:1000", "", "slow")
+	}()
+	<-verifier.started
+
+	require.NoError(t, a.Authenticate("10.0.0.2:1000", "", "secret"))
+
+	close(verifier.release)
+	require.ErrorIs(t, <-done, ErrInvalidPassword)
+}
@@ -0,0 +1,197 @@
+package session
+
+import (
+	"context"
+	"crypto/tls"
+	"kvdb/internal/model"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lastID is an ID of the last created session.
+var lastID atomic.Uint64
+
+type Session struct {
+	id          uint64
+	remoteAddr  string
+	identity    string // Verified client certificate common name.
+	connectedAt time.Time
+	bytesIn     atomic.Uint64
+	bytesOut    atomic.Uint64
+
+	mu                sync.RWMutex
+	user              string         // Authenticated user, empty for default user.
+	allowedCategories model.Category // Categories allowed on the listener.
+	name              string         // Name set by CLIENT SETNAME.
+	lastCommand       string
+	lastActiveAt      time.Time
+	kill              func() // Disconnects client.
+}
+
+type contextKey struct{}
+
+func New(conn net.Conn) *Session {
+	now := time.Now()
+	s := &Session{
+		id:                lastID.Add(1),
+		connectedAt:       now,
+		lastActiveAt:      now,
+		allowedCategories: model.CategoryAll,
+	}
+
+	if addr := conn.RemoteAddr(); addr != nil {
+		s.remoteAddr = addr.String()
+	}
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		s.identity = peerIdentity(tlsConn.ConnectionState())
+	}
+
+	return s
+}
+
+// NewWithAddr creates session for client without persistent connection, like HTTP request.
+func NewWithAddr(remoteAddr string) *Session {
+	now := time.Now()
+	return &Session{
+		id:                lastID.Add(1),
+		remoteAddr:        remoteAddr,
+		connectedAt:       now,
+		lastActiveAt:      now,
+		allowedCategories: model.CategoryAll,
+	}
+}
+
+// ID returns unique identifier of session within process.
+func (s *Session) ID() uint64 {
+	return s.id
+}
+
+func (s *Session) ConnectedAt() time.Time {
+	return s.connectedAt
+}
+
+func (s *Session) RemoteAddr() string {
+	return s.remoteAddr
+}
+
+// Identity returns common name of the client certificate, empty when the client
+// did not present a verified certificate.
+func (s *Session) Identity() string {
+	return s.identity
+}
+
+// User returns name of authenticated user, empty means default user.
+func (s *Session) User() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.user
+}
+
+func (s *Session) SetUser(user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.user = user
+}
+
+// AllowedCategories returns categories of commands allowed on the listener client connected to.
+func (s *Session) AllowedCategories() model.Category {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.allowedCategories
+}
+
+func (s *Session) SetAllowedCategories(categories model.Category) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.allowedCategories = categories
+}
+
+func (s *Session) Name() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.name
+}
+
+func (s *Session) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.name = name
+}
+
+// LastCommand returns name of the last command and time it was received.
+func (s *Session) LastCommand() (string, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastCommand, s.lastActiveAt
+}
+
+// SetLastCommand records command received from client.
+func (s *Session) SetLastCommand(command string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastCommand = command
+	s.lastActiveAt = time.Now()
+}
+
+func (s *Session) AddBytesIn(n uint64) {
+	s.bytesIn.Add(n)
+}
+
+func (s *Session) AddBytesOut(n uint64) {
+	s.bytesOut.Add(n)
+}
+
+// Bytes returns number of bytes received from and sent to client.
+func (s *Session) Bytes() (in, out uint64) {
+	return s.bytesIn.Load(), s.bytesOut.Load()
+}
+
+// SetKillFunc sets function which disconnects client.
+func (s *Session) SetKillFunc(kill func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.kill = kill
+}
+
+// Kill disconnects client. It returns false when session can't be disconnected.
+func (s *Session) Kill() bool {
+	s.mu.RLock()
+	kill := s.kill
+	s.mu.RUnlock()
+
+	if kill == nil {
+		return false
+	}
+
+	kill()
+	return true
+}
+
+func NewContext(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+func FromContext(ctx context.Context) (*Session, bool) {
+	s, ok := ctx.Value(contextKey{}).(*Session)
+	return s, ok
+}
+
+func peerIdentity(state tls.ConnectionState) string {
+	if len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return ""
+	}
+
+	return state.PeerCertificates[0].Subject.CommonName
+}
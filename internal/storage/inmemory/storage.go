@@ -2,39 +2,214 @@ package inmemory
 
 import (
 	"context"
+	"kvdb/internal/glob"
+	"kvdb/internal/metrics"
+	"sort"
 	"sync"
+	"time"
 )
 
+const (
+	// entryOverhead is a rough estimate of map entry size besides key and value data.
+	entryOverhead = 64
+
+	// purgeInterval is how often writes remove expired keys which nobody accessed.
+	purgeInterval = time.Second
+)
+
+// Storage keeps keys in memory. Keys with TTL expire lazily: expired key is
+// reported as missing and removed by the next write of it or by periodic purge.
 type Storage struct {
-	mu   sync.RWMutex
-	data map[string]string
+	mu        sync.RWMutex
+	data      map[string]string
+	expires   map[string]time.Time // Expiration time of keys with TTL.
+	memory    int64                // Estimated memory used by data in bytes.
+	now       func() time.Time
+	nextPurge time.Time
 }
 
 func New() *Storage {
 	return &Storage{
-		mu:   sync.RWMutex{},
-		data: make(map[string]string),
+		mu:      sync.RWMutex{},
+		data:    make(map[string]string),
+		expires: make(map[string]time.Time),
+		now:     time.Now,
 	}
 }
 
+// WithMetrics exposes number of keys and estimated memory usage.
+func (s *Storage) WithMetrics(registry *metrics.Registry) *Storage {
+	registry.GaugeFunc("kvdb_keys", "Number of keys in storage.", func() float64 {
+		return float64(s.Len())
+	})
+	registry.GaugeFunc("kvdb_memory_used_bytes", "Estimated memory used by keys and values.", func() float64 {
+		return float64(s.MemoryUsage())
+	})
+	return s
+}
+
 func (s *Storage) Get(_ context.Context, key string) (string, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	value, ok := s.data[key]
-	return value, ok
+	if !ok || s.expired(key) {
+		return "", false
+	}
+	return value, true
 }
 
+// Set sets value of key and removes its TTL.
 func (s *Storage) Set(_ context.Context, key, value string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.purge()
+	s.remove(key)
+	s.data[key] = value
+	s.memory += entrySize(key, value)
+}
+
+// Update atomically replaces value of key with value returned by fn. fn gets
+// current value and whether key exists, value is stored only when fn returns true.
+// TTL of key is kept.
+func (s *Storage) Update(_ context.Context, key string, fn func(value string, ok bool) (string, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purge()
+	if s.expired(key) {
+		s.remove(key)
+	}
+
+	old, ok := s.data[key]
+	value, store := fn(old, ok)
+	if !store {
+		return
+	}
+
+	if ok {
+		s.memory -= entrySize(key, old)
+	}
 	s.data[key] = value
+	s.memory += entrySize(key, value)
 }
 
 func (s *Storage) Del(_ context.Context, key string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	delete(s.data, key)
+	s.purge()
+	s.remove(key)
+}
+
+// Expire sets TTL of existing key, ttl of 0 or less removes TTL. It returns
+// false when key doesn't exist.
+func (s *Storage) Expire(_ context.Context, key string, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purge()
+	if s.expired(key) {
+		s.remove(key)
+	}
+	if _, ok := s.data[key]; !ok {
+		return false
+	}
+
+	if ttl <= 0 {
+		delete(s.expires, key)
+		return true
+	}
+	if s.expires == nil {
+		s.expires = make(map[string]time.Time)
+	}
+	s.expires[key] = s.now().Add(ttl)
+	return true
+}
+
+// TTL returns time left until key expires, 0 when key has no TTL, and whether
+// key exists.
+func (s *Storage) TTL(_ context.Context, key string) (time.Duration, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.data[key]; !ok || s.expired(key) {
+		return 0, false
+	}
+	expiresAt, ok := s.expires[key]
+	if !ok {
+		return 0, true
+	}
+	return expiresAt.Sub(s.now()), true
+}
+
+// Keys returns sorted keys matching glob pattern.
+func (s *Storage) Keys(_ context.Context, pattern string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0)
+	for key := range s.data {
+		if glob.Match(pattern, key) && !s.expired(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Len returns number of keys, expired keys which are not purged yet included.
+func (s *Storage) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.data)
+}
+
+// MemoryUsage returns estimated memory used by keys and values in bytes.
+func (s *Storage) MemoryUsage() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.memory
+}
+
+// expired reports whether key has TTL which is over, storage must be locked.
+func (s *Storage) expired(key string) bool {
+	expiresAt, ok := s.expires[key]
+	return ok && !s.now().Before(expiresAt)
+}
+
+// remove deletes key with its TTL, storage must be locked for writing.
+func (s *Storage) remove(key string) {
+	if old, ok := s.data[key]; ok {
+		s.memory -= entrySize(key, old)
+		delete(s.data, key)
+	}
+	delete(s.expires, key)
+}
+
+// purge removes expired keys at most once per purgeInterval, storage must be
+// locked for writing.
+func (s *Storage) purge() {
+	if len(s.expires) == 0 {
+		return
+	}
+
+	now := s.now()
+	if now.Before(s.nextPurge) {
+		return
+	}
+	s.nextPurge = now.Add(purgeInterval)
+
+	for key, expiresAt := range s.expires {
+		if !now.Before(expiresAt) {
+			s.remove(key)
+		}
+	}
+}
+
+func entrySize(key, value string) int64 {
+	return int64(len(key) + len(value) + entryOverhead)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -128,3 +129,101 @@ func TestStorage_Del(t *testing.T) {
 		})
 	}
 }
+
+func TestStorage_Stats(t *testing.T) {
+	ctx := context.Background()
+	storage := New()
+
+	storage.Set(ctx, "key1", "value1")
+	storage.Set(ctx, "key2", "value2")
+	storage.Set(ctx, "key1", "v")
+	storage.Del(ctx, "key2")
+	storage.Del(ctx, "missing")
+
+	assert.Equal(t, 1, storage.Len())
+	assert.Equal(t, int64(len("key1")+len("v")+entryOverhead), storage.MemoryUsage())
+
+	storage.Del(ctx, "key1")
+	assert.Equal(t, 0, storage.Len())
+	assert.Equal(t, int64(0), storage.MemoryUsage())
+}
+
+func TestStorage_Keys(t *testing.T) {
+	ctx := context.Background()
+	storage := New()
+
+	storage.Set(ctx, "user:2", "value")
+	storage.Set(ctx, "order:1", "value")
+	storage.Set(ctx, "user:1", "value")
+
+	assert.Equal(t, []string{"user:1", "user:2"}, storage.Keys(ctx, "user:*"))
+	assert.Equal(t, []string{"order:1", "user:1", "user:2"}, storage.Keys(ctx, "*"))
+	assert.Empty(t, storage.Keys(ctx, "missing"))
+}
+
+func TestStorage_Update(t *testing.T) {
+	ctx := context.Background()
+	storage := New()
+
+	storage.Update(ctx, "key", func(value string, ok bool) (string, bool) {
+		assert.False(t, ok)
+		return "a", true
+	})
+	storage.Update(ctx, "key", func(value string, ok bool) (string, bool) {
+		assert.True(t, ok)
+		assert.Equal(t, "a", value)
+		return "ignored", false
+	})
+
+	value, _ := storage.Get(ctx, "key")
+	assert.Equal(t, "a", value)
+	assert.Equal(t, int64(len("key")+len("a")+entryOverhead), storage.MemoryUsage())
+}
+
+func TestStorage_Expire(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	storage := New()
+	storage.now = func() time.Time { return now }
+
+	assert.False(t, storage.Expire(ctx, "missing", time.Minute))
+	_, ok := storage.TTL(ctx, "missing")
+	assert.False(t, ok)
+
+	storage.Set(ctx, "key", "value")
+	ttl, ok := storage.TTL(ctx, "key")
+	assert.True(t, ok)
+	assert.Zero(t, ttl, "no TTL")
+
+	assert.True(t, storage.Expire(ctx, "key", time.Minute))
+	now = now.Add(20 * time.Second)
+	ttl, _ = storage.TTL(ctx, "key")
+	assert.Equal(t, 40*time.Second, ttl)
+
+	// Update keeps TTL, Set removes it.
+	storage.Update(ctx, "key", func(string, bool) (string, bool) { return "updated", true })
+	ttl, _ = storage.TTL(ctx, "key")
+	assert.Equal(t, 40*time.Second, ttl)
+	storage.Set(ctx, "key", "value")
+	ttl, _ = storage.TTL(ctx, "key")
+	assert.Zero(t, ttl)
+
+	assert.True(t, storage.Expire(ctx, "key", time.Second))
+	now = now.Add(time.Second)
+	_, ok = storage.Get(ctx, "key")
+	assert.False(t, ok, "expired")
+	assert.Empty(t, storage.Keys(ctx, "*"))
+	assert.False(t, storage.Expire(ctx, "key", time.Minute))
+	storage.Update(ctx, "key", func(_ string, ok bool) (string, bool) {
+		assert.False(t, ok, "expired")
+		return "", false
+	})
+
+	// Expired keys which are not accessed are purged by writes.
+	storage.Set(ctx, "other", "value")
+	assert.True(t, storage.Expire(ctx, "other", time.Second))
+	now = now.Add(time.Minute)
+	storage.Set(ctx, "new", "value")
+	assert.Equal(t, 1, storage.Len())
+	assert.Equal(t, int64(len("new")+len("value")+entryOverhead), storage.MemoryUsage())
+}
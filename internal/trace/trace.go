@@ -0,0 +1,103 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kvdb/internal/model"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// MaxTraceIDLen is max length of trace ID provided by client.
+const MaxTraceIDLen = 64
+
+var ErrInvalidTraceID = model.WithCode(model.CodeSyntax, errors.New("invalid trace id"))
+
+// lastCommandID is an ID of the last command received by any listener.
+var lastCommandID atomic.Uint64
+
+// NextCommandID returns ID for a new command.
+func NextCommandID() uint64 {
+	return lastCommandID.Add(1)
+}
+
+// IDs identify command being run, so its log lines can be correlated with each
+// other and with client side errors.
+type IDs struct {
+	ConnID    uint64 // Session ID of connection, zero outside of connection.
+	CommandID uint64 // Unique within process, zero outside of command.
+	TraceID   string // Provided by client, empty when not provided.
+}
+
+type contextKey struct{}
+
+// NewContext returns context carrying ids. Zero fields are taken from ids
+// already carried by ctx, so connection ID survives when command ID is added.
+func NewContext(ctx context.Context, ids IDs) context.Context {
+	parent, _ := FromContext(ctx)
+	if ids.ConnID == 0 {
+		ids.ConnID = parent.ConnID
+	}
+	if ids.CommandID == 0 {
+		ids.CommandID = parent.CommandID
+	}
+	if ids.TraceID == "" {
+		ids.TraceID = parent.TraceID
+	}
+
+	return context.WithValue(ctx, contextKey{}, ids)
+}
+
+func FromContext(ctx context.Context) (IDs, bool) {
+	ids, ok := ctx.Value(contextKey{}).(IDs)
+	return ids, ok
+}
+
+// Fields returns log fields of ids carried by ctx. Zero ids are omitted.
+func Fields(ctx context.Context) []zap.Field {
+	ids, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	fields := make([]zap.Field, 0, 3)
+	if ids.ConnID != 0 {
+		fields = append(fields, zap.Uint64("conn_id", ids.ConnID))
+	}
+	if ids.CommandID != 0 {
+		fields = append(fields, zap.Uint64("command_id", ids.CommandID))
+	}
+	if ids.TraceID != "" {
+		fields = append(fields, zap.String("trace_id", ids.TraceID))
+	}
+	return fields
+}
+
+// Logger returns logger annotated with ids carried by ctx.
+func Logger(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	fields := Fields(ctx)
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.With(fields...)
+}
+
+// ValidateTraceID checks trace ID provided by client. It may contain letters,
+// digits, '-', '_', '.' and ':', so it fits any protocol and log format.
+func ValidateTraceID(id string) error {
+	if id == "" || len(id) > MaxTraceIDLen {
+		return fmt.Errorf("%w: want 1 to %d characters", ErrInvalidTraceID, MaxTraceIDLen)
+	}
+
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return fmt.Errorf("%w: unexpected character %q", ErrInvalidTraceID, r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package trace
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, Fields(ctx))
+
+	ctx = NewContext(ctx, IDs{ConnID: 3})
+	assert.Equal(t, []zap.Field{zap.Uint64("conn_id", 3)}, Fields(ctx))
+
+	cmdCtx := NewContext(ctx, IDs{CommandID: 10, TraceID: "req-1"})
+	ids, ok := FromContext(cmdCtx)
+	require.True(t, ok)
+	assert.Equal(t, IDs{ConnID: 3, CommandID: 10, TraceID: "req-1"}, ids)
+	assert.Equal(t, []zap.Field{
+		zap.Uint64("conn_id", 3),
+		zap.Uint64("command_id", 10),
+		zap.String("trace_id", "req-1"),
+	}, Fields(cmdCtx))
+
+	ids, _ = FromContext(ctx)
+	assert.Equal(t, IDs{ConnID: 3}, ids, "parent context is not changed")
+}
+
+func TestNextCommandID(t *testing.T) {
+	first := NextCommandID()
+	assert.Equal(t, first+1, NextCommandID())
+}
+
+func TestValidateTraceID(t *testing.T) {
+	for _, id := range []string{"req-1", "4bf92f3577b34da6a3ce929d0e0e4736", "svc.api:42_a", strings.Repeat("a", MaxTraceIDLen)} {
+		assert.NoError(t, ValidateTraceID(id), id)
+	}
+	for _, id := range []string{"", "a b", `"quoted"`, "ключ", strings.Repeat("a", MaxTraceIDLen+1)} {
+		assert.ErrorIs(t, ValidateTraceID(id), ErrInvalidTraceID, id)
+	}
+}
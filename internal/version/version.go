@@ -0,0 +1,4 @@
+package version
+
+// Version of kvdb, set at build time with -ldflags "-X kvdb/internal/version.Version=v1.2.3".
+var Version = "dev"
@@ -0,0 +1,179 @@
+// Package kvdb embeds key value database into Go program without network hop.
+//
+// Handle returned by Open is built on the same database and storage engine as
+// the server, so commands behave the same way. Data is kept in memory only, like
+// in the server, and is lost when handle is closed or program exits.
+package kvdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kvdb/internal/compute"
+	"kvdb/internal/database"
+	"kvdb/internal/storage/inmemory"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// EngineInMemory is the only supported storage engine.
+const EngineInMemory = "in_memory"
+
+var (
+	ErrClosed        = errors.New("database is closed")
+	ErrUnknownEngine = errors.New("unknown engine")
+)
+
+// Options configure database opened by Open. Zero value is valid.
+type Options struct {
+	Engine string      // Storage engine, default EngineInMemory.
+	Logger *zap.Logger // Logger of database, default logs nothing.
+}
+
+// DB is a handle of embedded database. It is safe for concurrent use.
+//
+// Writes are serialized with each other, reads run concurrently. Batches and
+// transactions are applied atomically: readers never see part of them.
+type DB struct {
+	mu     sync.RWMutex
+	db     *database.Database
+	closed bool
+}
+
+// Open opens database.
+func Open(opts Options) (*DB, error) {
+	if opts.Engine == "" {
+		opts.Engine = EngineInMemory
+	}
+	if opts.Engine != EngineInMemory {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEngine, opts.Engine)
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+
+	return &DB{
+		db: database.New(opts.Logger, compute.New(), inmemory.New()),
+	}, nil
+}
+
+// Close releases database. Data is dropped, every later call returns ErrClosed.
+func (d *DB) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return ErrClosed
+	}
+	d.closed = true
+	d.db = nil
+	return nil
+}
+
+// Get returns value of key and whether key exists.
+func (d *DB) Get(ctx context.Context, key string) (string, bool, error) {
+	unlock, err := d.rlock(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	defer unlock()
+
+	return d.db.Get(ctx, key)
+}
+
+// Set sets value of key.
+func (d *DB) Set(ctx context.Context, key, value string) error {
+	return d.Write(ctx, NewBatch().Set(key, value))
+}
+
+// Delete deletes key. Deleting missing key is not an error.
+func (d *DB) Delete(ctx context.Context, key string) error {
+	return d.Write(ctx, NewBatch().Delete(key))
+}
+
+// Scan calls fn for keys matching glob pattern and their values in key order.
+// Scan sees snapshot of database taken when it starts, so fn may modify database.
+// Scan stops at first error of fn and returns it.
+func (d *DB) Scan(ctx context.Context, pattern string, fn func(key, value string) error) error {
+	items, err := d.snapshot(ctx, pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(item.key, item.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type item struct {
+	key   string
+	value string
+}
+
+func (d *DB) snapshot(ctx context.Context, pattern string) ([]item, error) {
+	unlock, err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	keys, err := d.db.Keys(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]item, 0, len(keys))
+	for _, key := range keys {
+		value, ok, err := d.db.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			items = append(items, item{key: key, value: value})
+		}
+	}
+	return items, nil
+}
+
+// rlock locks database for reading. It fails when ctx is done, ctx is context
+// of transaction of d or database is closed.
+func (d *DB) rlock(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if ctx.Value(txKey{}) == d {
+		return nil, ErrInTransaction
+	}
+
+	d.mu.RLock()
+	if d.closed {
+		d.mu.RUnlock()
+		return nil, ErrClosed
+	}
+	return d.mu.RUnlock, nil
+}
+
+// lock locks database for writing. It fails when ctx is done, ctx is context
+// of transaction of d or database is closed.
+func (d *DB) lock(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if ctx.Value(txKey{}) == d {
+		return nil, ErrInTransaction
+	}
+
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil, ErrClosed
+	}
+	return d.mu.Unlock, nil
+}
@@ -0,0 +1,199 @@
+package kvdb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen_UnknownEngine(t *testing.T) {
+	_, err := Open(Options{Engine: "disk"})
+	require.ErrorIs(t, err, ErrUnknownEngine)
+}
+
+func TestDB_Closed(t *testing.T) {
+	db, err := Open(Options{})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	ctx := context.Background()
+	_, _, err = db.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.ErrorIs(t, db.Set(ctx, "key", "value"), ErrClosed)
+	assert.ErrorIs(t, db.Update(ctx, func(*Tx) error { return nil }), ErrClosed)
+	assert.ErrorIs(t, db.Close(), ErrClosed)
+}
+
+func TestDB_Context(t *testing.T) {
+	db, err := Open(Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, db.Set(ctx, "key", "value"), context.Canceled)
+	_, _, err = db.Get(ctx, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, db.Scan(ctx, "*", func(string, string) error { return nil }), context.Canceled)
+}
+
+func TestDB_Scan_StopsOnError(t *testing.T) {
+	db, err := Open(Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	require.NoError(t, db.Write(ctx, NewBatch().Set("a", "1").Set("b", "2")))
+
+	stop := errors.New("stop")
+	var keys []string
+	err = db.Scan(ctx, "*", func(key, _ string) error {
+		keys = append(keys, key)
+		// Scan sees snapshot, so writes don't deadlock and don't change iteration.
+		require.NoError(t, db.Set(ctx, "c", "3"))
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, []string{"a"}, keys)
+}
+
+func TestDB_Update(t *testing.T) {
+	db, err := Open(Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	require.NoError(t, db.Set(ctx, "key", "old"))
+
+	t.Run("reads own writes", func(t *testing.T) {
+		err := db.Update(ctx, func(tx *Tx) error {
+			require.NoError(t, tx.Set("key", "new"))
+			value, ok, err := tx.Get("key")
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, "new", value)
+
+			require.NoError(t, tx.Delete("key"))
+			_, ok, err = tx.Get("key")
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			return tx.Set("key", "committed")
+		})
+		require.NoError(t, err)
+
+		value, _, err := db.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "committed", value)
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		failure := errors.New("failure")
+		err := db.Update(ctx, func(tx *Tx) error {
+			require.NoError(t, tx.Set("key", "discarded"))
+			return failure
+		})
+		require.ErrorIs(t, err, failure)
+
+		value, _, err := db.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "committed", value)
+	})
+
+	t.Run("rollback on canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		err := db.Update(ctx, func(tx *Tx) error {
+			require.NoError(t, tx.Set("key", "discarded"))
+			cancel()
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+
+		value, _, err := db.Get(context.Background(), "key")
+		require.NoError(t, err)
+		assert.Equal(t, "committed", value)
+	})
+}
+
+func TestDB_View_ReadOnly(t *testing.T) {
+	db, err := Open(Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.View(context.Background(), func(tx *Tx) error {
+		return tx.Set("key", "value")
+	})
+	assert.ErrorIs(t, err, ErrTxReadOnly)
+}
+
+// TestDB_InTransaction tests that methods of DB called with context of its
+// transaction fail instead of deadlock.
+func TestDB_InTransaction(t *testing.T) {
+	db, err := Open(Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	err = db.Update(ctx, func(tx *Tx) error {
+		_, _, err := db.Get(tx.Context(), "key")
+		assert.ErrorIs(t, err, ErrInTransaction)
+		assert.ErrorIs(t, db.Set(tx.Context(), "key", "value"), ErrInTransaction)
+		return db.View(tx.Context(), func(*Tx) error { return nil })
+	})
+	assert.ErrorIs(t, err, ErrInTransaction)
+
+	err = db.View(ctx, func(tx *Tx) error {
+		return db.Update(tx.Context(), func(*Tx) error { return nil })
+	})
+	assert.ErrorIs(t, err, ErrInTransaction)
+
+	other, err := Open(Options{})
+	require.NoError(t, err)
+	defer other.Close()
+
+	err = db.Update(ctx, func(tx *Tx) error {
+		return other.Set(tx.Context(), "key", "value")
+	})
+	require.NoError(t, err)
+}
+
+// TestDB_Update_Concurrent tests that concurrent read-modify-write transactions
+// don't lose updates.
+func TestDB_Update_Concurrent(t *testing.T) {
+	db, err := Open(Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	const workers, increments = 8, 50
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range increments {
+				err := db.Update(ctx, func(tx *Tx) error {
+					value, _, err := tx.Get("counter")
+					if err != nil {
+						return err
+					}
+					n, _ := strconv.Atoi(value)
+					return tx.Set("counter", strconv.Itoa(n+1))
+				})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	value, _, err := db.Get(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(workers*increments), value)
+}
@@ -0,0 +1,104 @@
+package kvdb
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrTxReadOnly    = errors.New("transaction is read only")
+	ErrInTransaction = errors.New("called inside transaction of the same database")
+)
+
+// txKey marks context of transaction with its database.
+type txKey struct{}
+
+// Tx is a transaction started by DB.Update or DB.View. Tx is valid only inside
+// the function it is passed to and is not safe for concurrent use.
+//
+// Function of transaction must read and write through Tx only. Methods of DB
+// called from it wait until the transaction ends, so it never ends. Pass
+// Tx.Context to calls made inside transaction: methods of DB return
+// ErrInTransaction for it instead of waiting.
+type Tx struct {
+	ctx      context.Context
+	db       *DB
+	writable bool
+	writes   *Batch
+	pending  map[string]op // Last write of key, so transaction reads its own writes.
+}
+
+// Update runs fn in read-write transaction. Writes of fn are applied atomically
+// when fn returns nil and discarded when fn returns error or ctx is done.
+// Other writers and readers wait until transaction ends. fn must not call methods
+// of d, see Tx.
+func (d *DB) Update(ctx context.Context, fn func(tx *Tx) error) error {
+	unlock, err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	txCtx := context.WithValue(ctx, txKey{}, d)
+	tx := &Tx{ctx: txCtx, db: d, writable: true, writes: NewBatch(), pending: make(map[string]op)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return d.apply(ctx, tx.writes)
+}
+
+// View runs fn in read only transaction. Every read of fn sees the same state
+// of database. fn must not call methods of d, see Tx.
+func (d *DB) View(ctx context.Context, fn func(tx *Tx) error) error {
+	unlock, err := d.rlock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return fn(&Tx{ctx: context.WithValue(ctx, txKey{}, d), db: d})
+}
+
+// Context returns context of transaction. Methods of its DB called with it
+// return ErrInTransaction.
+func (tx *Tx) Context() context.Context {
+	return tx.ctx
+}
+
+// Get returns value of key and whether key exists, writes of transaction included.
+func (tx *Tx) Get(key string) (string, bool, error) {
+	if err := tx.ctx.Err(); err != nil {
+		return "", false, err
+	}
+	if op, ok := tx.pending[key]; ok {
+		return op.value, !op.delete, nil
+	}
+	return tx.db.db.Get(tx.ctx, key)
+}
+
+// Set sets value of key when transaction is applied.
+func (tx *Tx) Set(key, value string) error {
+	return tx.write(op{key: key, value: value})
+}
+
+// Delete deletes key when transaction is applied.
+func (tx *Tx) Delete(key string) error {
+	return tx.write(op{key: key, delete: true})
+}
+
+func (tx *Tx) write(op op) error {
+	if !tx.writable {
+		return ErrTxReadOnly
+	}
+	if err := tx.ctx.Err(); err != nil {
+		return err
+	}
+
+	tx.writes.ops = append(tx.writes.ops, op)
+	tx.pending[op.key] = op
+	return nil
+}